@@ -0,0 +1,298 @@
+package annotations
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// colors of the device, the highlighters default to yellow
+var brushColors = map[rm.BrushColor]color.RGBA{
+	rm.Black:       {0, 0, 0, 255},
+	rm.Grey:        {125, 125, 125, 255},
+	rm.White:       {255, 255, 255, 255},
+	rm.Yellow:      {255, 237, 117, 255},
+	rm.Green:       {0, 158, 73, 255},
+	rm.Pink:        {255, 110, 180, 255},
+	rm.Blue:        {78, 105, 201, 255},
+	rm.Red:         {179, 62, 57, 255},
+	rm.GreyOverlap: {125, 125, 125, 255},
+	rm.Highlight:   {255, 237, 117, 255},
+	rm.Green2:      {161, 216, 125, 255},
+	rm.Cyan:        {139, 208, 229, 255},
+	rm.Magenta:     {247, 132, 209, 255},
+	rm.Yellow2:     {254, 241, 133, 255},
+}
+
+// minWidth keeps the lines without width visible
+const minWidth = 0.5
+
+// A pen is the way a brush draws a line.
+type pen struct {
+	color color.RGBA
+	// square is true for the brushes with a flat tip
+	square bool
+	// constant is true when the whole line has the same width and opacity
+	constant bool
+	// multiply is true for the highlighters which darken what is under them
+	multiply bool
+	// width and opacity give the look of the line at a point
+	width   func(p rm.Point) float64
+	opacity func(p rm.Point) float64
+}
+
+// A segment is a part of a line between two points, in the coordinates of the device.
+type segment struct {
+	x1, y1, x2, y2 float64
+	width, opacity float64
+}
+
+// penOf returns the pen of the line, false for the erasers which don't draw.
+func penOf(line rm.Line) (pen, bool) {
+	c, ok := brushColors[line.BrushColor]
+	if !ok {
+		c = brushColors[rm.Black]
+	}
+	p := pen{
+		color:   c,
+		width:   func(p rm.Point) float64 { return float64(p.Width) },
+		opacity: func(rm.Point) float64 { return 1 },
+	}
+	opacity := func(o float64) func(rm.Point) float64 {
+		return func(rm.Point) float64 { return o }
+	}
+
+	switch line.BrushType {
+	case rm.Eraser, rm.EraseArea:
+		return p, false
+	case rm.BallPoint, rm.BallPointV5:
+		p.width = func(p rm.Point) float64 { return float64(p.Width) * (0.6 + 0.4*float64(p.Pressure)) }
+	case rm.Brush, rm.BrushV5:
+		p.width = func(p rm.Point) float64 { return float64(p.Width) * (0.4 + 0.8*float64(p.Pressure)) }
+	case rm.Marker, rm.MarkerV5:
+		p.square = true
+	case rm.Fineliner, rm.FinelinerV5:
+		p.constant = true
+	case rm.SharpPencil, rm.SharpPencilV5:
+		p.constant = true
+		p.width = func(p rm.Point) float64 { return float64(p.Width) * 0.6 }
+		p.opacity = opacity(0.9)
+	case rm.TiltPencil, rm.TiltPencilV5:
+		// the pencil is lighter when pressed lightly
+		p.opacity = func(p rm.Point) float64 { return 0.3 + 0.6*float64(p.Pressure) }
+	case rm.Highlighter, rm.HighlighterV5:
+		if line.BrushColor == rm.Black {
+			// the highlighters of the older versions are yellow
+			p.color = brushColors[rm.Highlight]
+		}
+		p.constant = true
+		p.square = true
+		p.multiply = true
+		p.opacity = opacity(0.35)
+	case rm.Calligraphy:
+		// the nib is tilted by 45°, the line is thinner along the nib
+		p.width = func(p rm.Point) float64 {
+			return float64(p.Width) * (0.3 + 0.7*math.Abs(math.Sin(float64(p.Direction)-math.Pi/4)))
+		}
+	case rm.Shader:
+		p.constant = true
+		p.opacity = opacity(0.1)
+	}
+	return p, true
+}
+
+// segments returns the segments of the line drawn with the pen, a constant pen
+// uses the mean width and opacity of the points. A line of a single point is a dot.
+func (p pen) segments(line rm.Line) []segment {
+	points := line.Points
+	if len(points) == 0 {
+		return nil
+	}
+	if len(points) == 1 {
+		points = []rm.Point{points[0], points[0]}
+	}
+
+	segments := make([]segment, len(points)-1)
+	var width, opacity float64
+	for i := 1; i < len(points); i++ {
+		from, to := points[i-1], points[i]
+		segments[i-1] = segment{
+			x1: float64(from.X), y1: float64(from.Y),
+			x2: float64(to.X), y2: float64(to.Y),
+			width:   math.Max(p.width(to), minWidth),
+			opacity: p.opacity(to),
+		}
+		width += segments[i-1].width
+		opacity += segments[i-1].opacity
+	}
+	if p.constant {
+		width /= float64(len(segments))
+		opacity /= float64(len(segments))
+		for i := range segments {
+			segments[i].width, segments[i].opacity = width, opacity
+		}
+	}
+	return segments
+}
+
+// runs splits the segments into runs of consecutive segments with the same width and opacity
+func runs(segments []segment) [][]segment {
+	var result [][]segment
+	for start := 0; start < len(segments); {
+		s := segments[start]
+		end := start + 1
+		for end < len(segments) && num(segments[end].width) == num(s.width) && num(segments[end].opacity) == num(s.opacity) {
+			end++
+		}
+		result = append(result, segments[start:end])
+		start = end
+	}
+	return result
+}
+
+// An outline is the shape of a part of a line with the same opacity, as a polygon
+// in the coordinates of the device.
+type outline struct {
+	points  [][2]float64
+	opacity float64
+}
+
+// opacityStep groups the points of the lines whose opacity changes into outlines
+const opacityStep = 0.05
+
+// outlines returns the shapes of the line drawn with the pen: the width changes
+// along the line and the caps are round, or square for a flat tip.
+func (p pen) outlines(line rm.Line) []outline {
+	// the points at the same place don't give a direction
+	var points []rm.Point
+	for i, pt := range line.Points {
+		if i > 0 && pt.X == points[len(points)-1].X && pt.Y == points[len(points)-1].Y {
+			continue
+		}
+		points = append(points, pt)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	widths := make([]float64, len(points))
+	opacities := make([]float64, len(points))
+	for i, pt := range points {
+		widths[i] = math.Max(p.width(pt), minWidth)
+		opacities[i] = math.Round(p.opacity(pt)/opacityStep) * opacityStep
+	}
+	if p.constant {
+		widths = constant(widths)
+		opacities = constant(opacities)
+	}
+
+	var result []outline
+	for start := 0; start < len(points); {
+		end := start + 1
+		for end < len(points) && opacities[end] == opacities[start] {
+			end++
+		}
+		// the parts of the line share their ends
+		last := end
+		if last < len(points) {
+			last++
+		}
+		result = append(result, outline{
+			points:  p.shape(points[start:last], widths[start:last]),
+			opacity: opacities[start],
+		})
+		start = end
+	}
+	return result
+}
+
+// constant replaces the values by their mean
+func constant(values []float64) []float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	result := make([]float64, len(values))
+	for i := range result {
+		result[i] = sum / float64(len(values))
+	}
+	return result
+}
+
+// shape returns the polygon around the points: their left side, the end cap,
+// their right side backwards and the start cap
+func (p pen) shape(points []rm.Point, widths []float64) [][2]float64 {
+	n := len(points)
+	at := func(i int) [2]float64 { return [2]float64{float64(points[i].X), float64(points[i].Y)} }
+	if n == 1 {
+		return circle(at(0), widths[0]/2)
+	}
+
+	left := make([][2]float64, n)
+	right := make([][2]float64, n)
+	var firstDir, lastDir [2]float64
+	for i := 0; i < n; i++ {
+		// the direction at a point goes from the previous point to the next one
+		from, to := at(max(i-1, 0)), at(min(i+1, n-1))
+		dir := unit(to[0]-from[0], to[1]-from[1])
+		if i == 0 {
+			firstDir = dir
+		}
+		lastDir = dir
+		h := widths[i] / 2
+		pt := at(i)
+		left[i] = [2]float64{pt[0] - dir[1]*h, pt[1] + dir[0]*h}
+		right[i] = [2]float64{pt[0] + dir[1]*h, pt[1] - dir[0]*h}
+	}
+
+	shape := append([][2]float64{}, left...)
+	shape = append(shape, p.cap(at(n-1), lastDir, widths[n-1]/2)...)
+	for i := n - 1; i >= 0; i-- {
+		shape = append(shape, right[i])
+	}
+	shape = append(shape, p.cap(at(0), [2]float64{-firstDir[0], -firstDir[1]}, widths[0]/2)...)
+	return shape
+}
+
+// cap returns the points of the cap at the end pt of a line going in the direction dir,
+// from its left side to its right side
+func (p pen) cap(pt, dir [2]float64, h float64) [][2]float64 {
+	normal := [2]float64{-dir[1], dir[0]}
+	if p.square {
+		return [][2]float64{
+			{pt[0] + (normal[0]+dir[0])*h, pt[1] + (normal[1]+dir[1])*h},
+			{pt[0] + (dir[0]-normal[0])*h, pt[1] + (dir[1]-normal[1])*h},
+		}
+	}
+	// a half circle
+	const steps = 6
+	var points [][2]float64
+	for i := 1; i < steps; i++ {
+		a := math.Pi * float64(i) / steps
+		x, y := math.Cos(a), math.Sin(a)
+		points = append(points, [2]float64{
+			pt[0] + (normal[0]*x+dir[0]*y)*h,
+			pt[1] + (normal[1]*x+dir[1]*y)*h,
+		})
+	}
+	return points
+}
+
+func circle(center [2]float64, radius float64) [][2]float64 {
+	const steps = 12
+	points := make([][2]float64, steps)
+	for i := range points {
+		a := 2 * math.Pi * float64(i) / steps
+		points[i] = [2]float64{center[0] + radius*math.Cos(a), center[1] + radius*math.Sin(a)}
+	}
+	return points
+}
+
+func unit(x, y float64) [2]float64 {
+	length := math.Hypot(x, y)
+	if length == 0 {
+		return [2]float64{1, 0}
+	}
+	return [2]float64{x / length, y / length}
+}
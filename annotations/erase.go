@@ -0,0 +1,112 @@
+package annotations
+
+import (
+	"math"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// eraserWidth is the width of the erasers whose points have none
+const eraserWidth = 20
+
+// erase applies the erasers of a layer to the lines drawn before them and drops
+// the erasers: the eraser removes the parts of the lines it goes over and the area
+// eraser the parts of the lines inside the area it outlines.
+func erase(lines []rm.Line) []rm.Line {
+	var result []rm.Line
+	for _, line := range lines {
+		var erased func(p rm.Point) bool
+		switch line.BrushType {
+		case rm.Eraser:
+			erased = underEraser(line)
+		case rm.EraseArea:
+			erased = insideArea(line)
+		default:
+			result = append(result, line)
+			continue
+		}
+
+		var kept []rm.Line
+		for _, l := range result {
+			kept = append(kept, split(l, erased)...)
+		}
+		result = kept
+	}
+	return result
+}
+
+// split returns the parts of the line left by the eraser
+func split(line rm.Line, erased func(p rm.Point) bool) []rm.Line {
+	var parts []rm.Line
+	var points []rm.Point
+	flush := func() {
+		// a point left alone isn't visible
+		if len(points) > 1 || (len(points) == 1 && len(line.Points) == 1) {
+			part := line
+			part.Points = points
+			parts = append(parts, part)
+		}
+		points = nil
+	}
+	for _, p := range line.Points {
+		if erased(p) {
+			flush()
+			continue
+		}
+		points = append(points, p)
+	}
+	flush()
+	return parts
+}
+
+// underEraser returns whether a point is under the line of the eraser
+func underEraser(eraser rm.Line) func(p rm.Point) bool {
+	var width float64
+	for _, p := range eraser.Points {
+		width += float64(p.Width)
+	}
+	if len(eraser.Points) > 0 {
+		width /= float64(len(eraser.Points))
+	}
+	if width == 0 {
+		width = eraserWidth
+	}
+	radius := width / 2
+
+	return func(p rm.Point) bool {
+		x, y := float64(p.X), float64(p.Y)
+		for i := range eraser.Points {
+			a := eraser.Points[max(i-1, 0)]
+			b := eraser.Points[i]
+			if distance(x, y, float64(a.X), float64(a.Y), float64(b.X), float64(b.Y)) <= radius {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// distance returns the distance between the point x, y and the segment x1, y1, x2, y2
+func distance(x, y, x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((x-x1)*dx+(y-y1)*dy)/l))
+	}
+	return math.Hypot(x-(x1+t*dx), y-(y1+t*dy))
+}
+
+// insideArea returns whether a point is inside the polygon outlined by the area eraser
+func insideArea(eraser rm.Line) func(p rm.Point) bool {
+	points := eraser.Points
+	return func(p rm.Point) bool {
+		inside := false
+		for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+			a, b := points[i], points[j]
+			if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+				inside = !inside
+			}
+		}
+		return inside
+	}
+}
@@ -0,0 +1,73 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+)
+
+func horizontal(brush rm.BrushType, y float32, xs ...float32) rm.Line {
+	line := rm.Line{BrushType: brush}
+	for _, x := range xs {
+		line.Points = append(line.Points, rm.Point{X: x, Y: y, Width: 2})
+	}
+	return line
+}
+
+func TestErase(t *testing.T) {
+	first := horizontal(rm.FinelinerV5, 100, 0, 10, 20, 30, 40, 50, 60)
+	eraser := rm.Line{BrushType: rm.Eraser, Points: []rm.Point{{X: 30, Y: 50, Width: 10}, {X: 30, Y: 150, Width: 10}}}
+	// drawn after the eraser
+	second := horizontal(rm.FinelinerV5, 120, 0, 30, 60)
+
+	lines := erase([]rm.Line{first, eraser, second})
+	assert.Equal(t, []rm.Line{
+		horizontal(rm.FinelinerV5, 100, 0, 10, 20),
+		horizontal(rm.FinelinerV5, 100, 40, 50, 60),
+		second,
+	}, lines)
+
+	area := rm.Line{BrushType: rm.EraseArea, Points: []rm.Point{{X: -5, Y: 90}, {X: 15, Y: 90}, {X: 15, Y: 130}, {X: -5, Y: 130}}}
+	lines = erase([]rm.Line{first, second, area})
+	assert.Equal(t, []rm.Line{
+		horizontal(rm.FinelinerV5, 100, 20, 30, 40, 50, 60),
+		horizontal(rm.FinelinerV5, 120, 30, 60),
+	}, lines)
+
+	// a line reduced to a point disappears, a dot stays
+	dot := horizontal(rm.FinelinerV5, 300, 500)
+	assert.Equal(t, []rm.Line{dot}, erase([]rm.Line{horizontal(rm.FinelinerV5, 100, 30, 500), dot, eraser}))
+}
+
+func TestOutlines(t *testing.T) {
+	pen, _ := penOf(rm.Line{BrushType: rm.FinelinerV5})
+	outlines := pen.outlines(horizontal(rm.FinelinerV5, 100, 0, 10, 10, 20))
+	if !assert.Len(t, outlines, 1) {
+		return
+	}
+	assert.Equal(t, 1.0, outlines[0].opacity)
+	// the left and the right sides of the 3 distinct points with two round caps
+	points := outlines[0].points
+	assert.Len(t, points, 3+5+3+5)
+	assert.Equal(t, [2]float64{0, 101}, points[0])
+	assert.Equal(t, [2]float64{20, 101}, points[2])
+	assert.InDelta(t, 21, points[5][0], 1e-9)
+	assert.InDelta(t, 100, points[5][1], 1e-9)
+	assert.Equal(t, [2]float64{20, 99}, points[8])
+
+	// the pencil is split where its opacity changes
+	pen, _ = penOf(rm.Line{BrushType: rm.TiltPencilV5})
+	line := horizontal(rm.TiltPencilV5, 0, 0, 10, 20, 30)
+	for i := range line.Points {
+		line.Points[i].Pressure = float32(i / 2)
+	}
+	outlines = pen.outlines(line)
+	if assert.Len(t, outlines, 2) {
+		assert.InDelta(t, 0.3, outlines[0].opacity, 1e-9)
+		assert.InDelta(t, 0.9, outlines[1].opacity, 1e-9)
+	}
+
+	// a dot
+	assert.Len(t, pen.outlines(horizontal(rm.TiltPencilV5, 0, 5)), 1)
+}
@@ -0,0 +1,184 @@
+package annotations
+
+import (
+	"math"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/unidoc/unipdf/v3/core"
+	pdf "github.com/unidoc/unipdf/v3/model"
+)
+
+// A geometry maps the points of the device to the points of a PDF page.
+//
+// The device shows the visible box of the page, turned by its rotation, as big as
+// it fits in a frame: the screen, in landscape for the landscape documents, or the
+// page size of the v6 files when the document has one. The page is centered
+// horizontally and at the top of the frame. The zoom and crop of the older
+// firmwares move the page on the screen and the points with it.
+type geometry struct {
+	// inverse undoes the transform of the document, nil when there is none
+	inverse *affine
+	// left is the left side of the page in the coordinates of the device, its top is at 0
+	left float64
+	// scale converts the lengths of the device to PDF points
+	scale float64
+	// box is the visible area of the page and rotate its rotation in degrees
+	box    pdf.PdfRectangle
+	rotate int
+}
+
+// newGeometry returns the geometry of a page with the given visible box and rotation
+// in the document. v6 is true for the pages of the v6 files.
+func newGeometry(box pdf.PdfRectangle, rotate int, content *archive.Content, v6 bool) geometry {
+	g := geometry{box: box, rotate: rotate}
+	width, height := g.size()
+
+	frameLeft, frameWidth, frameHeight := 0.0, float64(rm.Width), float64(rm.Height)
+	if content.Orientation == "landscape" {
+		frameWidth, frameHeight = frameHeight, frameWidth
+	}
+	if v6 && content.CustomZoomPageWidth > 0 && content.CustomZoomPageHeight > 0 {
+		// the x axis of the v6 files starts in the middle of the screen
+		frameWidth, frameHeight = content.CustomZoomPageWidth, content.CustomZoomPageHeight
+		frameLeft = (float64(rm.Width) - frameWidth) / 2
+	}
+
+	g.scale = math.Max(width/frameWidth, height/frameHeight)
+	g.left = frameLeft + (frameWidth-width/g.scale)/2
+	if t := content.Transform; t != nil {
+		m := affine{float64(t.M11), float64(t.M12), float64(t.M21), float64(t.M22), float64(t.M31), float64(t.M32)}
+		if !m.identity() {
+			g.inverse = m.invert()
+		}
+	}
+	return g
+}
+
+// size returns the size of the page as it is shown, in PDF points
+func (g geometry) size() (width, height float64) {
+	width, height = g.box.Urx-g.box.Llx, g.box.Ury-g.box.Lly
+	if g.rotate == 90 || g.rotate == 270 {
+		return height, width
+	}
+	return width, height
+}
+
+// point returns the point of the page under the point x, y of the device
+func (g geometry) point(x, y float64) (float64, float64) {
+	if g.inverse != nil {
+		x, y = g.inverse.apply(x, y)
+	}
+	// from the top left corner of the page as it is shown
+	u, v := (x-g.left)*g.scale, y*g.scale
+	b := g.box
+	switch g.rotate {
+	case 90:
+		return b.Llx + v, b.Lly + u
+	case 180:
+		return b.Urx - u, b.Lly + v
+	case 270:
+		return b.Urx - v, b.Ury - u
+	}
+	return b.Llx + u, b.Ury - v
+}
+
+// length returns a length of the device in PDF points
+func (g geometry) length(l float64) float64 {
+	if g.inverse != nil {
+		l *= math.Sqrt(math.Abs(g.inverse.determinant()))
+	}
+	return l * g.scale
+}
+
+// An affine transform maps x, y to a*x + c*y + e, b*x + d*y + f.
+type affine struct {
+	a, b, c, d, e, f float64
+}
+
+func (m affine) apply(x, y float64) (float64, float64) {
+	return m.a*x + m.c*y + m.e, m.b*x + m.d*y + m.f
+}
+
+func (m affine) determinant() float64 {
+	return m.a*m.d - m.b*m.c
+}
+
+func (m affine) identity() bool {
+	return m == affine{a: 1, d: 1}
+}
+
+// invert returns the inverse transform, nil when there is none
+func (m affine) invert() *affine {
+	det := m.determinant()
+	if det == 0 {
+		return nil
+	}
+	return &affine{
+		a: m.d / det, b: -m.b / det,
+		c: -m.c / det, d: m.a / det,
+		e: (m.c*m.f - m.d*m.e) / det,
+		f: (m.b*m.e - m.a*m.f) / det,
+	}
+}
+
+// pageBox returns the visible area of the page: its crop box inside its media box
+func pageBox(page *pdf.PdfPage) (pdf.PdfRectangle, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return pdf.PdfRectangle{}, err
+	}
+	box := normalizedBox(*mbox)
+	crop := page.CropBox
+	if crop == nil {
+		if arr, ok := core.GetArray(inherited(page, "CropBox")); ok {
+			if crop, err = pdf.NewPdfRectangle(*arr); err != nil {
+				return pdf.PdfRectangle{}, err
+			}
+		}
+	}
+	if crop != nil {
+		c := normalizedBox(*crop)
+		c.Llx, c.Lly = math.Max(c.Llx, box.Llx), math.Max(c.Lly, box.Lly)
+		c.Urx, c.Ury = math.Min(c.Urx, box.Urx), math.Min(c.Ury, box.Ury)
+		if c.Llx < c.Urx && c.Lly < c.Ury {
+			box = c
+		}
+	}
+	return box, nil
+}
+
+// pageRotation returns the rotation of the page in degrees: 0, 90, 180 or 270
+func pageRotation(page *pdf.PdfPage) int {
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = *page.Rotate
+	} else if i, ok := core.GetIntVal(inherited(page, "Rotate")); ok {
+		rotate = int64(i)
+	}
+	return int(((rotate%360)+360)%360) / 90 * 90
+}
+
+// inherited returns an attribute of the page tree above the page, nil when none has it
+func inherited(page *pdf.PdfPage, key core.PdfObjectName) core.PdfObject {
+	node := page.Parent
+	for node != nil {
+		dict, ok := core.GetDict(node)
+		if !ok {
+			return nil
+		}
+		if obj := dict.Get(key); obj != nil {
+			return core.TraceToDirectObject(obj)
+		}
+		node = dict.Get("Parent")
+	}
+	return nil
+}
+
+// normalizedBox returns the box with its lower left corner first
+func normalizedBox(r pdf.PdfRectangle) pdf.PdfRectangle {
+	return pdf.PdfRectangle{
+		Llx: math.Min(r.Llx, r.Urx), Lly: math.Min(r.Lly, r.Ury),
+		Urx: math.Max(r.Llx, r.Urx), Ury: math.Max(r.Lly, r.Ury),
+	}
+}
@@ -0,0 +1,89 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/stretchr/testify/assert"
+	"github.com/unidoc/unipdf/v3/core"
+	pdf "github.com/unidoc/unipdf/v3/model"
+)
+
+func TestGeometry(t *testing.T) {
+	a4 := pdf.PdfRectangle{Urx: 595, Ury: 842}
+	landscape := "landscape"
+	tests := []struct {
+		name    string
+		box     pdf.PdfRectangle
+		rotate  int
+		content archive.Content
+		v6      bool
+		// the corners of the page on the device
+		topLeft, bottomRight [2]float64
+	}{
+		// the A4 page fits the height of the screen and is centered
+		{name: "a4", box: a4, topLeft: [2]float64{40.6, 0}, bottomRight: [2]float64{1363.4, 1872}},
+		// the page wider than the screen fits its width
+		{name: "letter landscape", box: pdf.PdfRectangle{Urx: 792, Ury: 612}, topLeft: [2]float64{0, 0}, bottomRight: [2]float64{1404, 1084.9}},
+		{name: "landscape", box: pdf.PdfRectangle{Urx: 842, Ury: 595}, content: archive.Content{Orientation: landscape},
+			topLeft: [2]float64{0, 0}, bottomRight: [2]float64{1872, 1322.8}},
+		{name: "cropped", box: pdf.PdfRectangle{Llx: 50, Lly: 100, Urx: 545, Ury: 742}, topLeft: [2]float64{0, 0}, bottomRight: [2]float64{1404, 1820.9}},
+		{name: "rotated", box: a4, rotate: 90, topLeft: [2]float64{0, 0}, bottomRight: [2]float64{1404, 992.2}},
+		{name: "upside down", box: a4, rotate: 180, topLeft: [2]float64{40.6, 0}, bottomRight: [2]float64{1363.4, 1872}},
+		{name: "rotated back", box: a4, rotate: 270, topLeft: [2]float64{0, 0}, bottomRight: [2]float64{1404, 992.2}},
+		// the transform zooms the page twice and moves it to the top left
+		{name: "zoomed", box: a4, content: archive.Content{Transform: &archive.Transform{M11: 2, M22: 2, M31: -300, M32: -100, M33: 1}},
+			topLeft: [2]float64{-218.8, -100}, bottomRight: [2]float64{2426.8, 3644}},
+		// the v6 files have the page size of the document
+		{name: "v6", box: a4, content: archive.Content{CustomZoomPageWidth: 1870, CustomZoomPageHeight: 2646}, v6: true,
+			topLeft: [2]float64{-232.9, 0}, bottomRight: [2]float64{1636.9, 2646}},
+		{name: "v5 ignores the page size", box: a4, content: archive.Content{CustomZoomPageWidth: 1870, CustomZoomPageHeight: 2646},
+			topLeft: [2]float64{40.6, 0}, bottomRight: [2]float64{1363.4, 1872}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGeometry(tt.box, tt.rotate, &tt.content, tt.v6)
+			b := tt.box
+			// the corners of the page as it is shown in the coordinates of the PDF
+			topLeft := map[int][2]float64{0: {b.Llx, b.Ury}, 90: {b.Llx, b.Lly}, 180: {b.Urx, b.Lly}, 270: {b.Urx, b.Ury}}[tt.rotate]
+			bottomRight := map[int][2]float64{0: {b.Urx, b.Lly}, 90: {b.Urx, b.Ury}, 180: {b.Llx, b.Ury}, 270: {b.Llx, b.Lly}}[tt.rotate]
+
+			x, y := g.point(tt.topLeft[0], tt.topLeft[1])
+			assert.InDelta(t, topLeft[0], x, 0.1)
+			assert.InDelta(t, topLeft[1], y, 0.1)
+			x, y = g.point(tt.bottomRight[0], tt.bottomRight[1])
+			assert.InDelta(t, bottomRight[0], x, 0.1)
+			assert.InDelta(t, bottomRight[1], y, 0.1)
+		})
+	}
+}
+
+func TestGeometryLength(t *testing.T) {
+	a4 := pdf.PdfRectangle{Urx: 595, Ury: 842}
+	g := newGeometry(a4, 0, &archive.Content{}, false)
+	assert.InDelta(t, 842.0/1872, g.length(1), 1e-9)
+
+	zoomed := newGeometry(a4, 0, &archive.Content{Transform: &archive.Transform{M11: 2, M22: 2, M33: 1}}, false)
+	assert.InDelta(t, 842.0/1872/2, zoomed.length(1), 1e-9)
+}
+
+func TestPageBox(t *testing.T) {
+	page := pdf.NewPdfPage()
+	page.MediaBox = &pdf.PdfRectangle{Urx: 595, Ury: 842}
+	box, err := pageBox(page)
+	assert.NoError(t, err)
+	assert.Equal(t, pdf.PdfRectangle{Urx: 595, Ury: 842}, box)
+
+	// the crop box is kept inside the media box
+	page.CropBox = &pdf.PdfRectangle{Llx: 545, Lly: 100, Urx: 50, Ury: 900}
+	box, err = pageBox(page)
+	assert.NoError(t, err)
+	assert.Equal(t, pdf.PdfRectangle{Llx: 50, Lly: 100, Urx: 545, Ury: 842}, box)
+
+	// the rotation is inherited from the page tree
+	assert.Equal(t, 0, pageRotation(page))
+	parent := core.MakeDict()
+	parent.Set("Rotate", core.MakeInteger(-90))
+	page.Parent = parent
+	assert.Equal(t, 270, pageRotation(page))
+}
@@ -0,0 +1,255 @@
+package annotations
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+	"github.com/unidoc/unipdf/v3/creator"
+	pdf "github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/render"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden images in testfiles/golden")
+
+// A goldenDoc is a PDF with a page annotated on the device. The PDF has a red frame
+// inside its visible box and the annotations a black frame where the red one is shown
+// on the device, so that the frames overlap in the exported PDF.
+type goldenDoc struct {
+	name string
+	// size is the media box of the page, crop its crop box when not nil
+	size   creator.PageSize
+	crop   *pdf.PdfRectangle
+	rotate int64
+	// content has the fields of the .content file of the document
+	content string
+	version rm.Version
+	// left, top, width and height are where the page is shown on the device
+	left, top, width, height float64
+	// inserted adds the annotations to a page inserted after the page of the PDF
+	inserted bool
+	// epub makes the PDF the rendition of an EPUB with a highlight under the top of
+	// the frame
+	epub bool
+}
+
+func TestGoldenPages(t *testing.T) {
+	docs := []goldenDoc{
+		{name: "a5", size: creator.PageSize{420, 595}, left: 41.3, width: 1321.4, height: 1872},
+		{name: "landscape", size: creator.PageSize{842, 595}, content: `"orientation":"landscape",`, width: 1872, height: 1322.8},
+		{name: "landscape-portrait", size: creator.PageSize{842, 595}, width: 1404, height: 992.2},
+		{name: "cropped", size: creator.PageSize{595, 842}, crop: &pdf.PdfRectangle{Llx: 50, Lly: 100, Urx: 545, Ury: 742}, width: 1404, height: 1820.9},
+		{name: "rotated", size: creator.PageSize{595, 842}, rotate: 90, width: 1404, height: 992.2},
+		// zoomed twice, the page is shown from 40.6, 0 to 1363.4, 1872 before the zoom
+		{name: "zoomed", size: creator.PageSize{595, 842}, content: `"transform":{"m11":2,"m22":2,"m31":-300,"m32":-100,"m33":1},`,
+			left: -218.8, top: -100, width: 2645.7, height: 3744},
+		{name: "v6", size: creator.PageSize{595, 842}, content: `"customZoomPageWidth":1870,"customZoomPageHeight":2646,`, version: rm.V6,
+			left: -232.9, width: 1869.8, height: 2646},
+		{name: "inserted", size: creator.PageSize{420, 595}, inserted: true, width: 1404, height: 1872},
+		{name: "epub", size: creator.PageSize{445, 594}, epub: true, left: 1.3, width: 1401.4, height: 1872},
+	}
+	for _, doc := range docs {
+		t.Run(doc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			zipName := filepath.Join(dir, doc.name+".zip")
+			if !assert.NoError(t, os.WriteFile(zipName, goldenZip(t, doc), 0644)) {
+				return
+			}
+			outfile := filepath.Join(dir, doc.name+".pdf")
+			if !assert.NoError(t, CreatePdfGenerator(zipName, outfile, PdfGeneratorOptions{}).Generate()) {
+				return
+			}
+			assertGolden(t, doc.name, renderPDF(t, outfile))
+		})
+	}
+}
+
+// goldenZip returns the archive of the document
+func goldenZip(t *testing.T, doc goldenDoc) []byte {
+	c := creator.New()
+	c.SetPageSize(doc.size)
+	page := c.NewPage()
+	box := pdf.PdfRectangle{Urx: doc.size[0], Ury: doc.size[1]}
+	if doc.crop != nil {
+		page.CropBox = doc.crop
+		box = *doc.crop
+	}
+	if doc.rotate != 0 {
+		page.Rotate = &doc.rotate
+	}
+	w, h := box.Urx-box.Llx, box.Ury-box.Lly
+	frame := c.NewRectangle(box.Llx+w/10, doc.size[1]-box.Ury+h/10, w*8/10, h*8/10)
+	frame.SetBorderColor(creator.ColorRed)
+	frame.SetBorderWidth(2)
+	if err := c.Draw(frame); err != nil {
+		t.Fatal(err)
+	}
+	var pdfData bytes.Buffer
+	if err := c.Write(&pdfData); err != nil {
+		t.Fatal(err)
+	}
+
+	left, top := doc.left+doc.width/10, doc.top+doc.height/10
+	right, bottom := doc.left+doc.width*9/10, doc.top+doc.height*9/10
+	var points []rm.Point
+	for _, p := range [][2]float64{{left, top}, {right, top}, {right, bottom}, {left, bottom}, {left, top}} {
+		points = append(points, rm.Point{X: float32(p[0]), Y: float32(p[1]), Width: 4})
+	}
+	version := doc.version
+	if version == 0 {
+		version = rm.V5
+	}
+	drawing, err := (&rm.Rm{Version: version, Layers: []rm.Layer{{Lines: []rm.Line{
+		{BrushType: rm.FinelinerV5, BrushSize: rm.Medium, Points: points},
+	}}}}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := "0c9f3c4e-3b5e-4a6e-9d5b-6a1f2e3d4c5b"
+	pages := []string{"5a3e6c0d-8f1b-4f43-9c4e-2b7d9f0e1a2c"}
+	redirection := "[0]"
+	if doc.inserted {
+		pages = append(pages, "e4b1d2c3-7a6f-4e5d-8c9b-0a1b2c3d4e5f")
+		redirection = "[0,-1]"
+	}
+
+	fileType := "pdf"
+	if doc.epub {
+		fileType = "epub"
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	add(id+".content", []byte(fmt.Sprintf(`{"fileType":"%s",%s"pages":["%s"],"redirectionPageMap":%s}`,
+		fileType, doc.content, joinIDs(pages), redirection)))
+	add(id+".pdf", pdfData.Bytes())
+	add(id+"/"+pages[len(pages)-1]+".rm", drawing)
+	if doc.epub {
+		add(id+".epub", []byte("epub"))
+		add(id+".highlights/"+pages[0]+".json", []byte(fmt.Sprintf(
+			`{"highlights":[[{"text":"chapter","color":3,"start":0,"length":7,"rects":[{"x":%f,"y":%f,"width":%f,"height":%f}]}]]}`,
+			left, top, right-left, doc.height/20)))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func joinIDs(ids []string) string {
+	s := ids[0]
+	for _, id := range ids[1:] {
+		s += `","` + id
+	}
+	return s
+}
+
+// renderPDF renders the last page of the PDF at a pixel per point, turned by its rotation
+func renderPDF(t *testing.T, name string) image.Image {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := openPdf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := reader.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := reader.GetPage(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := render.NewImageDevice().Render(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < pageRotation(page); i += 90 {
+		img = rotateClockwise(img)
+	}
+	return img
+}
+
+func rotateClockwise(img image.Image) image.Image {
+	b := img.Bounds()
+	rotated := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			rotated.Set(b.Max.Y-1-y, x-b.Min.X, img.At(x, y))
+		}
+	}
+	return rotated
+}
+
+// assertGolden compares the image with testfiles/golden/<name>.png, a few pixels can
+// differ with the antialiasing. The golden image is written with -update.
+func assertGolden(t *testing.T, name string, img image.Image) {
+	golden := filepath.Join("testfiles", "golden", name+".png")
+	if *updateGolden {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(golden)
+	if !assert.NoError(t, err, "run the tests with -update to write the golden images") {
+		return
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Equal(t, want.Bounds().Size(), img.Bounds().Size()) {
+		return
+	}
+
+	differ := 0
+	b, wb := img.Bounds(), want.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if !similar(img.At(b.Min.X+x, b.Min.Y+y), want.At(wb.Min.X+x, wb.Min.Y+y)) {
+				differ++
+			}
+		}
+	}
+	if differ > b.Dx()*b.Dy()/200 {
+		t.Errorf("%d pixels differ from %s", differ, golden)
+		if out, err := os.CreateTemp("", name+"-*.png"); err == nil {
+			png.Encode(out, img)
+			out.Close()
+			t.Logf("the image is in %s", out.Name())
+		}
+	}
+}
+
+func similar(a, b color.Color) bool {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	near := func(x, y uint32) bool { return x-y < 0x2000 || y-x < 0x2000 }
+	return near(r1, r2) && near(g1, g2) && near(b1, b2)
+}
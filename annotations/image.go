@@ -0,0 +1,174 @@
+package annotations
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	pdf "github.com/unidoc/unipdf/v3/model"
+)
+
+// ImageGenerator renders each page of a downloaded document to an image file.
+type ImageGenerator struct {
+	zipName      string
+	outputPrefix string
+	format       string
+	options      ImageGeneratorOptions
+}
+
+// ImageGeneratorOptions are the pages to render and how.
+type ImageGeneratorOptions struct {
+	AllPages        bool
+	AnnotationsOnly bool //export the annotations without the background/pdf
+	// Pages are the numbers of the pages to render starting at 1, annotated or not
+	Pages []int
+	// DPI is the resolution of the PNG images, DeviceDPI if 0
+	DPI float64
+}
+
+// CreateImageGenerator returns a generator of images in the format "svg" or "png",
+// the files are named <outputPrefix>-<page>.<format>.
+func CreateImageGenerator(zipName, outputPrefix, format string, options ImageGeneratorOptions) *ImageGenerator {
+	return &ImageGenerator{zipName: zipName, outputPrefix: outputPrefix, format: format, options: options}
+}
+
+// Generate writes the images and returns the names of the files.
+func (g *ImageGenerator) Generate() ([]string, error) {
+	if g.format != "svg" && g.format != "png" {
+		return nil, fmt.Errorf("unsupported format %s", g.format)
+	}
+
+	zip, err := readZip(g.zipName)
+	if err != nil {
+		return nil, err
+	}
+	var reader *pdf.PdfReader
+	if background := backgroundPdf(zip); background != nil && !g.options.AnnotationsOnly {
+		if reader, err = openPdf(background); err != nil {
+			return nil, err
+		}
+	}
+
+	pages := g.options.Pages
+	if len(pages) == 0 {
+		for i, page := range zip.Pages {
+			// do not render a page when there are no annotations
+			if g.options.AllPages || page.Data != nil {
+				pages = append(pages, i+1)
+			}
+		}
+	}
+
+	var files []string
+	for _, n := range pages {
+		if n < 1 || n > len(zip.Pages) {
+			return nil, fmt.Errorf("invalid page %d, the document has %d pages", n, len(zip.Pages))
+		}
+		page := &zip.Pages[n-1]
+
+		var background *pdf.PdfPage
+		if reader != nil && page.DocPage >= 0 {
+			//1 based, redirected page
+			if background, err = reader.GetPage(page.DocPage + 1); err != nil {
+				return nil, err
+			}
+		}
+
+		name := fmt.Sprintf("%s-%d.%s", g.outputPrefix, n, g.format)
+		if err := g.writeImage(name, page, background); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("the document has no annotated pages")
+	}
+	return files, nil
+}
+
+func (g *ImageGenerator) writeImage(name string, page *archive.Page, background *pdf.PdfPage) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if g.format == "svg" {
+		err = RenderSVG(f, page, background)
+	} else {
+		dpi := g.options.DPI
+		if dpi == 0 {
+			dpi = DeviceDPI
+		}
+		err = RenderPNG(f, page, background, dpi)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readZip reads a downloaded document
+func readZip(name string) (*archive.Zip, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	zip := archive.NewZip()
+	if err := zip.Read(file, fi.Size()); err != nil {
+		return nil, err
+	}
+	return zip, nil
+}
+
+// openPdf opens the PDF of a document, decrypting it if needed
+func openPdf(data []byte) (*pdf.PdfReader, error) {
+	pdfReader, err := pdf.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return pdfReader, nil
+	}
+	if encrypted {
+		valid, err := pdfReader.Decrypt([]byte(""))
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			return nil, fmt.Errorf("cannot decrypt")
+		}
+	}
+	return pdfReader, nil
+}
+
+// deviceSize returns the size of the page in the coordinates of the device, the
+// background page is scaled to fit the device like in the PDF of the annotations
+func deviceSize(background *pdf.PdfPage) (width, height float64, err error) {
+	if background == nil {
+		return float64(rm.Width), float64(rm.Height), nil
+	}
+	mbox, err := background.GetMediaBox()
+	if err != nil {
+		return 0, 0, err
+	}
+	pageWidth, pageHeight := mbox.Urx-mbox.Llx, mbox.Ury-mbox.Lly
+
+	var scale float64
+	if pageHeight/pageWidth < 1.33 {
+		scale = pageWidth / DeviceWidth
+	} else {
+		scale = pageHeight / DeviceHeight
+	}
+	return pageWidth / scale, pageHeight / scale, nil
+}
@@ -1,18 +1,13 @@
 package annotations
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-
-	"os"
+	"math"
 
 	"github.com/juruen/rmapi/archive"
 	"github.com/juruen/rmapi/encoding/rm"
-	"github.com/juruen/rmapi/log"
-	"github.com/unidoc/unipdf/v3/annotator"
 	"github.com/unidoc/unipdf/v3/contentstream"
-	"github.com/unidoc/unipdf/v3/contentstream/draw"
 	"github.com/unidoc/unipdf/v3/core"
 	"github.com/unidoc/unipdf/v3/creator"
 	pdf "github.com/unidoc/unipdf/v3/model"
@@ -25,6 +20,10 @@ const (
 
 var rmPageSize = creator.PageSize{445, 594}
 
+// rounding is the length of the sides of the outlines rounded at their corners, in the
+// coordinates of the device
+const rounding = 4
+
 type PdfGenerator struct {
 	zipName        string
 	outputFilePath string
@@ -37,40 +36,27 @@ type PdfGeneratorOptions struct {
 	AddPageNumbers  bool
 	AllPages        bool
 	AnnotationsOnly bool //export the annotations without the background/pdf
+	// NativeAnnotations adds the strokes, highlights and text as PDF annotations
+	// instead of drawing them on the pages
+	NativeAnnotations bool
 }
 
 func CreatePdfGenerator(zipName, outputFilePath string, options PdfGeneratorOptions) *PdfGenerator {
 	return &PdfGenerator{zipName: zipName, outputFilePath: outputFilePath, options: options}
 }
 
-func normalized(p1 rm.Point, ratioX float64) (float64, float64) {
-	return float64(p1.X) * ratioX, float64(p1.Y) * ratioX
-}
-
 func (p *PdfGenerator) Generate() error {
-	file, err := os.Open(p.zipName)
+	zip, err := readZip(p.zipName)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = file.Close() }()
-
-	zip := archive.NewZip()
 
-	fi, err := file.Stat()
-	if err != nil {
-		return err
+	background := backgroundPdf(zip)
+	if zip.Content.FileType == "epub" && background == nil && !p.options.AnnotationsOnly {
+		return errors.New("the EPUB has no PDF rendition, open it on the device to sync one")
 	}
 
-	err = zip.Read(file, fi.Size())
-	if err != nil {
-		return err
-	}
-
-	if zip.Content.FileType == "epub" {
-		return errors.New("only pdf and notebooks supported")
-	}
-
-	if err = p.initBackgroundPages(zip.Payload); err != nil {
+	if err = p.initBackgroundPages(background); err != nil {
 		return err
 	}
 
@@ -90,90 +76,45 @@ func (p *PdfGenerator) Generate() error {
 	}
 
 	for _, pageAnnotations := range zip.Pages {
-		hasContent := pageAnnotations.Data != nil
+		hasContent := pageAnnotations.Data != nil || len(pageAnnotations.Highlights) > 0
 
 		// do not add a page when there are no annotations
 		if !p.options.AllPages && !hasContent {
 			continue
 		}
-		//1 based, redirected page
-		pageNum := pageAnnotations.DocPage + 1
-
-		page, err := p.addBackgroundPage(c, pageNum)
+		v6 := pageAnnotations.Data != nil && pageAnnotations.Data.Version == rm.V6
+		page, g, err := p.addBackgroundPage(c, pageAnnotations.DocPage, &zip.Content, v6)
 		if err != nil {
 			return err
 		}
-
-		ratio := c.Height() / c.Width()
-
-		var scale float64
-		if ratio < 1.33 {
-			scale = c.Width() / DeviceWidth
-		} else {
-			scale = c.Height() / DeviceHeight
-		}
-		if page == nil {
-			log.Error.Fatal("page is null")
+		if !hasContent {
+			continue
 		}
 
-		if err != nil {
-			return err
+		if page.Resources == nil {
+			page.Resources = pdf.NewPdfPageResources()
 		}
-		if !hasContent {
+		if p.options.NativeAnnotations {
+			if err := addAnnotations(page, &pageAnnotations, g); err != nil {
+				return err
+			}
 			continue
 		}
 
 		contentCreator := contentstream.NewContentCreator()
 		contentCreator.Add_q()
 
-		for _, layer := range pageAnnotations.Data.Layers {
-			for _, line := range layer.Lines {
-				if len(line.Points) < 1 {
-					continue
-				}
-				if line.BrushType == rm.Eraser {
-					continue
-				}
-
-				if line.BrushType == rm.HighlighterV5 {
-					last := len(line.Points) - 1
-					x1, y1 := normalized(line.Points[0], scale)
-					x2, _ := normalized(line.Points[last], scale)
-					// make horizontal lines only, use y1
-					width := scale * 30
-					y1 += width / 2
-
-					lineDef := annotator.LineAnnotationDef{X1: x1 - 1, Y1: c.Height() - y1, X2: x2, Y2: c.Height() - y1}
-					lineDef.LineColor = pdf.NewPdfColorDeviceRGB(1.0, 1.0, 0.0) //yellow
-					lineDef.Opacity = 0.5
-					lineDef.LineWidth = width
-					ann, err := annotator.CreateLineAnnotation(lineDef)
-					if err != nil {
+		for _, h := range pageAnnotations.AllHighlights() {
+			if err := drawHighlight(contentCreator, page.Resources, h, g); err != nil {
+				return err
+			}
+		}
+		if pageAnnotations.Data != nil {
+			for _, layer := range pageAnnotations.Data.Layers {
+				for _, line := range erase(layer.Lines) {
+					if err := drawLine(contentCreator, page.Resources, line, g); err != nil {
 						return err
 					}
-					page.AddAnnotation(ann)
-				} else {
-					path := draw.NewPath()
-					for i := 0; i < len(line.Points); i++ {
-						x1, y1 := normalized(line.Points[i], scale)
-						path = path.AppendPoint(draw.NewPoint(x1, c.Height()-y1))
-					}
-
-					contentCreator.Add_w(float64(line.BrushSize*6.0 - 10.8))
-
-					switch line.BrushColor {
-					case rm.Black:
-						contentCreator.Add_rg(1.0, 1.0, 1.0)
-					case rm.White:
-						contentCreator.Add_rg(0.0, 0.0, 0.0)
-					case rm.Grey:
-						contentCreator.Add_rg(0.8, 0.8, 0.8)
-					}
-
-					//TODO: use bezier
-					draw.DrawPathWithCreator(path, contentCreator)
-
-					contentCreator.Add_S()
 				}
 			}
 		}
@@ -188,28 +129,85 @@ func (p *PdfGenerator) Generate() error {
 	return c.WriteToFile(p.outputFilePath)
 }
 
-func (p *PdfGenerator) initBackgroundPages(pdfArr []byte) error {
-	if len(pdfArr) > 0 {
-		pdfReader, err := pdf.NewPdfReader(bytes.NewReader(pdfArr))
-		if err != nil {
-			return err
-		}
-
-		encrypted, err := pdfReader.IsEncrypted()
-		if err != nil {
-			return nil
-		}
-		if encrypted {
-			valid, err := pdfReader.Decrypt([]byte(""))
+// drawLine fills the outlines of the line on the page of the geometry
+func drawLine(cc *contentstream.ContentCreator, resources *pdf.PdfPageResources, line rm.Line, g geometry) error {
+	pen, ok := penOf(line)
+	if !ok {
+		return nil
+	}
+	for _, o := range pen.outlines(line) {
+		cc.Add_q()
+		if o.opacity < 1 || pen.multiply {
+			name, err := addOpacity(resources, o.opacity, pen.multiply)
 			if err != nil {
 				return err
 			}
-			if !valid {
-				return fmt.Errorf("cannot decrypt")
+			cc.Add_gs(name)
+		}
+		cc.Add_rg(rgb(pen.color))
+
+		// the corners of the outline are rounded with bezier curves, over half of its
+		// sides at most so that the dense points of a stroke make a smooth curve and
+		// the long sides stay straight
+		n := len(o.points)
+		toward := func(from, to [2]float64) (float64, float64) {
+			t := 0.5
+			if length := math.Hypot(to[0]-from[0], to[1]-from[1]); length > 2*rounding {
+				t = rounding / length
 			}
-
+			return g.point(from[0]+(to[0]-from[0])*t, from[1]+(to[1]-from[1])*t)
 		}
+		cc.Add_m(toward(o.points[n-1], o.points[0]))
+		for i, pt := range o.points {
+			prev, next := o.points[(i+n-1)%n], o.points[(i+1)%n]
+			ax, ay := toward(pt, prev)
+			px, py := g.point(pt[0], pt[1])
+			bx, by := toward(pt, next)
+			cc.Add_l(ax, ay)
+			// the quadratic curve as a cubic one
+			cc.Add_c(ax+2*(px-ax)/3, ay+2*(py-ay)/3, bx+2*(px-bx)/3, by+2*(py-by)/3, bx, by)
+		}
+		cc.Add_h()
+		cc.Add_f()
+		cc.Add_Q()
+	}
+	return nil
+}
+
+// addOpacity adds the graphics state of the opacity to the resources and returns its
+// name, multiply darkens the page under the strokes like a highlighter
+func addOpacity(resources *pdf.PdfPageResources, opacity float64, multiply bool) (core.PdfObjectName, error) {
+	name := fmt.Sprintf("RmOpacity%d", int(math.Round(opacity*100)))
+	if multiply {
+		name += "Multiply"
+	}
+	if _, ok := resources.GetExtGState(core.PdfObjectName(name)); ok {
+		return core.PdfObjectName(name), nil
+	}
+	gs := core.MakeDict()
+	gs.Set("ca", core.MakeFloat(opacity))
+	gs.Set("CA", core.MakeFloat(opacity))
+	if multiply {
+		gs.Set("BM", core.MakeName("Multiply"))
+	}
+	return core.PdfObjectName(name), resources.AddExtGState(core.PdfObjectName(name), gs)
+}
 
+// backgroundPdf returns the PDF under the annotations: the PDF of the document or the
+// rendition of an EPUB, nil for the notebooks
+func backgroundPdf(zip *archive.Zip) []byte {
+	if zip.Content.FileType == "epub" {
+		return zip.Rendition
+	}
+	return zip.Payload
+}
+
+func (p *PdfGenerator) initBackgroundPages(pdfArr []byte) error {
+	if len(pdfArr) > 0 {
+		pdfReader, err := openPdf(pdfArr)
+		if err != nil {
+			return err
+		}
 		p.pdfReader = pdfReader
 		p.template = false
 		return nil
@@ -219,29 +217,70 @@ func (p *PdfGenerator) initBackgroundPages(pdfArr []byte) error {
 	return nil
 }
 
-func (p *PdfGenerator) addBackgroundPage(c *creator.Creator, pageNum int) (*pdf.PdfPage, error) {
+// addBackgroundPage adds the page of the document shown by a page of the device,
+// docPage is -1 for the pages inserted on the device, and returns the geometry of the page
+func (p *PdfGenerator) addBackgroundPage(c *creator.Creator, docPage int, content *archive.Content, v6 bool) (*pdf.PdfPage, geometry, error) {
 	var page *pdf.PdfPage
-
-	// if page == 0 then empty page
-	if !p.template && !p.options.AnnotationsOnly && pageNum > 0 {
-		tmpPage, err := p.pdfReader.GetPage(pageNum)
+	var box pdf.PdfRectangle
+	rotate := 0
+
+	switch {
+	case p.template:
+		size := rmPageSize
+		if content.Orientation == "landscape" {
+			size = creator.PageSize{size[1], size[0]}
+		}
+		c.SetPageSize(size)
+		page = c.NewPage()
+		box = pdf.PdfRectangle{Urx: size[0], Ury: size[1]}
+	case docPage < 0:
+		// a blank page as wide as the first page of the document, in the shape of the screen
+		first, err := p.pdfReader.GetPage(1)
 		if err != nil {
-			return nil, err
+			return nil, geometry{}, err
 		}
-		mbox, err := tmpPage.GetMediaBox()
+		firstBox, err := pageBox(first)
 		if err != nil {
-			return nil, err
+			return nil, geometry{}, err
+		}
+		width, _ := geometry{box: firstBox, rotate: pageRotation(first)}.size()
+		height := width * DeviceHeight / DeviceWidth
+		if content.Orientation == "landscape" {
+			height = width * DeviceWidth / DeviceHeight
+		}
+		c.SetPageSize(creator.PageSize{width, height})
+		page = c.NewPage()
+		box = pdf.PdfRectangle{Urx: width, Ury: height}
+	default:
+		tmpPage, err := p.pdfReader.GetPage(docPage + 1)
+		if err != nil {
+			return nil, geometry{}, err
+		}
+		if box, err = pageBox(tmpPage); err != nil {
+			return nil, geometry{}, err
+		}
+		rotate = pageRotation(tmpPage)
+
+		if p.options.AnnotationsOnly {
+			// a blank page of the size of the page as it is shown
+			width, height := geometry{box: box, rotate: rotate}.size()
+			c.SetPageSize(creator.PageSize{width, height})
+			page = c.NewPage()
+			box, rotate = pdf.PdfRectangle{Urx: width, Ury: height}, 0
+			break
 		}
 
-		// TODO: adjust the page if cropped
-		pageHeight := mbox.Ury - mbox.Lly
-		pageWidth := mbox.Urx - mbox.Llx
+		mbox, err := tmpPage.GetMediaBox()
+		if err != nil {
+			return nil, geometry{}, err
+		}
+		// the boxes and the rotation can be inherited from the page tree which isn't copied
+		visible, rotation := box, int64(rotate)
+		tmpPage.MediaBox, tmpPage.CropBox, tmpPage.Rotate = mbox, &visible, &rotation
 		// use the pdf's page size
-		c.SetPageSize(creator.PageSize{pageWidth, pageHeight})
+		c.SetPageSize(creator.PageSize{mbox.Urx - mbox.Llx, mbox.Ury - mbox.Lly})
 		c.AddPage(tmpPage)
 		page = tmpPage
-	} else {
-		page = c.NewPage()
 	}
 
 	if p.options.AddPageNumbers {
@@ -254,5 +293,5 @@ func (p *PdfGenerator) addBackgroundPage(c *creator.Creator, pageNum int) (*pdf.
 			block.Draw(p)
 		})
 	}
-	return page, nil
+	return page, newGeometry(box, rotate, content, v6), nil
 }
@@ -0,0 +1,347 @@
+package annotations
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/unidoc/unipdf/v3/contentstream"
+	"github.com/unidoc/unipdf/v3/core"
+	pdf "github.com/unidoc/unipdf/v3/model"
+)
+
+const (
+	// annotationPrint is the flag of the annotations to print
+	annotationPrint = 4
+	// textSize is the size of the typed text in the coordinates of the device
+	textSize = 32
+	// textFont is the name of the font of the typed text in the appearances
+	textFont = core.PdfObjectName("Helv")
+)
+
+// addAnnotations adds the lines, the highlights and the typed text of the page as
+// annotations of the PDF page of the geometry: ink for the lines, highlight for the
+// highlighters and the highlighted text, free text for the typed text.
+func addAnnotations(page *pdf.PdfPage, rmPage *archive.Page, g geometry) error {
+	var annotations []*pdf.PdfAnnotation
+	if rmPage.Data != nil {
+		for _, layer := range rmPage.Data.Layers {
+			for _, line := range erase(layer.Lines) {
+				annotation, err := lineAnnotation(line, g)
+				if err != nil {
+					return err
+				}
+				if annotation != nil {
+					annotations = append(annotations, annotation)
+				}
+			}
+		}
+	}
+
+	for _, h := range rmPage.AllHighlights() {
+		annotation, err := highlightAnnotation(h, g)
+		if err != nil {
+			return err
+		}
+		if annotation != nil {
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	if rmPage.Data != nil && rmPage.Data.Scene != nil && rmPage.Data.Scene.Text != nil {
+		annotation, err := textAnnotation(rmPage.Data.Scene.Text, g)
+		if err != nil {
+			return err
+		}
+		if annotation != nil {
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	for _, annotation := range annotations {
+		page.AddAnnotation(annotation)
+	}
+	return nil
+}
+
+// lineAnnotation returns the ink annotation of a line, or a highlight annotation for the
+// highlighters, with an appearance drawn like the flattened lines. Nil for the erasers.
+func lineAnnotation(line rm.Line, g geometry) (*pdf.PdfAnnotation, error) {
+	pen, ok := penOf(line)
+	if !ok || len(line.Points) == 0 {
+		return nil, nil
+	}
+
+	bbox := emptyBox()
+	for _, o := range pen.outlines(line) {
+		for _, pt := range o.points {
+			x, y := g.point(pt[0], pt[1])
+			extend(bbox, x, y)
+		}
+	}
+	ap, err := appearance(bbox, func(cc *contentstream.ContentCreator, resources *pdf.PdfPageResources) error {
+		return drawLine(cc, resources, line, g)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	segments := pen.segments(line)
+	if pen.multiply {
+		var quads []float64
+		for _, s := range segments {
+			// the sides of the segment, the upper one first for a segment going right
+			d := unit(s.x2-s.x1, s.y2-s.y1)
+			nx, ny := d[1]*s.width/2, -d[0]*s.width/2
+			quads = append(quads, quad(g, s.x1+nx, s.y1+ny, s.x2+nx, s.y2+ny, s.x1-nx, s.y1-ny, s.x2-nx, s.y2-ny)...)
+		}
+		highlight := pdf.NewPdfAnnotationHighlight()
+		highlight.QuadPoints = core.MakeArrayFromFloats(quads)
+		setAnnotation(highlight.PdfAnnotation, bbox, pen.color, ap)
+		return highlight.PdfAnnotation, nil
+	}
+
+	var path []float64
+	var width float64
+	for _, p := range line.Points {
+		x, y := g.point(float64(p.X), float64(p.Y))
+		path = append(path, x, y)
+	}
+	for _, s := range segments {
+		width += s.width
+	}
+	width /= float64(len(segments))
+
+	ink := pdf.NewPdfAnnotationInk()
+	ink.InkList = core.MakeArray(core.MakeArrayFromFloats(path))
+	bs := pdf.NewBorderStyle()
+	bs.SetBorderWidth(g.length(width))
+	ink.BS = bs.ToPdfObject()
+	setAnnotation(ink.PdfAnnotation, bbox, pen.color, ap)
+	return ink.PdfAnnotation, nil
+}
+
+// highlightAnnotation returns the highlight annotation of a highlighted text with the
+// text as its content, nil when the position of the text is unknown
+func highlightAnnotation(h archive.Highlight, g geometry) (*pdf.PdfAnnotation, error) {
+	if len(h.Rects) == 0 {
+		return nil, nil
+	}
+	quads := highlightQuads(h, g)
+	bbox := emptyBox()
+	for i := 0; i < len(quads); i += 2 {
+		extend(bbox, quads[i], quads[i+1])
+	}
+	ap, err := appearance(bbox, func(cc *contentstream.ContentCreator, resources *pdf.PdfPageResources) error {
+		return drawHighlight(cc, resources, h, g)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	highlight := pdf.NewPdfAnnotationHighlight()
+	highlight.QuadPoints = core.MakeArrayFromFloats(quads)
+	highlight.Contents = core.MakeString(h.Text)
+	setAnnotation(highlight.PdfAnnotation, bbox, highlighter(h).color, ap)
+	return highlight.PdfAnnotation, nil
+}
+
+// drawHighlight fills the rectangles of a highlighted text on the page of the geometry
+// like the highlighter does
+func drawHighlight(cc *contentstream.ContentCreator, resources *pdf.PdfPageResources, h archive.Highlight, g geometry) error {
+	if len(h.Rects) == 0 {
+		return nil
+	}
+	pen := highlighter(h)
+	name, err := addOpacity(resources, pen.opacity(rm.Point{}), true)
+	if err != nil {
+		return err
+	}
+	cc.Add_q()
+	cc.Add_gs(name)
+	cc.Add_rg(rgb(pen.color))
+	quads := highlightQuads(h, g)
+	for i := 0; i < len(quads); i += 8 {
+		// the quadrilateral goes around its corners in the order 0, 1, 3, 2
+		cc.Add_m(quads[i], quads[i+1])
+		cc.Add_l(quads[i+2], quads[i+3])
+		cc.Add_l(quads[i+6], quads[i+7])
+		cc.Add_l(quads[i+4], quads[i+5])
+		cc.Add_h()
+	}
+	cc.Add_f()
+	cc.Add_Q()
+	return nil
+}
+
+// highlighter returns the pen of the highlighter in the color of the highlight
+func highlighter(h archive.Highlight) pen {
+	pen, _ := penOf(rm.Line{BrushType: rm.HighlighterV5, BrushColor: h.Color})
+	return pen
+}
+
+// highlightQuads returns the QuadPoints of the rectangles of a highlighted text
+func highlightQuads(h archive.Highlight, g geometry) []float64 {
+	var quads []float64
+	for _, r := range h.Rects {
+		quads = append(quads, quad(g, r.X, r.Y, r.X+r.W, r.Y, r.X, r.Y+r.H, r.X+r.W, r.Y+r.H)...)
+	}
+	return quads
+}
+
+// textAnnotation returns a free text annotation with the typed text of the page, nil
+// when there is none. The paragraphs are wrapped to the width of the text.
+func textAnnotation(text *rm.Text, g geometry) (*pdf.PdfAnnotation, error) {
+	if strings.TrimSpace(text.String()) == "" {
+		return nil, nil
+	}
+	font, err := pdf.NewStandard14Font(pdf.HelveticaName)
+	if err != nil {
+		return nil, err
+	}
+
+	// the x axis of the text starts in the middle of the page
+	x, y := text.X+float64(rm.Width)/2, text.Y
+	width := float64(text.Width)
+	if width <= 0 {
+		width = float64(rm.Width) - 2*x
+	}
+	leading := textSize * 1.5
+	size := g.length(textSize)
+
+	var lines []string
+	for _, p := range text.Paragraphs {
+		lines = append(lines, wrapText(font, p.String(), size, g.length(width))...)
+	}
+	bottom := y + leading*float64(len(lines))
+	bbox := emptyBox()
+	for _, corner := range [][2]float64{{x, y}, {x + width, y}, {x, bottom}, {x + width, bottom}} {
+		px, py := g.point(corner[0], corner[1])
+		extend(bbox, px, py)
+	}
+
+	// the text goes along the x axis of the device on the pages turned by their rotation
+	ox, oy := g.point(x, y)
+	rx, ry := g.point(x+1, y)
+	dx, dy := g.point(x, y+1)
+	right, up := unit(rx-ox, ry-oy), unit(ox-dx, oy-dy)
+	baseX, baseY := g.point(x, y+textSize)
+
+	ap, err := appearance(bbox, func(cc *contentstream.ContentCreator, resources *pdf.PdfPageResources) error {
+		if err := resources.SetFontByName(textFont, font.ToPdfObject()); err != nil {
+			return err
+		}
+		cc.Add_BT()
+		cc.Add_g(0)
+		cc.Add_Tf(textFont, size)
+		cc.Add_TL(g.length(leading))
+		cc.Add_Tm(right[0], right[1], up[0], up[1], baseX, baseY)
+		for i, line := range lines {
+			if i > 0 {
+				cc.Add_Tstar()
+			}
+			encoded, _ := font.StringToCharcodeBytes(line)
+			cc.Add_Tj(*core.MakeStringFromBytes(encoded))
+		}
+		cc.Add_ET()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	freeText := pdf.NewPdfAnnotationFreeText()
+	freeText.Contents = core.MakeString(text.String())
+	freeText.DA = core.MakeString("/" + string(textFont) + " " + num(size) + " Tf 0 g")
+	setAnnotation(freeText.PdfAnnotation, bbox, color.RGBA{}, ap)
+	// the color would fill the background of the text
+	freeText.PdfAnnotation.C = nil
+	return freeText.PdfAnnotation, nil
+}
+
+// wrapText splits a paragraph into the lines that fit in the width when written with
+// the font of the given size, a paragraph without text is an empty line
+func wrapText(font *pdf.PdfFont, paragraph string, size, width float64) []string {
+	textWidth := func(s string) float64 {
+		var w float64
+		for _, r := range s {
+			if m, ok := font.GetRuneMetrics(r); ok {
+				w += m.Wx
+			}
+		}
+		return w * size / 1000
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(paragraph) {
+		if line != "" && textWidth(line+" "+word) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// quad returns the QuadPoints of four points of the device: the upper side of the
+// quadrilateral from left to right, then its lower side
+func quad(g geometry, points ...float64) []float64 {
+	quad := make([]float64, len(points))
+	for i := 0; i < len(points); i += 2 {
+		quad[i], quad[i+1] = g.point(points[i], points[i+1])
+	}
+	return quad
+}
+
+// appearance returns the appearance dictionary of an annotation drawn by draw in the
+// coordinates of the page, bbox is the area of the annotation. The appearance starts
+// at the corner of the area as some viewers ignore the origin of its bounding box.
+func appearance(bbox *pdf.PdfRectangle, draw func(cc *contentstream.ContentCreator, resources *pdf.PdfPageResources) error) (*core.PdfObjectDictionary, error) {
+	form := pdf.NewXObjectForm()
+	form.Resources = pdf.NewPdfPageResources()
+	cc := contentstream.NewContentCreator()
+	cc.Add_cm(1, 0, 0, 1, -bbox.Llx, -bbox.Lly)
+	if err := draw(cc, form.Resources); err != nil {
+		return nil, err
+	}
+	if err := form.SetContentStream(cc.Bytes(), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	form.BBox = core.MakeArrayFromFloats([]float64{0, 0, bbox.Urx - bbox.Llx, bbox.Ury - bbox.Lly})
+
+	ap := core.MakeDict()
+	ap.Set("N", form.ToPdfObject())
+	return ap, nil
+}
+
+// setAnnotation sets the entries common to the annotations
+func setAnnotation(a *pdf.PdfAnnotation, bbox *pdf.PdfRectangle, c color.RGBA, ap *core.PdfObjectDictionary) {
+	a.Rect = bbox.ToPdfObject()
+	r, g, b := rgb(c)
+	a.C = core.MakeArrayFromFloats([]float64{r, g, b})
+	a.F = core.MakeInteger(annotationPrint)
+	a.AP = ap
+}
+
+// rgb returns the components of the color between 0 and 1
+func rgb(c color.RGBA) (float64, float64, float64) {
+	return float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255
+}
+
+// emptyBox returns a bounding box to extend with points
+func emptyBox() *pdf.PdfRectangle {
+	return &pdf.PdfRectangle{Llx: math.Inf(1), Lly: math.Inf(1), Urx: math.Inf(-1), Ury: math.Inf(-1)}
+}
+
+// extend grows the bounding box to contain the point
+func extend(r *pdf.PdfRectangle, x, y float64) {
+	r.Llx, r.Lly = math.Min(r.Llx, x), math.Min(r.Lly, y)
+	r.Urx, r.Ury = math.Max(r.Urx, x), math.Max(r.Ury, y)
+}
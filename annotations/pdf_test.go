@@ -1,8 +1,19 @@
 package annotations
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+	"github.com/unidoc/unipdf/v3/core"
+	"github.com/unidoc/unipdf/v3/creator"
+	pdf "github.com/unidoc/unipdf/v3/model"
 )
 
 func test(name string, t *testing.T) {
@@ -38,3 +49,134 @@ func TestGenerateTmpl(t *testing.T) {
 func TestGenerateStrangeBug(t *testing.T) {
 	test("strange", t)
 }
+
+// floats returns the numbers of a PDF array
+func floats(t *testing.T, obj core.PdfObject) []float64 {
+	array, ok := core.GetArray(obj)
+	if !assert.True(t, ok) {
+		return nil
+	}
+	values, err := array.ToFloat64Array()
+	assert.NoError(t, err)
+	return values
+}
+
+func TestAddAnnotations(t *testing.T) {
+	page := &archive.Page{
+		Data: &rm.Rm{
+			Layers: []rm.Layer{{Lines: []rm.Line{
+				{BrushType: rm.BallPointV5, Points: []rm.Point{{X: 100, Y: 100, Width: 4, Pressure: 1}, {X: 200, Y: 150, Width: 4, Pressure: 1}}},
+				{BrushType: rm.HighlighterV5, BrushColor: rm.Yellow, Points: []rm.Point{{X: 100, Y: 300, Width: 30}, {X: 400, Y: 300, Width: 30}}},
+				{BrushType: rm.EraseArea, Points: []rm.Point{{X: 0, Y: 500}, {X: 50, Y: 500}, {X: 50, Y: 550}}},
+			}}},
+			Scene: &rm.Scene{Text: &rm.Text{X: -468, Y: 234, Width: 936, Paragraphs: []rm.Paragraph{
+				{Spans: []rm.Span{{Text: "Agreed "}, {Text: "budget", Bold: true}}},
+			}}},
+		},
+		Highlights: []archive.Highlight{
+			{Text: "quoted", Color: rm.Green, Rects: []rm.Rect{{X: 10, Y: 20, W: 100, H: 30}}},
+			{Text: "nowhere"},
+		},
+	}
+	pdfPage := pdf.NewPdfPage()
+	assert.NoError(t, addAnnotations(pdfPage, page, newGeometry(pdf.PdfRectangle{Urx: 702, Ury: 936}, 0, &archive.Content{}, false)))
+
+	annotations, err := pdfPage.GetAnnotations()
+	assert.NoError(t, err)
+	if !assert.Len(t, annotations, 4) {
+		return
+	}
+
+	ink, ok := annotations[0].GetContext().(*pdf.PdfAnnotationInk)
+	if assert.True(t, ok) {
+		paths, _ := core.GetArray(ink.InkList)
+		assert.Equal(t, []float64{50, 886, 100, 861}, floats(t, paths.Get(0)))
+		assert.NotNil(t, ink.AP)
+	}
+
+	stroke, ok := annotations[1].GetContext().(*pdf.PdfAnnotationHighlight)
+	if assert.True(t, ok) {
+		assert.Equal(t, []float64{50, 793.5, 200, 793.5, 50, 778.5, 200, 778.5}, floats(t, stroke.QuadPoints))
+	}
+
+	text, ok := annotations[2].GetContext().(*pdf.PdfAnnotationHighlight)
+	if assert.True(t, ok) {
+		assert.Equal(t, "quoted", text.Contents.(*core.PdfObjectString).Str())
+		assert.Equal(t, []float64{5, 926, 55, 926, 5, 911, 55, 911}, floats(t, text.QuadPoints))
+		assert.InDeltaSlice(t, []float64{0, 0.62, 0.29}, floats(t, text.C), 0.01)
+	}
+
+	freeText, ok := annotations[3].GetContext().(*pdf.PdfAnnotationFreeText)
+	if assert.True(t, ok) {
+		assert.Equal(t, "Agreed budget", freeText.Contents.(*core.PdfObjectString).Str())
+		assert.Equal(t, []float64{117, 795, 585, 819}, floats(t, freeText.Rect))
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	font, err := pdf.NewStandard14Font(pdf.HelveticaName)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"one two", "three"}, wrapText(font, "one two three", 10, 50))
+	assert.Equal(t, []string{""}, wrapText(font, "", 10, 50))
+}
+
+func TestGenerateNativeAnnotations(t *testing.T) {
+	outfile := filepath.Join(t.TempDir(), "a4.pdf")
+	options := PdfGeneratorOptions{NativeAnnotations: true}
+	if !assert.NoError(t, CreatePdfGenerator("testfiles/a4.zip", outfile, options).Generate()) {
+		return
+	}
+
+	data, err := os.ReadFile(outfile)
+	assert.NoError(t, err)
+	reader, err := openPdf(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	page, err := reader.GetPage(1)
+	assert.NoError(t, err)
+	annotations, err := page.GetAnnotations()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, annotations)
+	for _, a := range annotations {
+		assert.IsType(t, &pdf.PdfAnnotationInk{}, a.GetContext())
+	}
+}
+
+func TestGenerateEpubWithoutRendition(t *testing.T) {
+	// the EPUB as it is before the device opens it
+	var buf bytes.Buffer
+	data := goldenZip(t, goldenDoc{name: "epub", size: creator.PageSize{445, 594}, epub: true, width: 1404, height: 1872})
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		if filepath.Ext(f.Name) == ".pdf" {
+			continue
+		}
+		assert.NoError(t, zw.Copy(f))
+	}
+	assert.NoError(t, zw.Close())
+
+	dir := t.TempDir()
+	zipName := filepath.Join(dir, "epub.zip")
+	assert.NoError(t, os.WriteFile(zipName, buf.Bytes(), 0644))
+	outfile := filepath.Join(dir, "epub.pdf")
+	assert.Error(t, CreatePdfGenerator(zipName, outfile, PdfGeneratorOptions{}).Generate())
+
+	// the annotations alone are on pages of the size of the screen
+	if !assert.NoError(t, CreatePdfGenerator(zipName, outfile, PdfGeneratorOptions{AnnotationsOnly: true}).Generate()) {
+		return
+	}
+	pdfData, err := os.ReadFile(outfile)
+	assert.NoError(t, err)
+	reader, err := openPdf(pdfData)
+	if !assert.NoError(t, err) {
+		return
+	}
+	count, err := reader.GetNumPages()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
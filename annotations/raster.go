@@ -0,0 +1,152 @@
+package annotations
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/nfnt/resize"
+	pdf "github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/render"
+	"golang.org/x/image/vector"
+)
+
+// DeviceDPI is the resolution of the screen of the device.
+const DeviceDPI = 226
+
+// RenderPNG writes the page as a PNG image at dpi, see RenderImage.
+func RenderPNG(w io.Writer, page *archive.Page, background *pdf.PdfPage, dpi float64) error {
+	img, err := RenderImage(page, background, dpi)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// RenderImage draws the strokes of the page with antialiasing at dpi, the resolution of
+// the device is DeviceDPI. background is the page of the PDF under the strokes, the
+// image is white if there is none.
+func RenderImage(page *archive.Page, background *pdf.PdfPage, dpi float64) (*image.RGBA, error) {
+	width, height, err := deviceSize(background)
+	if err != nil {
+		return nil, err
+	}
+	scale := dpi / DeviceDPI
+	bounds := image.Rect(0, 0, int(math.Ceil(width*scale)), int(math.Ceil(height*scale)))
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, image.White, image.Point{}, draw.Src)
+
+	if background != nil {
+		bg, err := render.NewImageDevice().Render(background)
+		if err != nil {
+			return nil, err
+		}
+		bg = resize.Resize(uint(bounds.Dx()), uint(bounds.Dy()), bg, resize.Bilinear)
+		draw.Draw(img, bounds, bg, bg.Bounds().Min, draw.Over)
+	}
+
+	if page.Data == nil {
+		return img, nil
+	}
+	r := &rasterizer{scale: scale}
+	for _, layer := range page.Data.Layers {
+		for _, line := range erase(layer.Lines) {
+			r.drawLine(img, line)
+		}
+	}
+	return img, nil
+}
+
+// rasterizer draws the lines of the device on an image scaled by scale
+type rasterizer struct {
+	scale float64
+	// z covers the bounding box of the run being drawn, which starts at origin
+	z      *vector.Rasterizer
+	origin image.Point
+}
+
+func (r *rasterizer) drawLine(img *image.RGBA, line rm.Line) {
+	pen, ok := penOf(line)
+	if !ok {
+		return
+	}
+	// the runs are drawn at once, their overlapping parts aren't darker
+	for _, run := range runs(pen.segments(line)) {
+		box := r.bounds(run).Intersect(img.Bounds())
+		if box.Empty() {
+			continue
+		}
+		r.z = vector.NewRasterizer(box.Dx(), box.Dy())
+		r.origin = box.Min
+		for _, s := range run {
+			r.segment(s, pen.square)
+		}
+		c := pen.color
+		c.A = uint8(math.Round(run[0].opacity * 255))
+		r.z.Draw(img, box, image.NewUniform(color.NRGBA(c)), image.Point{})
+	}
+}
+
+// bounds returns the pixels covered by the segments
+func (r *rasterizer) bounds(run []segment) image.Rectangle {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, s := range run {
+		// the caps may go as far as the width
+		for _, p := range [][2]float64{{s.x1, s.y1}, {s.x2, s.y2}} {
+			minX, maxX = math.Min(minX, p[0]-s.width), math.Max(maxX, p[0]+s.width)
+			minY, maxY = math.Min(minY, p[1]-s.width), math.Max(maxY, p[1]+s.width)
+		}
+	}
+	return image.Rect(int(math.Floor(minX*r.scale)), int(math.Floor(minY*r.scale)),
+		int(math.Ceil(maxX*r.scale))+1, int(math.Ceil(maxY*r.scale))+1)
+}
+
+// segment adds a segment with its caps, the shapes all turn in the same direction
+// so that they add up
+func (r *rasterizer) segment(s segment, square bool) {
+	x1, y1, x2, y2 := s.x1*r.scale, s.y1*r.scale, s.x2*r.scale, s.y2*r.scale
+	half := s.width * r.scale / 2
+	dx, dy := x2-x1, y2-y1
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		dx, dy, length = 1, 0, 1
+	}
+	// unit vectors along the segment and normal to it
+	ux, uy := dx/length, dy/length
+	nx, ny := -uy*half, ux*half
+
+	if square {
+		// the caps extend the segment
+		x1, y1 = x1-ux*half, y1-uy*half
+		x2, y2 = x2+ux*half, y2+uy*half
+	} else {
+		r.circle(x1, y1, half)
+		r.circle(x2, y2, half)
+	}
+	r.polygon(x1-nx, y1-ny, x2-nx, y2-ny, x2+nx, y2+ny, x1+nx, y1+ny)
+}
+
+func (r *rasterizer) circle(x, y, radius float64) {
+	n := int(math.Min(math.Max(radius*2, 8), 64))
+	points := make([]float64, 0, 2*n)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		points = append(points, x+radius*math.Cos(a), y+radius*math.Sin(a))
+	}
+	r.polygon(points...)
+}
+
+// polygon adds the polygon of the points x1, y1, x2, y2...
+func (r *rasterizer) polygon(points ...float64) {
+	x, y := float64(r.origin.X), float64(r.origin.Y)
+	r.z.MoveTo(float32(points[0]-x), float32(points[1]-y))
+	for i := 2; i < len(points); i += 2 {
+		r.z.LineTo(float32(points[i]-x), float32(points[i+1]-y))
+	}
+	r.z.ClosePath()
+}
@@ -0,0 +1,69 @@
+package annotations
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderImage(t *testing.T) {
+	page := &archive.Page{Data: &rm.Rm{Layers: []rm.Layer{{Lines: []rm.Line{
+		{BrushType: rm.FinelinerV5, Points: []rm.Point{{X: 100, Y: 100, Width: 9}, {X: 300, Y: 100, Width: 9}}},
+		// the highlighter goes back over itself
+		{BrushType: rm.HighlighterV5, Points: []rm.Point{{X: 100, Y: 500, Width: 30}, {X: 300, Y: 500, Width: 30}, {X: 200, Y: 500, Width: 30}}},
+		{BrushType: rm.Eraser, Points: []rm.Point{{X: 100, Y: 800, Width: 30}, {X: 300, Y: 800, Width: 30}}},
+	}}}}}
+
+	img, err := RenderImage(page, nil, DeviceDPI)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, image.Rect(0, 0, rm.Width, rm.Height), img.Bounds())
+
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, img.RGBAAt(200, 100))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(200, 120))
+	// the edges are antialiased
+	edge := img.RGBAAt(200, 104)
+	assert.True(t, edge.R > 0 && edge.R < 255, "edge %v", edge)
+
+	// the highlight has the same color where it overlaps
+	assert.Equal(t, img.RGBAAt(150, 500), img.RGBAAt(250, 500))
+	assert.NotEqual(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(250, 500))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.RGBAAt(200, 800))
+
+	small, err := RenderImage(page, nil, DeviceDPI/2)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, rm.Width/2, rm.Height/2), small.Bounds())
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, small.RGBAAt(100, 50))
+}
+
+func TestGeneratePNG(t *testing.T) {
+	dir := t.TempDir()
+	options := ImageGeneratorOptions{DPI: 72, Pages: []int{1}}
+	files, err := CreateImageGenerator("testfiles/a4.zip", filepath.Join(dir, "a4"), "png", options).Generate()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{filepath.Join(dir, "a4-1.png")}, files)
+
+	f, err := os.Open(files[0])
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	assert.NoError(t, err)
+	// the A4 page fits the height of the device
+	assert.Equal(t, image.Rect(0, 0, 422, 597), img.Bounds())
+
+	options.Pages = []int{2}
+	_, err = CreateImageGenerator("testfiles/a4.zip", filepath.Join(dir, "a4"), "png", options).Generate()
+	assert.Error(t, err)
+}
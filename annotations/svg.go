@@ -0,0 +1,129 @@
+package annotations
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	pdf "github.com/unidoc/unipdf/v3/model"
+	"github.com/unidoc/unipdf/v3/render"
+)
+
+// RenderSVG writes the page as a standalone SVG document in the coordinates of the device,
+// the layers are groups which Inkscape shows as layers. background is the page of the
+// PDF under the strokes, it is embedded as an image; nil if there is none.
+func RenderSVG(w io.Writer, page *archive.Page, background *pdf.PdfPage) error {
+	width, height, err := deviceSize(background)
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" `+
+		`xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		num(width), num(height), num(width), num(height))
+
+	if background != nil {
+		img, err := render.NewImageDevice().Render(background)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		fmt.Fprintf(b, `<image x="0" y="0" width="%s" height="%s" preserveAspectRatio="none" xlink:href="data:image/png;base64,%s"/>`+"\n",
+			num(width), num(height), base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+
+	if page.Data != nil {
+		names := layerNames(page)
+		for i, layer := range page.Data.Layers {
+			fmt.Fprintf(b, `<g id="layer%d" inkscape:groupmode="layer" inkscape:label="%s">`+"\n", i+1, escape(names[i]))
+			for _, line := range erase(layer.Lines) {
+				writeSVGLine(b, line)
+			}
+			fmt.Fprintln(b, "</g>")
+		}
+	}
+	fmt.Fprintln(b, "</svg>")
+	return b.Flush()
+}
+
+// writeSVGLine writes a line as polylines, the consecutive segments with the same
+// width and opacity are joined
+func writeSVGLine(w io.Writer, line rm.Line) {
+	pen, ok := penOf(line)
+	if !ok {
+		return
+	}
+	cap, join := "round", "round"
+	if pen.square {
+		cap, join = "square", "bevel"
+	}
+
+	for _, run := range runs(pen.segments(line)) {
+		s := run[0]
+		var points bytes.Buffer
+		fmt.Fprintf(&points, "%s,%s", num(s.x1), num(s.y1))
+		for _, s := range run {
+			fmt.Fprintf(&points, " %s,%s", num(s.x2), num(s.y2))
+		}
+		fmt.Fprintf(w, `<polyline fill="none" stroke="%s" stroke-width="%s" stroke-linecap="%s" stroke-linejoin="%s"`,
+			hex(pen.color), num(s.width), cap, join)
+		if s.opacity < 1 {
+			fmt.Fprintf(w, ` stroke-opacity="%s"`, num(s.opacity))
+		}
+		fmt.Fprintf(w, ` points="%s"/>`+"\n", points.String())
+	}
+}
+
+// layerNames returns the names of the layers of the page, from the scene of a v6 page
+// or from the metadata of the older ones
+func layerNames(page *archive.Page) []string {
+	names := make([]string, len(page.Data.Layers))
+	var known []string
+	if page.Data.Scene != nil {
+		for _, g := range page.Data.Scene.Layers() {
+			if g.Visible {
+				known = append(known, g.Label)
+			}
+		}
+	} else {
+		for _, l := range page.Metadata.Layers {
+			known = append(known, l.Name)
+		}
+	}
+	for i := range names {
+		if i < len(known) && known[i] != "" {
+			names[i] = known[i]
+		} else {
+			names[i] = fmt.Sprintf("Layer %d", i+1)
+		}
+	}
+	return names
+}
+
+// num formats a coordinate, two decimals are enough for the device
+func num(f float64) string {
+	return fmt.Sprintf("%.2f", f)
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	// writing to a buffer can't fail
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package annotations
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+)
+
+// svgElements returns the number of elements of each name, failing when the document isn't valid
+func svgElements(t *testing.T, data []byte) map[string]int {
+	elements := make(map[string]int)
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return elements
+		}
+		if !assert.NoError(t, err) {
+			return elements
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			elements[start.Name.Local]++
+		}
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	page := &archive.Page{Data: &rm.Rm{Layers: []rm.Layer{
+		{Lines: []rm.Line{
+			{BrushType: rm.FinelinerV5, Points: []rm.Point{{X: 10, Y: 10, Width: 2}, {X: 20, Y: 20, Width: 2}, {X: 30, Y: 10, Width: 2}}},
+			{BrushType: rm.Eraser, Points: []rm.Point{{X: 100, Y: 100}, {X: 200, Y: 200}}},
+		}},
+		{Lines: []rm.Line{
+			{BrushType: rm.BallPointV5, BrushColor: rm.Blue, Points: []rm.Point{{X: 1, Y: 1, Width: 4, Pressure: 0}, {X: 2, Y: 2, Width: 4, Pressure: 1}, {X: 3, Y: 3, Width: 4, Pressure: 1}}},
+			{BrushType: rm.HighlighterV5, Points: []rm.Point{{X: 5, Y: 5, Width: 30}, {X: 50, Y: 5, Width: 30}}},
+		}},
+	}}}
+	page.Metadata.Layers = []archive.Layer{{Name: "Sketch"}}
+
+	var out bytes.Buffer
+	assert.NoError(t, RenderSVG(&out, page, nil))
+	svg := out.String()
+
+	elements := svgElements(t, out.Bytes())
+	assert.Equal(t, 2, elements["g"])
+	// the fineliner, the highlighter and the ballpoint in a polyline per width
+	assert.Equal(t, 3, elements["polyline"])
+	assert.Equal(t, 0, elements["image"])
+
+	assert.Contains(t, svg, `viewBox="0 0 1404.00 1872.00"`)
+	assert.Contains(t, svg, `inkscape:label="Sketch"`)
+	assert.Contains(t, svg, `inkscape:label="Layer 2"`)
+	assert.Contains(t, svg, `stroke="#000000" stroke-width="2.00" stroke-linecap="round" stroke-linejoin="round" points="10.00,10.00 20.00,20.00 30.00,10.00"`)
+	assert.Contains(t, svg, `stroke="#4e69c9" stroke-width="4.00"`)
+	assert.Contains(t, svg, `stroke="#ffed75" stroke-width="30.00" stroke-linecap="square" stroke-linejoin="bevel" stroke-opacity="0.35"`)
+}
+
+func TestPenSegments(t *testing.T) {
+	points := []rm.Point{{Width: 10, Pressure: 0}, {Width: 10, Pressure: 0.5}, {Width: 10, Pressure: 1}}
+
+	pen, ok := penOf(rm.Line{BrushType: rm.BrushV5})
+	assert.True(t, ok)
+	segments := pen.segments(rm.Line{Points: points})
+	assert.Len(t, segments, 2)
+	assert.InDelta(t, 8, segments[0].width, 1e-6)
+	assert.InDelta(t, 12, segments[1].width, 1e-6)
+
+	pen, _ = penOf(rm.Line{BrushType: rm.TiltPencilV5})
+	segments = pen.segments(rm.Line{Points: points})
+	assert.InDelta(t, 0.6, segments[0].opacity, 1e-6)
+	assert.InDelta(t, 0.9, segments[1].opacity, 1e-6)
+
+	pen, _ = penOf(rm.Line{BrushType: rm.FinelinerV5})
+	segments = pen.segments(rm.Line{Points: []rm.Point{{Width: 2}, {Width: 4}, {Width: 0}}})
+	assert.Equal(t, segments[0].width, segments[1].width)
+	assert.InDelta(t, 2.25, segments[0].width, 1e-6)
+
+	// a dot
+	assert.Len(t, pen.segments(rm.Line{Points: points[:1]}), 1)
+
+	_, ok = penOf(rm.Line{BrushType: rm.EraseArea})
+	assert.False(t, ok)
+}
+
+func TestGenerateSVG(t *testing.T) {
+	dir := t.TempDir()
+	for _, options := range []ImageGeneratorOptions{{}, {AnnotationsOnly: true}} {
+		generator := CreateImageGenerator("testfiles/a4.zip", filepath.Join(dir, "a4"), "svg", options)
+		files, err := generator.Generate()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{filepath.Join(dir, "a4-1.svg")}, files)
+
+		data, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+		elements := svgElements(t, data)
+		assert.NotZero(t, elements["polyline"])
+		if options.AnnotationsOnly {
+			assert.Equal(t, 0, elements["image"])
+		} else {
+			assert.Equal(t, 1, elements["image"])
+			assert.True(t, strings.Contains(string(data), "data:image/png;base64,"))
+		}
+	}
+
+	_, err := CreateImageGenerator("testfiles/a4.zip", filepath.Join(dir, "a4"), "bmp", ImageGeneratorOptions{}).Generate()
+	assert.Error(t, err)
+}
@@ -3,12 +3,14 @@ package api
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/juruen/rmapi/api/sync15"
+	"github.com/juruen/rmapi/archive"
 	"github.com/juruen/rmapi/filetree"
 	"github.com/juruen/rmapi/model"
 	"github.com/juruen/rmapi/transport"
@@ -17,11 +19,26 @@ import (
 type ApiCtx interface {
 	Filetree() *filetree.FileTreeCtx
 	FetchDocument(docId, dstPath string) error
+	FetchDocumentTo(docId string, w io.Writer) error
+	DocumentContent(docId string) (*archive.Content, error)
+	DocumentTemplate(docId string) (*archive.Template, error)
+	DocumentPages(docId string) ([]archive.PageInfo, error)
+	UpdatePages(docId string, update func(pages []archive.PageInfo) ([]archive.PageInfo, error)) (*model.Document, error)
+	MergeDocuments(docIds []string, parentId, name string) (*model.Document, error)
+	SplitDocument(docId string, at []int, names []string) ([]*model.Document, error)
+	UpdateContent(docIds []string, update func(docId string, content *archive.Content) error) ([]*model.Document, error)
+	UploadTemplate(template *archive.Template, ext string, r io.Reader, notify bool) (*model.Document, error)
 	CreateDir(parentId, name string, notify bool) (*model.Document, error)
-	UploadDocument(parentId string, sourceDocPath string, notify bool, coverpage *int) (*model.Document, error)
-	ReplaceDocumentFile(docId, sourceDocPath string, notify bool) error
+	UploadDocument(parentId string, sourceDocPath string, notify bool, layout *archive.Layout) (*model.Document, error)
+	UploadDocumentFrom(parentId, name, ext string, r io.Reader, notify bool) (*model.Document, error)
+	ReplaceDocumentFile(docId, sourceDocPath string, notify bool, layout *archive.Layout) error
 	MoveEntry(src, dstDir *model.Node, name string) (*model.Node, error)
+	CopyEntry(src, dstDir *model.Node, name string, recursive bool) ([]*model.Document, error)
+	CopyEntries(srcs []*model.Node, dstDir *model.Node, recursive bool) ([]*model.Document, error)
+	UpdateMetadata(docId string, update func(metadata *archive.MetadataFile) error) (*model.Document, error)
+	UpdateDocumentsMetadata(docIds []string, update func(docId string, metadata *archive.MetadataFile) error) ([]*model.Document, error)
 	DeleteEntry(node *model.Node, recursive, notify bool) error
+	DeleteEntries(nodes []*model.Node, recursive, notify bool) error
 	SyncComplete() error
 	Nuke() error
 	Refresh() (string, int64, error)
@@ -85,6 +102,44 @@ func ParseToken(userToken string) (token *UserInfo, err error) {
 	return token, nil
 }
 
+// TokenStatus describes the claims of a user token
+type TokenStatus struct {
+	User        string
+	Scopes      []string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+	Expired     bool
+	SyncVersion SyncVersion
+}
+
+// ParseTokenStatus decodes a user token without verifying it, expired tokens are not an error
+func ParseTokenStatus(userToken string) (*TokenStatus, error) {
+	claims := UserToken{}
+	_, _, err := (&jwt.Parser{}).ParseUnverified(userToken, &claims)
+
+	if err != nil {
+		return nil, fmt.Errorf("can't parse token %v", err)
+	}
+
+	status := &TokenStatus{
+		User:        claims.Auth0.Email,
+		Scopes:      strings.Fields(claims.Scopes),
+		SyncVersion: Version15,
+	}
+
+	if claims.StandardClaims != nil {
+		if claims.IssuedAt != 0 {
+			status.IssuedAt = time.Unix(claims.IssuedAt, 0)
+		}
+		if claims.ExpiresAt != 0 {
+			status.ExpiresAt = time.Unix(claims.ExpiresAt, 0)
+		}
+		status.Expired = !claims.VerifyExpiresAt(time.Now().Unix(), false)
+	}
+
+	return status, nil
+}
+
 // CreateApiCtx initializes an instance of ApiCtx
 func CreateApiCtx(httpCtx *transport.HttpClientCtx, syncVerison SyncVersion) (ctx ApiCtx, err error) {
 	switch syncVerison {
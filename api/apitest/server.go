@@ -0,0 +1,210 @@
+// Package apitest provides an in-memory reMarkable cloud for testing code built on api.ApiCtx.
+//
+// A Server keeps the blobs and the root index of the sync 1.5 protocol in memory
+// and serves them without any network, an ApiCtx created with NewApiCtx is a regular
+// sync15.ApiCtx talking to it:
+//
+//	srv := apitest.NewServer()
+//	ctx, err := srv.NewApiCtx()
+//	doc, err := ctx.CreateDir("", "books", false)
+//
+// Faults can be injected to test the error handling of callers, see
+// FailRootWrites, SetUnauthorized and RemoveBlobs.
+package apitest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/juruen/rmapi/api"
+	"github.com/juruen/rmapi/api/sync15"
+	"github.com/juruen/rmapi/config"
+	"github.com/juruen/rmapi/model"
+	"github.com/juruen/rmapi/transport"
+)
+
+// Host is the url under which a Server is reachable from its HttpClientCtx
+const Host = "http://rmapi.test"
+
+var _ api.ApiCtx = (*sync15.ApiCtx)(nil)
+
+// Server is an in-memory implementation of the sync 1.5 storage
+type Server struct {
+	mu         sync.Mutex
+	blobs      map[string][]byte
+	hash       string
+	generation int64
+	blobReads  int
+	blobWrites int
+
+	rootConflicts int
+	unauthorized  bool
+	missing       func(name string) bool
+}
+
+// NewServer creates an empty cloud
+func NewServer() *Server {
+	return &Server{blobs: map[string][]byte{}}
+}
+
+// Client returns an http client which sends every request to s
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s}
+}
+
+// HttpClientCtx returns an authenticated http context for s
+func (s *Server) HttpClientCtx() *transport.HttpClientCtx {
+	return &transport.HttpClientCtx{
+		Client: s.Client(),
+		Tokens: model.AuthTokens{DeviceToken: "device", UserToken: "user"},
+	}
+}
+
+// NewApiCtx creates an ApiCtx mirroring s, the tree is not cached on disk
+func (s *Server) NewApiCtx() (*sync15.ApiCtx, error) {
+	http := s.HttpClientCtx()
+	return sync15.NewCtx(http, sync15.NewBlobStorageForHost(http, Host), "")
+}
+
+// FailRootWrites makes the next n root index updates fail with a generation conflict,
+// as if another client had synced in between
+func (s *Server) FailRootWrites(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rootConflicts = n
+}
+
+// SetUnauthorized makes every request fail with 401 Unauthorized until reset
+func (s *Server) SetUnauthorized(unauthorized bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unauthorized = unauthorized
+}
+
+// RemoveBlobs makes the blobs, whose file name (e.g. "<id>.metadata") matches, missing.
+// A nil match restores them.
+func (s *Server) RemoveBlobs(match func(name string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.missing = match
+}
+
+// Generation returns the current generation of the root index
+func (s *Server) Generation() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation
+}
+
+// BlobCount returns the number of stored blobs
+func (s *Server) BlobCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.blobs)
+}
+
+// BlobReads returns the number of blob downloads so far
+func (s *Server) BlobReads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blobReads
+}
+
+// BlobWrites returns the number of blob uploads so far
+func (s *Server) BlobWrites() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blobWrites
+}
+
+// RoundTrip serves the request in memory
+func (s *Server) RoundTrip(r *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	resp := w.Result()
+	resp.Request = r
+	return resp, nil
+}
+
+// ServeHTTP implements the sync 1.5 endpoints, a Server can also be used with httptest.NewServer
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unauthorized {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == config.RootGetPath && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(model.BlobRootStorageResponse{Hash: s.hash, Generation: s.generation})
+	case r.URL.Path == config.RootPutPath && r.Method == http.MethodPut:
+		s.putRoot(w, r)
+	case strings.HasPrefix(r.URL.Path, config.BlobPath):
+		hash := strings.TrimPrefix(r.URL.Path, config.BlobPath)
+		switch r.Method {
+		case http.MethodGet:
+			s.blobReads++
+			blob, ok := s.blobs[hash]
+			if !ok || (s.missing != nil && s.missing(fileName(r))) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(blob)
+		case http.MethodPut:
+			blob, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.blobWrites++
+			s.blobs[hash] = blob
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) putRoot(w http.ResponseWriter, r *http.Request) {
+	var req model.BlobRootStorageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if s.rootConflicts > 0 {
+		s.rootConflicts--
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if req.Generation != s.generation {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if _, ok := s.blobs[req.Hash]; !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.hash = req.Hash
+	s.generation++
+	json.NewEncoder(w).Encode(model.BlobRootStorageResponse{Hash: s.hash, Generation: s.generation})
+}
+
+// fileName returns the rm-filename header, which is sent with its non canonical key
+func fileName(r *http.Request) string {
+	if v := r.Header[transport.RmFileNameHeader]; len(v) > 0 {
+		return v[0]
+	}
+	return r.Header.Get(transport.RmFileNameHeader)
+}
@@ -0,0 +1,403 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/juruen/rmapi/api/sync15"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
+	"github.com/juruen/rmapi/transport"
+	"github.com/juruen/rmapi/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiCtx(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+
+	dir, err := ctx.CreateDir("", "books", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "books", dir.Name)
+
+	doc, err := ctx.UploadDocumentFrom(dir.ID, "notes", util.PDF, strings.NewReader("%PDF-1.4"), false)
+	assert.NoError(t, err)
+
+	// another client sees the same tree
+	other, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	node, err := other.Filetree().NodeByPath("/books/notes", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, doc.ID, node.Id())
+	assert.Equal(t, "DocumentType", node.Document.Type)
+
+	var buf bytes.Buffer
+	assert.NoError(t, other.FetchDocumentTo(doc.ID, &buf))
+	assert.Contains(t, buf.String(), "%PDF-1.4")
+
+	assert.NoError(t, other.DeleteEntry(node, false, false))
+	assert.Equal(t, int64(3), srv.Generation())
+}
+
+func TestGenerationConflict(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+
+	srv.FailRootWrites(2)
+	_, err = ctx.CreateDir("", "retried", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), srv.Generation())
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := NewServer()
+	srv.SetUnauthorized(true)
+
+	_, err := srv.NewApiCtx()
+	assert.True(t, errors.Is(err, transport.ErrUnauthorized), err)
+
+	srv.SetUnauthorized(false)
+	_, err = srv.NewApiCtx()
+	assert.NoError(t, err)
+}
+
+func TestMissingBlob(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+
+	doc, err := ctx.UploadDocumentFrom("", "notes", util.PDF, strings.NewReader("%PDF-1.4"), false)
+	assert.NoError(t, err)
+
+	srv.RemoveBlobs(func(name string) bool {
+		return strings.HasSuffix(name, ".pdf")
+	})
+	err = ctx.FetchDocumentTo(doc.ID, &bytes.Buffer{})
+	assert.Equal(t, transport.ErrNotFound, err)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc, err := ctx.UploadDocumentFrom("", fmt.Sprintf("doc%d", i), util.PDF, strings.NewReader("%PDF-1.4"), false)
+			assert.NoError(t, err)
+			assert.NoError(t, ctx.FetchDocumentTo(doc.ID, io.Discard))
+			ctx.Filetree().Root()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(n), srv.Generation())
+	_, _, err = ctx.Refresh()
+	assert.NoError(t, err)
+	// the root holds the trash too
+	assert.Len(t, ctx.Filetree().Root().Children, n+1)
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	ids := uploadDocs(t, ctx, 3)
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				filetree.WalkTree(ctx.Filetree().Root(), filetree.FileTreeVistor{
+					Visit: func(node *model.Node, path []string) bool {
+						_ = node.Name() + strings.Join(node.Document.Tags, ",")
+						_ = node.Document.Starred
+						_ = node.Parent
+						return false
+					},
+				})
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i, id := range ids {
+		writers.Add(1)
+		go func(i int, id string) {
+			defer writers.Done()
+			_, err := ctx.UpdateMetadata(id, func(metadata *archive.MetadataFile) error {
+				metadata.Pinned = true
+				metadata.DocName = fmt.Sprintf("renamed%d", i)
+				return nil
+			})
+			assert.NoError(t, err)
+			_, err = ctx.UpdateContent([]string{id}, func(_ string, content *archive.Content) error {
+				content.AddTag("tag", "")
+				return nil
+			})
+			assert.NoError(t, err)
+			_, err = ctx.DocumentContent(id)
+			assert.NoError(t, err)
+		}(i, id)
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	// the file tree has all the changes
+	for i, id := range ids {
+		n := ctx.Filetree().NodeById(id)
+		if assert.NotNil(t, n) {
+			assert.Equal(t, fmt.Sprintf("renamed%d", i), n.Name())
+			assert.True(t, n.Document.Starred)
+			assert.Equal(t, []string{"tag"}, n.Document.Tags)
+		}
+	}
+}
+
+func uploadDocs(t *testing.T, ctx *sync15.ApiCtx, n int) []string {
+	var ids []string
+	for i := 0; i < n; i++ {
+		doc, err := ctx.UploadDocumentFrom("", fmt.Sprintf("doc%d", i), util.PDF, strings.NewReader("%PDF-1.4"), false)
+		assert.NoError(t, err)
+		ids = append(ids, doc.ID)
+	}
+	return ids
+}
+
+func TestUpdateContentOrder(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	ids := uploadDocs(t, ctx, 12)
+
+	// update is called serially, it needs no lock
+	var seen []string
+	generation := srv.Generation()
+	docs, err := ctx.UpdateContent(ids, func(docId string, content *archive.Content) error {
+		seen = append(seen, docId)
+		if len(seen)%2 == 0 {
+			content.AddTag("even", "")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ids, seen)
+	assert.Equal(t, generation+1, srv.Generation())
+	if assert.Len(t, docs, len(ids)/2) {
+		for i, doc := range docs {
+			assert.Equal(t, ids[2*i+1], doc.ID)
+		}
+	}
+}
+
+func TestLazyMirror(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	ids := uploadDocs(t, ctx, 3)
+
+	sync15.LazyMirror = true
+	defer func() { sync15.LazyMirror = false }()
+
+	reads := srv.BlobReads()
+	other, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	// the root index, then the index and the metadata of each document
+	assert.Equal(t, 1+2*3, srv.BlobReads()-reads)
+
+	reads = srv.BlobReads()
+	content, err := other.DocumentContent(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "pdf", content.FileType)
+	_, err = other.DocumentContent(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, srv.BlobReads()-reads)
+}
+
+func TestLazyMirrorKeepsRemoteContent(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	ids := uploadDocs(t, ctx, 1)
+
+	sync15.LazyMirror = true
+	defer func() { sync15.LazyMirror = false }()
+
+	lazy, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	_, err = lazy.DocumentContent(ids[0])
+	assert.NoError(t, err)
+
+	// another client tags the document
+	_, err = ctx.UpdateContent(ids, func(_ string, content *archive.Content) error {
+		content.DocumentTags = append(content.DocumentTags, archive.Tag{Name: "remote"})
+		return nil
+	})
+	assert.NoError(t, err)
+
+	_, _, err = lazy.Refresh()
+	assert.NoError(t, err)
+	_, err = lazy.UpdateMetadata(ids[0], func(metadata *archive.MetadataFile) error {
+		metadata.Pinned = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// the index written by the lazy client keeps the tag
+	fresh, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	content, err := fresh.DocumentContent(ids[0])
+	assert.NoError(t, err)
+	if assert.Len(t, content.DocumentTags, 1) {
+		assert.Equal(t, "remote", content.DocumentTags[0].Name)
+	}
+	content, err = lazy.DocumentContent(ids[0])
+	assert.NoError(t, err)
+	assert.Len(t, content.DocumentTags, 1)
+}
+
+func TestMirrorResume(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	ids := uploadDocs(t, ctx, 4)
+
+	cacheFile := filepath.Join(t.TempDir(), "tree.cache")
+	http := srv.HttpClientCtx()
+	storage := sync15.NewBlobStorageForHost(http, Host)
+
+	srv.RemoveBlobs(func(name string) bool {
+		return name == ids[2]+".metadata"
+	})
+	_, err = sync15.NewCtx(http, storage, cacheFile)
+	assert.Error(t, err)
+
+	// the documents mirrored before the failure are kept
+	var checkpoint struct{ Docs []json.RawMessage }
+	if b, err := os.ReadFile(cacheFile); err == nil {
+		assert.NoError(t, json.Unmarshal(b, &checkpoint))
+	}
+	saved := len(checkpoint.Docs)
+	assert.True(t, saved < len(ids))
+
+	srv.RemoveBlobs(nil)
+	reads := srv.BlobReads()
+	_, err = sync15.NewCtx(http, storage, cacheFile)
+	assert.NoError(t, err)
+	// the root index, then the index, the metadata and the content of each missing document
+	assert.Equal(t, 1+3*(len(ids)-saved), srv.BlobReads()-reads)
+}
+
+func TestSkipKnownBlobs(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+
+	pdf := strings.Repeat("%PDF-1.4", 1000)
+	writes := srv.BlobWrites()
+	_, err = ctx.UploadDocumentFrom("", "first", util.PDF, strings.NewReader(pdf), false)
+	assert.NoError(t, err)
+	// pdf, metadata, content, document index and root index
+	assert.Equal(t, 5, srv.BlobWrites()-writes)
+
+	// the same pdf and content are not uploaded again
+	writes = srv.BlobWrites()
+	doc, err := ctx.UploadDocumentFrom("", "second", util.PDF, strings.NewReader(pdf), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, srv.BlobWrites()-writes)
+
+	var buf bytes.Buffer
+	assert.NoError(t, ctx.FetchDocumentTo(doc.ID, &buf))
+	assert.Contains(t, buf.String(), "%PDF-1.4")
+}
+
+func TestFiletreeAfterCommit(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+
+	// every commit is visible in Filetree right away, without a refresh
+	lookup := func(path string) *model.Node {
+		node, err := ctx.Filetree().NodeByPath(path, nil)
+		if err != nil {
+			return nil
+		}
+		return node
+	}
+
+	dir, err := ctx.CreateDir("", "books", false)
+	assert.NoError(t, err)
+	books := lookup("/books")
+	if assert.NotNil(t, books) {
+		assert.Equal(t, dir.ID, books.Id())
+	}
+
+	_, err = ctx.UploadDocumentFrom(dir.ID, "notes", util.PDF, strings.NewReader("%PDF-1.4"), false)
+	assert.NoError(t, err)
+	assert.NotNil(t, lookup("/books/notes"))
+
+	path := filepath.Join(t.TempDir(), "paper.pdf")
+	assert.NoError(t, os.WriteFile(path, []byte("%PDF-1.4"), 0644))
+	paper, err := ctx.UploadDocument(dir.ID, path, false, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, lookup("/books/paper"))
+
+	before := ctx.Filetree()
+	assert.NoError(t, os.WriteFile(path, []byte("%PDF-1.7"), 0644))
+	assert.NoError(t, ctx.ReplaceDocumentFile(paper.ID, path, false, nil))
+	assert.NotSame(t, before, ctx.Filetree())
+
+	_, err = ctx.CopyEntry(lookup("/books/notes"), ctx.Filetree().Root(), "copy", false)
+	assert.NoError(t, err)
+	assert.NotNil(t, lookup("/copy"))
+
+	_, err = ctx.MoveEntry(lookup("/copy"), lookup("/books"), "moved")
+	assert.NoError(t, err)
+	assert.Nil(t, lookup("/copy"))
+	assert.NotNil(t, lookup("/books/moved"))
+
+	assert.NoError(t, ctx.DeleteEntry(lookup("/books/moved"), false, false))
+	assert.Nil(t, lookup("/books/moved"))
+
+	assert.NoError(t, ctx.Nuke())
+	assert.Nil(t, lookup("/books"))
+}
+
+func TestUpdateDocumentsMetadataDuplicates(t *testing.T) {
+	srv := NewServer()
+	ctx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	ids := uploadDocs(t, ctx, 2)
+
+	generation := srv.Generation()
+	_, err = ctx.UpdateDocumentsMetadata([]string{ids[0], ids[1], ids[0]}, func(_ string, metadata *archive.MetadataFile) error {
+		metadata.Pinned = true
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, generation, srv.Generation())
+}
@@ -2,11 +2,13 @@ package api
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/juruen/rmapi/auth"
 	"github.com/juruen/rmapi/config"
 	"github.com/juruen/rmapi/log"
 	"github.com/juruen/rmapi/model"
@@ -15,24 +17,96 @@ import (
 
 const (
 	defaultDeviceDesc string = "desktop-linux"
+	passphraseEnvVar  string = "RMAPI_CONFIG_PASSPHRASE"
 )
 
-func AuthHttpCtx(reAuth, nonInteractive bool) *transport.HttpClientCtx {
+// ErrMissingDeviceToken is returned when the device is not registered
+// and a one-time code can't be asked
+var ErrMissingDeviceToken = errors.New("missing device token, register the device first")
+
+// CodeReader returns a one-time code used to register a new device
+type CodeReader func() (string, error)
+
+// DefaultTokenStore returns the token store used by rmapi:
+//   - the environment if RMAPI_DEVICE_TOKEN or RMAPI_DEVICE_TOKEN_FILE is set
+//   - the config file encrypted with RMAPI_CONFIG_PASSPHRASE if set
+//   - the config file otherwise
+func DefaultTokenStore() (auth.TokenStore, error) {
+	if auth.HasEnvTokens() {
+		return &auth.EnvTokenStore{}, nil
+	}
+
 	configPath, err := config.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if passphrase := os.Getenv(passphraseEnvVar); passphrase != "" {
+		return &auth.EncryptedFileTokenStore{Path: configPath, Passphrase: []byte(passphrase)}, nil
+	}
+
+	return &auth.FileTokenStore{Path: configPath}, nil
+}
+
+// AuthHttpCtx creates an authenticated http context from the default token store
+func AuthHttpCtx(reAuth, nonInteractive bool) *transport.HttpClientCtx {
+	store, err := DefaultTokenStore()
 	if err != nil {
 		log.Error.Fatal("failed to get config path")
 	}
-	authTokens := config.LoadTokens(configPath)
+
+	var codeReader CodeReader
+	if !nonInteractive {
+		codeReader = func() (string, error) {
+			return readCode(), nil
+		}
+	}
+
+	httpClientCtx, err := AuthHttpCtxWithStore(store, reAuth, codeReader)
+
+	if err == ErrMissingDeviceToken {
+		log.Error.Fatal("missing token, not asking, aborting")
+	}
+
+	// the device token was reset, the caller may try again
+	if err == transport.ErrUnauthorized {
+		log.Trace.Println("Invalid deviceToken, resetting")
+		return httpClientCtx
+	}
+
+	if err != nil {
+		log.Error.Fatalln(err)
+	}
+
+	return httpClientCtx
+}
+
+// AuthHttpCtxWithStore creates an authenticated http context with the tokens of store.
+// If the device is not registered yet, codeReader is used to get a one-time code,
+// a nil codeReader results in ErrMissingDeviceToken.
+// A new user token is requested when missing or when reAuth is set. If the device token
+// is rejected, it gets removed from the store and transport.ErrUnauthorized is returned.
+func AuthHttpCtxWithStore(store auth.TokenStore, reAuth bool, codeReader CodeReader) (*transport.HttpClientCtx, error) {
+	tokenSet, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokens, %w", err)
+	}
+	authTokens := toAuthTokens(tokenSet)
 	httpClientCtx := transport.CreateHttpClientCtx(authTokens)
 
 	if authTokens.DeviceToken == "" {
-		if nonInteractive {
-			log.Error.Fatal("missing token, not asking, aborting")
+		if codeReader == nil {
+			return nil, ErrMissingDeviceToken
+		}
+
+		code, err := codeReader()
+		if err != nil {
+			return nil, err
 		}
-		deviceToken, err := newDeviceToken(&httpClientCtx, readCode())
 
+		deviceToken, err := newDeviceToken(&httpClientCtx, code)
 		if err != nil {
-			log.Error.Fatal("failed to crete device token from on-time code")
+			return nil, fmt.Errorf("failed to create device token from one-time code, %w", err)
 		}
 
 		log.Trace.Println("device token", deviceToken)
@@ -40,17 +114,23 @@ func AuthHttpCtx(reAuth, nonInteractive bool) *transport.HttpClientCtx {
 		authTokens.DeviceToken = deviceToken
 		httpClientCtx.Tokens.DeviceToken = deviceToken
 
-		config.SaveTokens(configPath, authTokens)
+		if err := store.Save(toTokenSet(authTokens)); err != nil {
+			return nil, err
+		}
 	}
 
 	if authTokens.UserToken == "" || reAuth {
 		userToken, err := newUserToken(&httpClientCtx)
 
 		if err == transport.ErrUnauthorized {
-			log.Trace.Println("Invalid deviceToken, resetting")
-			authTokens.DeviceToken = ""
+			authTokens = model.AuthTokens{}
+			httpClientCtx.Tokens = authTokens
+			if err := store.Save(toTokenSet(authTokens)); err != nil {
+				return nil, err
+			}
+			return &httpClientCtx, transport.ErrUnauthorized
 		} else if err != nil {
-			log.Error.Fatalln("failed to create user token from device token", err)
+			return nil, fmt.Errorf("failed to create user token from device token, %w", err)
 		}
 
 		log.Trace.Println("user token:", userToken)
@@ -58,10 +138,62 @@ func AuthHttpCtx(reAuth, nonInteractive bool) *transport.HttpClientCtx {
 		authTokens.UserToken = userToken
 		httpClientCtx.Tokens.UserToken = userToken
 
-		config.SaveTokens(configPath, authTokens)
+		if err := store.Save(toTokenSet(authTokens)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &httpClientCtx, nil
+}
+
+// RegisterDevice registers a new device with a one-time code and saves
+// the device token, the user token is reset
+func RegisterDevice(store auth.TokenStore, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) != 8 {
+		return errors.New("code has the wrong length, it should be 8")
+	}
+
+	httpClientCtx := transport.CreateHttpClientCtx(model.AuthTokens{})
+	deviceToken, err := newDeviceToken(&httpClientCtx, code)
+	if err != nil {
+		return err
+	}
+
+	return store.Save(auth.TokenSet{DeviceToken: deviceToken})
+}
+
+// RefreshUserToken requests a new user token with the stored device token
+func RefreshUserToken(store auth.TokenStore) (string, error) {
+	_, err := AuthHttpCtxWithStore(store, true, nil)
+	if err != nil {
+		return "", err
+	}
+
+	tokenSet, err := store.Load()
+	if err != nil {
+		return "", err
 	}
+	return tokenSet.UserToken, nil
+}
 
-	return &httpClientCtx
+// Logout removes the tokens from the store
+func Logout(store auth.TokenStore) error {
+	return store.Save(auth.TokenSet{})
+}
+
+func toAuthTokens(t auth.TokenSet) model.AuthTokens {
+	return model.AuthTokens{
+		DeviceToken: t.DeviceToken,
+		UserToken:   t.UserToken,
+	}
+}
+
+func toTokenSet(t model.AuthTokens) auth.TokenSet {
+	return auth.TokenSet{
+		DeviceToken: t.DeviceToken,
+		UserToken:   t.UserToken,
+	}
 }
 
 func readCode() string {
@@ -83,13 +215,17 @@ func readCode() string {
 func newDeviceToken(http *transport.HttpClientCtx, code string) (string, error) {
 	uuid := uuid.New()
 
-	req := model.DeviceTokenRequest{code, defaultDeviceDesc, uuid.String()}
+	req := model.DeviceTokenRequest{
+		Code:       code,
+		DeviceDesc: defaultDeviceDesc,
+		DeviceId:   uuid.String(),
+	}
 
 	resp := transport.BodyString{}
 	err := http.Post(transport.EmptyBearer, config.NewTokenDevice, req, &resp)
 
 	if err != nil {
-		log.Error.Fatal("failed to create a new device token")
+		log.Error.Println("failed to create a new device token")
 		return "", err
 	}
 
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/golang-jwt/jwt"
+	"github.com/juruen/rmapi/auth"
+	"github.com/juruen/rmapi/config"
 	"github.com/juruen/rmapi/transport"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestAuthHttpCtx(t *testing.T) {
@@ -96,3 +102,48 @@ func Test_newUserToken(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHttpCtxWithStore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer device":
+			w.Write([]byte("user"))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	oldUrl := config.NewUserDevice
+	config.NewUserDevice = srv.URL
+	defer func() { config.NewUserDevice = oldUrl }()
+
+	store := &auth.MemoryTokenStore{}
+	_, err := AuthHttpCtxWithStore(store, false, nil)
+	assert.Equal(t, ErrMissingDeviceToken, err)
+
+	store.Tokens.DeviceToken = "device"
+	ctx, err := AuthHttpCtxWithStore(store, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", ctx.Tokens.UserToken)
+	assert.Equal(t, "user", store.Tokens.UserToken)
+
+	store.Tokens.DeviceToken = "revoked"
+	_, err = AuthHttpCtxWithStore(store, true, nil)
+	assert.Equal(t, transport.ErrUnauthorized, err)
+	assert.Equal(t, auth.TokenSet{}, store.Tokens)
+}
+
+func TestParseTokenStatus(t *testing.T) {
+	claims := UserToken{Scopes: "sync:tortoise intgr", StandardClaims: &jwt.StandardClaims{ExpiresAt: 1000}}
+	claims.Auth0.Email = "user@example.com"
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("key"))
+	assert.NoError(t, err)
+
+	status, err := ParseTokenStatus(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "user@example.com", status.User)
+	assert.Equal(t, []string{"sync:tortoise", "intgr"}, status.Scopes)
+	assert.True(t, status.Expired)
+	assert.Equal(t, int64(1000), status.ExpiresAt.Unix())
+}
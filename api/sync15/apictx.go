@@ -2,13 +2,15 @@ package sync15
 
 import (
 	"archive/zip"
-	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,58 +20,126 @@ import (
 	"github.com/juruen/rmapi/model"
 	"github.com/juruen/rmapi/transport"
 	"github.com/juruen/rmapi/util"
+	"golang.org/x/sync/errgroup"
 )
 
-// An ApiCtx allows you interact with the remote reMarkable API
+// An ApiCtx allows you interact with the remote reMarkable API.
+//
+// An ApiCtx is safe for concurrent use by multiple goroutines: reads of the tree
+// can run in parallel, changes are committed one at a time. The ApiCtx never changes
+// a FileTreeCtx returned by Filetree: Refresh and the changes of documents publish a
+// new one, the nodes of an older one stay valid but are not updated.
 type ApiCtx struct {
 	Http        *transport.HttpClientCtx
 	ft          *filetree.FileTreeCtx
 	blobStorage *BlobStorage
 	hashTree    *HashTree
+	// ftStale tells that ft is older than hashTree, Filetree builds a new one
+	ftStale bool
+
+	// mu guards ft, ftStale and hashTree
+	mu sync.RWMutex
 }
 
 // max number of concurrent requests
 var concurrent = 20
 
+// LazyMirror defers reading the .content of documents (tags, pages, ...) until it is needed,
+// only the metadata required to browse the tree is mirrored up front
+var LazyMirror = false
+
 func init() {
 	c := os.Getenv("RMAPI_CONCURRENT")
 	if u, err := strconv.Atoi(c); err == nil {
 		concurrent = u
 	}
+	if l, err := strconv.ParseBool(os.Getenv("RMAPI_LAZY")); err == nil {
+		LazyMirror = l
+	}
 }
 
+// CreateCtx creates an ApiCtx for the configured sync host, the tree is cached in the user cache dir
 func CreateCtx(http *transport.HttpClientCtx) (*ApiCtx, error) {
-	apiStorage := NewBlobStorage(http)
-	cacheTree, err := loadTree()
+	cacheFile, err := getCachedTreePath()
+	if err != nil {
+		fmt.Print(err)
+		return nil, err
+	}
+	return NewCtx(http, NewBlobStorage(http), cacheFile)
+}
+
+// NewCtx creates an ApiCtx mirroring the tree of the given storage.
+// The tree is cached in cacheFile, an empty cacheFile keeps it in memory only.
+func NewCtx(http *transport.HttpClientCtx, apiStorage *BlobStorage, cacheFile string) (*ApiCtx, error) {
+	// other processes wait until the cache is up to date instead of mirroring it again
+	unlock, err := lockCache(cacheFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock the cache %w", err)
+	}
+	defer unlock()
+
+	cacheTree, err := loadTree(cacheFile)
 	if err != nil {
 		fmt.Print(err)
 		return nil, err
 	}
-	err = cacheTree.Mirror(apiStorage, concurrent)
+	err = cacheTree.mirror(apiStorage, concurrent, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to mirror %v", err)
+		return nil, fmt.Errorf("failed to mirror %w", err)
+	}
+	if err := writeTree(cacheTree); err != nil {
+		log.Warning.Println("failed to write the cache", err)
 	}
-	saveTree(cacheTree)
 	tree := DocumentsFileTree(cacheTree)
-	return &ApiCtx{http, tree, apiStorage, cacheTree}, nil
+	return &ApiCtx{
+		Http:        http,
+		ft:          tree,
+		blobStorage: apiStorage,
+		hashTree:    cacheTree,
+	}, nil
 }
 
+// Filetree returns the current file tree
 func (ctx *ApiCtx) Filetree() *filetree.FileTreeCtx {
+	ctx.mu.RLock()
+	ft, stale := ctx.ft, ctx.ftStale
+	ctx.mu.RUnlock()
+	if !stale {
+		return ft
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.ftStale {
+		ctx.ft = DocumentsFileTree(ctx.hashTree)
+		ctx.ftStale = false
+	}
 	return ctx.ft
 }
 
+// Refresh mirrors the remote tree and rebuilds the file tree
 func (ctx *ApiCtx) Refresh() (string, int64, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
 	err := ctx.hashTree.Mirror(ctx.blobStorage, concurrent)
 	if err != nil {
 		return "", 0, err
 	}
+	if err := saveTree(ctx.hashTree); err != nil {
+		log.Warning.Println("failed to write the cache", err)
+	}
 	ctx.ft = DocumentsFileTree(ctx.hashTree)
+	ctx.ftStale = false
 	return ctx.hashTree.Hash, ctx.hashTree.Generation, nil
 }
 
 // Nuke removes all documents from the account
 func (ctx *ApiCtx) Nuke() (err error) {
-	err = Sync(ctx.blobStorage, ctx.hashTree, func(t *HashTree) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	err = ctx.sync(func(t *HashTree) error {
 		ctx.hashTree.Docs = nil
 		ctx.hashTree.Rehash()
 		return nil
@@ -77,55 +147,118 @@ func (ctx *ApiCtx) Nuke() (err error) {
 	return err
 }
 
-// FetchDocument downloads a document given its ID and saves it locally into dstPath
-func (ctx *ApiCtx) FetchDocument(docId, dstPath string) error {
+// DocumentContent returns the .content of a document, it is downloaded on demand
+// after a lazy mirror and cached afterwards. The returned content must not be modified.
+func (ctx *ApiCtx) DocumentContent(docId string) (*archive.Content, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
 	doc, err := ctx.hashTree.FindDoc(docId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tmp, err := os.CreateTemp("", "rmapizip")
+	if !doc.ContentLoaded() {
+		if err := doc.LoadContent(ctx.blobStorage); err != nil {
+			return nil, err
+		}
+		if err := saveTree(ctx.hashTree); err != nil {
+			log.Warning.Println("failed to write the cache", err)
+		}
+		// the tags of the document are known now
+		ctx.ftStale = true
+	}
 
+	content := doc.Content
+	return &content, nil
+}
+
+// FetchDocument downloads a document given its ID and saves it locally into dstPath
+func (ctx *ApiCtx) FetchDocument(docId, dstPath string) error {
+	// write next to dstPath so that a failed download doesn't leave a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), ".rmapizip")
 	if err != nil {
 		log.Error.Println("failed to create tmpfile for zip dir", err)
 		return err
 	}
-	defer tmp.Close()
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
 
-	w := zip.NewWriter(tmp)
-	defer w.Close()
-	for _, f := range doc.Files {
-		log.Trace.Println("fetching document: ", f.DocumentID)
-		blobReader, err := ctx.blobStorage.GetReader(f.Hash, f.DocumentID)
-		if err != nil {
-			return err
-		}
-		defer blobReader.Close()
-		header := zip.FileHeader{}
-		header.Name = f.DocumentID
-		header.Modified = time.Now()
-		zipWriter, err := w.CreateHeader(&header)
-		if err != nil {
-			return err
+	err = ctx.FetchDocumentTo(docId, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, dstPath)
+}
+
+// FetchDocumentTo downloads a document given its ID and writes it as a zip archive into w,
+// the archive can be read with archive.Zip
+func (ctx *ApiCtx) FetchDocumentTo(docId string, w io.Writer) error {
+	// copy the entries, the download doesn't block other goroutines
+	ctx.mu.RLock()
+	var files []Entry
+	doc, err := ctx.hashTree.FindDoc(docId)
+	if err == nil {
+		for _, f := range doc.Files {
+			files = append(files, *f)
 		}
-		_, err = io.Copy(zipWriter, blobReader)
+	}
+	ctx.mu.RUnlock()
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
+	zw := zip.NewWriter(w)
+	for i := range files {
+		if err := ctx.fetchFile(zw, &files[i]); err != nil {
 			return err
 		}
 	}
-	w.Close()
-	tmpPath := tmp.Name()
-	_, err = util.CopyFile(tmpPath, dstPath)
+	return zw.Close()
+}
 
+func (ctx *ApiCtx) fetchFile(zw *zip.Writer, f *Entry) error {
+	log.Trace.Println("fetching document: ", f.DocumentID)
+	blobReader, err := ctx.blobStorage.GetReader(f.Hash, f.DocumentID)
 	if err != nil {
-		log.Error.Printf("failed to copy %s to %s, er: %s\n", tmpPath, dstPath, err.Error())
 		return err
 	}
+	defer blobReader.Close()
 
-	defer os.RemoveAll(tmp.Name())
+	header := zip.FileHeader{
+		Name:     f.DocumentID,
+		Modified: time.Now(),
+	}
+	zipWriter, err := zw.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zipWriter, blobReader)
+	return err
+}
 
-	return nil
+// addDoc uploads the index of a new document and adds it to the tree
+func (ctx *ApiCtx) addDoc(doc *BlobDoc, notify bool) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	log.Info.Printf("Uploading new doc index...%s, size: %d", doc.Hash, doc.Size)
+	indexReader, err := doc.IndexReader()
+	if err != nil {
+		return err
+	}
+	err = ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+	if err != nil {
+		return err
+	}
+
+	return ctx.sync(func(t *HashTree) error {
+		return t.Add(doc)
+	}, notify)
 }
 
 // CreateDir creates a remote directory with a given name under the parentId directory
@@ -138,6 +271,8 @@ func (ctx *ApiCtx) CreateDir(parentId, name string, notify bool) (*model.Documen
 	if err != nil {
 		return nil, err
 	}
+	defer os.RemoveAll(tmpDir)
+
 	id := uuid.New().String()
 	objectName, filePath, err := archive.CreateMetadata(id, name, parentId, model.DirectoryType, tmpDir)
 	if err != nil {
@@ -152,50 +287,12 @@ func (ctx *ApiCtx) CreateDir(parentId, name string, notify bool) (*model.Documen
 	files.AddMap(objectName, filePath, archive.ContentExt)
 
 	doc := NewBlobDoc(name, id, model.DirectoryType, parentId)
-
-	for _, f := range files.Files {
-		log.Info.Printf("File %s, path: %s", f.Name, f.Path)
-		hash, size, err := FileHashAndSize(f.Path)
-		if err != nil {
-			return nil, err
-		}
-		hashStr := hex.EncodeToString(hash)
-		fileEntry := &Entry{
-			DocumentID: f.Name,
-			Hash:       hashStr,
-			Type:       FileType,
-			Size:       size,
-		}
-		reader, err := os.Open(f.Path)
-		if err != nil {
-			return nil, err
-		}
-		//does not accept rm-file in header
-		err = ctx.blobStorage.UploadBlob(hashStr, f.Name, reader)
-		reader.Close()
-
-		if err != nil {
-			return nil, err
-		}
-
-		doc.AddFile(fileEntry)
-	}
-
-	log.Info.Println("Uploading new doc index...", doc.Hash)
-	indexReader, err := doc.IndexReader()
-	if err != nil {
-		return nil, err
-	}
-	// defer indexReader.Close()
-	err = ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+	err = ctx.uploadLocalFiles(doc, files.Files)
 	if err != nil {
 		return nil, err
 	}
 
-	err = Sync(ctx.blobStorage, ctx.hashTree, func(t *HashTree) error {
-		return t.Add(doc)
-	}, notify)
-
+	err = ctx.addDoc(doc, notify)
 	if err != nil {
 		return nil, err
 	}
@@ -210,7 +307,16 @@ func (ctx *ApiCtx) CreateDir(parentId, name string, notify bool) (*model.Documen
 	return doc.ToDocument(), nil
 }
 
-// Sync applies changes to the local tree and syncs with the remote storage
+// sync commits operation with Sync and marks the file tree as stale, Filetree shows the
+// change afterwards. The tree is marked even when the commit fails, as a conflict mirrors
+// the remote tree. The caller must hold mu.
+func (ctx *ApiCtx) sync(operation func(t *HashTree) error, notify bool) error {
+	ctx.ftStale = true
+	return Sync(ctx.blobStorage, ctx.hashTree, operation, notify)
+}
+
+// Sync applies changes to the local tree and syncs with the remote storage.
+// Sync doesn't synchronize access to tree, the ApiCtx methods serialize their calls.
 func Sync(b *BlobStorage, tree *HashTree, operation func(t *HashTree) error, notify bool) error {
 	syncTry := 0
 	for {
@@ -261,16 +367,57 @@ func Sync(b *BlobStorage, tree *HashTree, operation func(t *HashTree) error, not
 	return saveTree(tree)
 }
 
-// DeleteEntry removes an entry: either an empty directory or a file
+// DeleteEntry removes an entry: either an empty directory or a file,
+// a directory is removed with all its entries when recursive is set
 func (ctx *ApiCtx) DeleteEntry(node *model.Node, recursive, notify bool) error {
-	if node.IsDirectory() && len(node.Children) > 0 && !recursive {
-		return errors.New("directory is not empty")
+	return ctx.DeleteEntries([]*model.Node{node}, recursive, notify)
+}
+
+// DeleteEntries removes the entries nodes like DeleteEntry and commits all of them at once
+func (ctx *ApiCtx) DeleteEntries(nodes []*model.Node, recursive, notify bool) error {
+	var ids []string
+	for _, node := range nodes {
+		if node.IsDirectory() && len(node.Children) > 0 && !recursive {
+			return fmt.Errorf("%s: directory is not empty", node.Name())
+		}
+		ids = append(ids, node.Document.ID)
 	}
 
-	err := Sync(ctx.blobStorage, ctx.hashTree, func(t *HashTree) error {
-		return t.Remove(node.Document.ID)
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.sync(func(t *HashTree) error {
+		remove := make(map[string]bool)
+		for _, id := range ids {
+			if _, err := t.FindDoc(id); err != nil {
+				return err
+			}
+			remove[id] = true
+		}
+		if recursive {
+			// the descendants of removed directories would end up in the root otherwise
+			for changed := true; changed; {
+				changed = false
+				for _, d := range t.Docs {
+					if !remove[d.DocumentID] && remove[d.Metadata.Parent] {
+						remove[d.DocumentID] = true
+						changed = true
+					}
+				}
+			}
+		}
+
+		docs := make([]*BlobDoc, 0, len(t.Docs))
+		for _, d := range t.Docs {
+			if remove[d.DocumentID] {
+				log.Trace.Printf("Removing %s", d.DocumentID)
+				continue
+			}
+			docs = append(docs, d)
+		}
+		t.Docs = docs
+		return t.Rehash()
 	}, notify)
-	return err
 }
 
 // MoveEntry moves an entry (either a directory or a file)
@@ -281,61 +428,132 @@ func (ctx *ApiCtx) MoveEntry(src, dstDir *model.Node, name string) (*model.Node,
 	if dstDir.IsFile() {
 		return nil, errors.New("destination directory is a file")
 	}
-	var err error
 
-	err = Sync(ctx.blobStorage, ctx.hashTree, func(t *HashTree) error {
-		doc, err := t.FindDoc(src.Document.ID)
-		if err != nil {
-			return err
-		}
-		doc.Metadata.Version++
-		doc.Metadata.DocName = name
-		doc.Metadata.Parent = dstDir.Id()
-		doc.Metadata.MetadataModified = true
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 
-		hashStr, reader, err := doc.MetadataHashAndReader()
-		if err != nil {
-			return err
-		}
-		err = doc.Rehash()
-		if err != nil {
-			return err
-		}
-		err = t.Rehash()
+	d, err := ctx.updateMetadata(src.Document.ID, func(metadata *archive.MetadataFile) error {
+		metadata.DocName = name
+		metadata.Parent = dstDir.Id()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return err
-		}
+	return &model.Node{Document: d, Children: src.Children, Parent: dstDir}, nil
+}
 
-		err = ctx.blobStorage.UploadBlob(hashStr, addExt(doc.DocumentID, archive.MetadataExt), reader)
+// UpdateMetadata changes the metadata of the document docId with update,
+// bumps its version and uploads the new metadata and document index
+func (ctx *ApiCtx) UpdateMetadata(docId string, update func(metadata *archive.MetadataFile) error) (*model.Document, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 
-		if err != nil {
-			return err
+	return ctx.updateMetadata(docId, update)
+}
+
+// UpdateDocumentsMetadata changes the metadata of the documents docIds like UpdateMetadata,
+// all of them are committed at once. The next file tree has the changes, the entries
+// whose parent changed are moved. A document can be given only once.
+func (ctx *ApiCtx) UpdateDocumentsMetadata(docIds []string, update func(docId string, metadata *archive.MetadataFile) error) ([]*model.Document, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.updateDocumentsMetadata(docIds, update)
+}
+
+func (ctx *ApiCtx) updateMetadata(docId string, update func(metadata *archive.MetadataFile) error) (*model.Document, error) {
+	docs, err := ctx.updateDocumentsMetadata([]string{docId}, func(_ string, metadata *archive.MetadataFile) error {
+		return update(metadata)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs[0], nil
+}
+
+func (ctx *ApiCtx) updateDocumentsMetadata(docIds []string, update func(docId string, metadata *archive.MetadataFile) error) ([]*model.Document, error) {
+	// update would see the changes of the first call on a duplicate
+	seen := make(map[string]bool)
+	for _, docId := range docIds {
+		if seen[docId] {
+			return nil, fmt.Errorf("%s: duplicate document", docId)
 		}
+		seen[docId] = true
+	}
 
-		log.Info.Println("Uploading new doc index...", doc.Hash)
-		indexReader, err := doc.IndexReader()
-		if err != nil {
+	var changed []*BlobDoc
+	err := ctx.sync(func(t *HashTree) error {
+		changed = make([]*BlobDoc, len(docIds))
+		for i, docId := range docIds {
+			doc, err := t.FindDoc(docId)
+			if err != nil {
+				return err
+			}
+			err = update(docId, &doc.Metadata)
+			if err != nil {
+				return err
+			}
+			changed[i] = doc
+		}
+
+		var wg errgroup.Group
+		wg.SetLimit(concurrent)
+		for _, doc := range changed {
+			wg.Go(func() error {
+				return ctx.uploadMetadata(doc)
+			})
+		}
+		if err := wg.Wait(); err != nil {
 			return err
 		}
-		// defer indexReader.Close()
-		return ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+		return t.Rehash()
 	}, true)
 
 	if err != nil {
 		return nil, err
 	}
 
-	d, err := ctx.hashTree.FindDoc(src.Document.ID)
+	docs := make([]*model.Document, len(changed))
+	for i, d := range changed {
+		docs[i] = d.ToDocument()
+	}
+	return docs, nil
+}
+
+// uploadMetadata bumps the version of the metadata of doc
+// and uploads the new metadata and document index
+func (ctx *ApiCtx) uploadMetadata(doc *BlobDoc) error {
+	doc.Metadata.Version++
+	doc.Metadata.MetadataModified = true
+
+	hashStr, reader, err := doc.MetadataHashAndReader()
+	if err != nil {
+		return err
+	}
+	doc.updateSize()
+	err = doc.Rehash()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.blobStorage.UploadBlob(hashStr, addExt(doc.DocumentID, archive.MetadataExt), reader)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &model.Node{Document: d.ToDocument(), Children: src.Children, Parent: dstDir}, nil
+	log.Info.Println("Uploading new doc index...", doc.Hash)
+	indexReader, err := doc.IndexReader()
+	if err != nil {
+		return err
+	}
+	return ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
 }
 
-// UploadDocument uploads a local document given by sourceDocPath under the parentId directory
-func (ctx *ApiCtx) UploadDocument(parentId string, sourceDocPath string, notify bool, coverpage *int) (*model.Document, error) {
+// UploadDocument uploads a local document given by sourceDocPath under the parentId directory,
+// the document gets the settings of layout unless it is nil
+func (ctx *ApiCtx) UploadDocument(parentId string, sourceDocPath string, notify bool, layout *archive.Layout) (*model.Document, error) {
 	//TODO: overwrite file
 	name, ext := util.DocPathToName(sourceDocPath)
 
@@ -356,53 +574,74 @@ func (ctx *ApiCtx) UploadDocument(parentId string, sourceDocPath string, notify
 
 	defer os.RemoveAll(tmpDir)
 
-	docFiles, id, err := archive.Prepare(name, parentId, sourceDocPath, ext, tmpDir, coverpage)
+	docFiles, id, err := archive.Prepare(name, parentId, sourceDocPath, ext, tmpDir, layout)
 	if err != nil {
 		return nil, err
 	}
 
 	doc := NewBlobDoc(name, id, model.DocumentType, parentId)
-	for _, f := range docFiles.Files {
-		log.Info.Printf("File %s, path: %s", f.Name, f.Path)
-		hash, size, err := FileHashAndSize(f.Path)
-		if err != nil {
-			return nil, err
-		}
-		hashStr := hex.EncodeToString(hash)
-		fileEntry := &Entry{
-			DocumentID: f.Name,
-			Hash:       hashStr,
-			Type:       FileType,
-			Size:       size,
-		}
-		reader, err := os.Open(f.Path)
-		if err != nil {
-			return nil, err
-		}
-		err = ctx.blobStorage.UploadBlob(hashStr, fileEntry.DocumentID, reader)
+	err = ctx.uploadLocalFiles(doc, docFiles.Files)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	err = ctx.addDoc(doc, notify)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.ToDocument(), nil
+}
 
-		doc.AddFile(fileEntry)
+// UploadDocumentFrom uploads a document of type ext read from r under the parentId directory
+// without using temporary files. The supported types are the ones of UploadDocument,
+// zip and rmdoc archives keep the id of the archived document.
+func (ctx *ApiCtx) UploadDocumentFrom(parentId, name, ext string, r io.Reader, notify bool) (*model.Document, error) {
+	doc, err := ctx.uploadFrom(parentId, name, ext, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.addDoc(doc, notify)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.ToDocument(), nil
+}
+
+// ReplaceDocumentFrom uploads a document like UploadDocumentFrom, the document with the same id
+// is replaced in the same commit. The metadata is changed by update before the upload unless
+// update is nil.
+func (ctx *ApiCtx) ReplaceDocumentFrom(parentId, name, ext string, r io.Reader, update func(metadata *archive.MetadataFile) error, notify bool) (*model.Document, error) {
+	doc, err := ctx.uploadFrom(parentId, name, ext, r, update)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Info.Printf("Uploading new doc index...%s, size: %d", doc.Hash, doc.Size)
 	indexReader, err := doc.IndexReader()
 	if err != nil {
 		return nil, err
 	}
-	// defer indexReader.Close()
 	err = ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
 	if err != nil {
 		return nil, err
 	}
 
-	err = Sync(ctx.blobStorage, ctx.hashTree, func(t *HashTree) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	err = ctx.sync(func(t *HashTree) error {
+		if existing, err := t.FindDoc(doc.DocumentID); err == nil {
+			if existing.Metadata.CollectionType != model.DocumentType {
+				return fmt.Errorf("%s is not a document", existing.Metadata.DocName)
+			}
+			if err := t.Remove(doc.DocumentID); err != nil {
+				return err
+			}
+		}
 		return t.Add(doc)
 	}, notify)
-
 	if err != nil {
 		return nil, err
 	}
@@ -410,12 +649,60 @@ func (ctx *ApiCtx) UploadDocument(parentId string, sourceDocPath string, notify
 	return doc.ToDocument(), nil
 }
 
+// uploadFrom uploads the files of a document read from r, the metadata is changed
+// by update unless it is nil. The document is not added to the tree.
+func (ctx *ApiCtx) uploadFrom(parentId, name, ext string, r io.Reader, update func(metadata *archive.MetadataFile) error) (*BlobDoc, error) {
+	if name == "" {
+		return nil, errors.New("file name is invalid")
+	}
+
+	if ext != util.ZIP && !util.IsFileTypeSupported(ext) {
+		return nil, errors.New("unsupported file extension: " + ext)
+	}
+
+	files, id, err := archive.PrepareFrom(name, parentId, ext, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := NewBlobDoc(name, id, model.DocumentType, parentId)
+	if update != nil {
+		for i, f := range files {
+			if f.FileType != archive.MetadataExt {
+				continue
+			}
+			var metadata archive.MetadataFile
+			if err := json.Unmarshal(f.Content, &metadata); err != nil {
+				return nil, err
+			}
+			if err := update(&metadata); err != nil {
+				return nil, err
+			}
+			files[i].Content, err = json.Marshal(metadata)
+			if err != nil {
+				return nil, err
+			}
+			doc.Metadata = metadata
+		}
+	}
+
+	err = ctx.uploadMemoryFiles(doc, files)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
 // ReplaceDocumentFile replaces the main document file (e.g. PDF) of an existing document
 // identified by docId with the local file given by sourceDocPath. Metadata and annotations
-// remain untouched.
-func (ctx *ApiCtx) ReplaceDocumentFile(docId, sourceDocPath string, notify bool) error {
+// remain untouched, the settings of layout are changed in the same commit unless it is nil.
+func (ctx *ApiCtx) ReplaceDocumentFile(docId, sourceDocPath string, notify bool, layout *archive.Layout) error {
 	_, ext := util.DocPathToName(sourceDocPath)
-	return Sync(ctx.blobStorage, ctx.hashTree, func(t *HashTree) error {
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	return ctx.sync(func(t *HashTree) error {
 		doc, err := t.FindDoc(docId)
 		if err != nil {
 			return err
@@ -432,37 +719,47 @@ func (ctx *ApiCtx) ReplaceDocumentFile(docId, sourceDocPath string, notify bool)
 			return fmt.Errorf("document does not contain .%s", ext)
 		}
 
-		hash, size, err := FileHashAndSize(sourceDocPath)
-		if err != nil {
-			return err
-		}
-		hashStr := hex.EncodeToString(hash)
-
 		r, err := os.Open(sourceDocPath)
 		if err != nil {
 			return err
 		}
 		defer r.Close()
 
-		if err := ctx.blobStorage.UploadBlob(hashStr, fileEntry.DocumentID, r); err != nil {
+		known := t.knownHashes()
+		uploaded, err := uploadBlob(ctx.blobStorage, fileEntry.DocumentID, r, known)
+		if err != nil {
 			return err
 		}
 
-		fileEntry.Hash = hashStr
-		fileEntry.Size = size
+		fileEntry.Hash = uploaded.Hash
+		fileEntry.Size = uploaded.Size
 
-		if err := doc.Rehash(); err != nil {
-			return err
-		}
-		if err := t.Rehash(); err != nil {
-			return err
+		// a changed .content is uploaded with the document index
+		uploadedIndex := false
+		if layout != nil && !layout.IsEmpty() {
+			uploadedIndex, err = ctx.updateContent(doc, func(_ string, content *archive.Content) error {
+				layout.Apply(content)
+				return nil
+			}, known)
+			if err != nil {
+				return err
+			}
 		}
 
-		indexReader, err := doc.IndexReader()
-		if err != nil {
-			return err
+		if !uploadedIndex {
+			if err := doc.Rehash(); err != nil {
+				return err
+			}
+			indexReader, err := doc.IndexReader()
+			if err != nil {
+				return err
+			}
+			err = ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+			if err != nil {
+				return err
+			}
 		}
-		return ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+		return t.Rehash()
 	}, notify)
 }
 
@@ -493,7 +790,11 @@ func DocumentsFileTree(tree *HashTree) *filetree.FileTreeCtx {
 
 // SyncComplete notfies that somethings has changed (triggers tablet sync)
 func (ctx *ApiCtx) SyncComplete() error {
-	err := ctx.blobStorage.SyncComplete(ctx.hashTree.Generation)
+	ctx.mu.RLock()
+	generation := ctx.hashTree.Generation
+	ctx.mu.RUnlock()
+
+	err := ctx.blobStorage.SyncComplete(generation)
 
 	//sync can be called once per generation, ignore the error if nothing was changed
 	if err == transport.ErrConflict {
@@ -23,6 +23,8 @@ type BlobDoc struct {
 	Entry
 	Metadata archive.MetadataFile
 	Content  archive.Content
+	// ContentHash is the hash of the .content read into Content
+	ContentHash string `json:",omitempty"`
 }
 
 func NewBlobDoc(name, documentId, colType, parentId string) *BlobDoc {
@@ -79,12 +81,17 @@ func (d *BlobDoc) MetadataHashAndReader() (hash string, reader io.Reader, err er
 
 func (d *BlobDoc) AddFile(e *Entry) error {
 	d.Files = append(d.Files, e)
+	d.updateSize()
+	return d.Rehash()
+}
+
+// updateSize sets the size of the document to the size of its files
+func (d *BlobDoc) updateSize() {
 	size := int64(0)
 	for _, f := range d.Files {
 		size += f.Size
 	}
 	d.Size = size
-	return d.Rehash()
 }
 
 func (t *HashTree) Add(d *BlobDoc) error {
@@ -144,45 +151,84 @@ func (d *BlobDoc) ReadMetadata(fileEntry *Entry, r RemoteStorage) error {
 	}
 
 	if strings.HasSuffix(fileEntry.DocumentID, ".content") {
-		log.Trace.Println("Reading content: " + d.DocumentID)
+		if err := d.readContent(fileEntry, r); err != nil {
+			log.Warning.Printf("cannot read content %s: %v", fileEntry.DocumentID, err)
+		}
+	}
 
-		contentData := archive.Content{}
+	return nil
+}
 
-		contentReader, err := r.GetReader(fileEntry.Hash, fileEntry.DocumentID)
-		if err != nil {
-			log.Warning.Printf("cannot get content reader %s: %v", fileEntry.DocumentID, err)
-			return nil
-		}
-		defer contentReader.Close()
+// readContent reads the .content given by fileEntry into Content
+func (d *BlobDoc) readContent(fileEntry *Entry, r RemoteStorage) error {
+	log.Trace.Println("Reading content: " + d.DocumentID)
 
-		contentBytes, err := io.ReadAll(contentReader)
-		if err != nil {
-			log.Warning.Printf("cannot read content bytes %s: %v", fileEntry.DocumentID, err)
-			return nil
-		}
+	contentReader, err := r.GetReader(fileEntry.Hash, fileEntry.DocumentID)
+	if err != nil {
+		return err
+	}
+	defer contentReader.Close()
 
-		err = json.Unmarshal(contentBytes, &contentData)
-		if err != nil {
-			log.Warning.Printf("cannot parse content JSON %s: %v", fileEntry.DocumentID, err)
-			return nil
-		}
+	contentBytes, err := io.ReadAll(contentReader)
+	if err != nil {
+		return err
+	}
 
-		// Ensure nil slices become empty arrays
-		if contentData.DocumentTags == nil {
-			contentData.DocumentTags = []archive.Tag{}
-		}
-		if contentData.PageTags == nil {
-			contentData.PageTags = []archive.PageTag{}
-		}
+	return d.setContent(fileEntry, contentBytes)
+}
 
-		log.Trace.Printf("parsed content for %s: %d document tags, %d page tags",
-			d.DocumentID, len(contentData.DocumentTags), len(contentData.PageTags))
-		d.Content = contentData
+// setContent parses the data of the .content fileEntry into Content
+func (d *BlobDoc) setContent(fileEntry *Entry, data []byte) error {
+	contentData := archive.Content{}
+	err := json.Unmarshal(data, &contentData)
+	if err != nil {
+		return fmt.Errorf("cannot parse content JSON: %w", err)
 	}
 
+	// Ensure nil slices become empty arrays
+	if contentData.DocumentTags == nil {
+		contentData.DocumentTags = []archive.Tag{}
+	}
+	if contentData.PageTags == nil {
+		contentData.PageTags = []archive.PageTag{}
+	}
+
+	log.Trace.Printf("parsed content for %s: %d document tags, %d page tags",
+		d.DocumentID, len(contentData.DocumentTags), len(contentData.PageTags))
+	d.Content = contentData
+	d.ContentHash = fileEntry.Hash
+	return nil
+}
+
+// skipLazy tells whether reading the file is deferred until needed
+func skipLazy(e *Entry) bool {
+	return LazyMirror && strings.HasSuffix(e.DocumentID, "."+string(archive.ContentExt))
+}
+
+// contentEntry returns the .content file of the document
+func (d *BlobDoc) contentEntry() *Entry {
+	for _, f := range d.Files {
+		if strings.HasSuffix(f.DocumentID, "."+string(archive.ContentExt)) {
+			return f
+		}
+	}
 	return nil
 }
 
+// ContentLoaded tells whether Content is up to date, it isn't after a lazy mirror
+func (d *BlobDoc) ContentLoaded() bool {
+	e := d.contentEntry()
+	return e == nil || e.Hash == d.ContentHash
+}
+
+// LoadContent reads the .content of the document unless it is already loaded
+func (d *BlobDoc) LoadContent(r RemoteStorage) error {
+	if d.ContentLoaded() {
+		return nil
+	}
+	return d.readContent(d.contentEntry(), r)
+}
+
 func (d *BlobDoc) Line() string {
 	return d.LineWithSchema("")
 }
@@ -236,11 +282,15 @@ func (d *BlobDoc) Mirror(e *Entry, r RemoteStorage) error {
 	for _, currentEntry := range d.Files {
 		if newEntry, ok := new[currentEntry.DocumentID]; ok {
 			if newEntry.Hash != currentEntry.Hash {
-				err = d.ReadMetadata(newEntry, r)
-				if err != nil {
-					return err
+				// a lazy .content is read later, the new hash tells it is out of date
+				if !skipLazy(newEntry) {
+					err = d.ReadMetadata(newEntry, r)
+					if err != nil {
+						return err
+					}
 				}
 				currentEntry.Hash = newEntry.Hash
+				currentEntry.Size = newEntry.Size
 			}
 			head = append(head, currentEntry)
 			current[currentEntry.DocumentID] = currentEntry
@@ -250,9 +300,11 @@ func (d *BlobDoc) Mirror(e *Entry, r RemoteStorage) error {
 	//add missing
 	for k, newEntry := range new {
 		if _, ok := current[k]; !ok {
-			err = d.ReadMetadata(newEntry, r)
-			if err != nil {
-				return err
+			if !skipLazy(newEntry) {
+				err = d.ReadMetadata(newEntry, r)
+				if err != nil {
+					return err
+				}
 			}
 			head = append(head, newEntry)
 		}
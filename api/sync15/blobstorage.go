@@ -3,6 +3,7 @@ package sync15
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/juruen/rmapi/config"
 	"github.com/juruen/rmapi/log"
@@ -13,16 +14,34 @@ import (
 type BlobStorage struct {
 	http        *transport.HttpClientCtx
 	concurrency int
+	blobUrl     string
+	rootGet     string
+	rootPut     string
 }
 
 func NewBlobStorage(http *transport.HttpClientCtx) *BlobStorage {
 	return &BlobStorage{
-		http: http,
+		http:    http,
+		blobUrl: config.BlobUrl,
+		rootGet: config.RootGet,
+		rootPut: config.RootPut,
+	}
+}
+
+// NewBlobStorageForHost creates a BlobStorage using the sync api of host
+// (e.g. a test server) instead of the configured one
+func NewBlobStorageForHost(http *transport.HttpClientCtx, host string) *BlobStorage {
+	host = strings.TrimSuffix(host, "/")
+	return &BlobStorage{
+		http:    http,
+		blobUrl: host + config.BlobPath,
+		rootGet: host + config.RootGetPath,
+		rootPut: host + config.RootPutPath,
 	}
 }
 
 func (b *BlobStorage) GetReader(hash, filename string) (io.ReadCloser, error) {
-	return b.http.GetStream(transport.UserBearer, config.BlobUrl+hash, filename)
+	return b.http.GetStream(transport.UserBearer, b.blobUrl+hash, filename)
 }
 
 func (b *BlobStorage) UploadBlob(hash, filename string, reader io.Reader) error {
@@ -32,7 +51,7 @@ func (b *BlobStorage) UploadBlob(hash, filename string, reader io.Reader) error
 	if filename == "root.docSchema" {
 		headers["content-type"] = "text/plain; charset=UTF-8"
 	}
-	return b.http.PutStream(transport.UserBearer, config.BlobUrl+hash, reader, filename, headers)
+	return b.http.PutStream(transport.UserBearer, b.blobUrl+hash, reader, filename, headers)
 }
 
 // SyncComplete no longer used
@@ -52,7 +71,7 @@ func (b *BlobStorage) WriteRootIndex(roothash string, gen int64, notify bool) (i
 		transport.RmFileNameHeader: "roothash",
 	}
 
-	err := b.http.Put(transport.UserBearer, config.RootPut, req, &res, headers)
+	err := b.http.Put(transport.UserBearer, b.rootPut, req, &res, headers)
 	if err != nil {
 		return 0, err
 	}
@@ -64,7 +83,7 @@ func (b *BlobStorage) WriteRootIndex(roothash string, gen int64, notify bool) (i
 }
 func (b *BlobStorage) GetRootIndex() (string, int64, error) {
 	var res model.BlobRootStorageResponse
-	err := b.http.Get(transport.UserBearer, config.RootGet, nil, &res)
+	err := b.http.Get(transport.UserBearer, b.rootGet, nil, &res)
 	if err != nil {
 		return "", 0, err
 	}
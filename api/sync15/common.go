@@ -41,14 +41,14 @@ func getCachedTreePath() (string, error) {
 	return cacheFile, nil
 }
 
-const cacheVersion = 3
+const cacheVersion = 4
 
-func loadTree() (*HashTree, error) {
-	cacheFile, err := getCachedTreePath()
-	if err != nil {
-		return nil, err
+// loadTree loads the cached tree from cacheFile, an empty cacheFile results in an in-memory tree
+func loadTree(cacheFile string) (*HashTree, error) {
+	tree := &HashTree{cachePath: cacheFile}
+	if cacheFile == "" {
+		return tree, nil
 	}
-	tree := &HashTree{}
 	if _, err := os.Stat(cacheFile); err == nil {
 		b, err := os.ReadFile(cacheFile)
 		if err != nil {
@@ -57,11 +57,11 @@ func loadTree() (*HashTree, error) {
 		err = json.Unmarshal(b, tree)
 		if err != nil {
 			log.Error.Println("cache corrupt, resyncing")
-			return tree, nil
+			return &HashTree{cachePath: cacheFile}, nil
 		}
 		if tree.CacheVersion != cacheVersion {
 			log.Info.Println("wrong cache file version, resyncing")
-			return &HashTree{}, nil
+			return &HashTree{cachePath: cacheFile}, nil
 		}
 	}
 	log.Info.Println("cache loaded: ", cacheFile)
@@ -69,18 +69,58 @@ func loadTree() (*HashTree, error) {
 	return tree, nil
 }
 
+// lockCache takes the lock shared by all processes using cacheFile,
+// it is a no-op for in-memory trees
+func lockCache(cacheFile string) (unlock func(), err error) {
+	if cacheFile == "" {
+		return func() {}, nil
+	}
+	l, err := lockFile(cacheFile + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			log.Warning.Println("failed to unlock the cache", err)
+		}
+	}, nil
+}
+
 // save cached version of the tree
 func saveTree(tree *HashTree) error {
-	cacheFile, err := getCachedTreePath()
-	log.Info.Println("Writing cache: ", cacheFile)
+	unlock, err := lockCache(tree.cachePath)
 	if err != nil {
 		return err
 	}
+	defer unlock()
+	return writeTree(tree)
+}
+
+// writeTree writes the cache, the caller holds the cache lock
+func writeTree(tree *HashTree) error {
+	cacheFile := tree.cachePath
+	if cacheFile == "" {
+		return nil
+	}
+	log.Info.Println("Writing cache: ", cacheFile)
 	tree.CacheVersion = cacheVersion
 	b, err := json.MarshalIndent(tree, "", "")
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(cacheFile, b, 0644)
-	return err
+
+	// replace the cache atomically so that readers never see a partial file
+	tmp, err := os.CreateTemp(path.Dir(cacheFile), ".tree.cache")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cacheFile)
 }
@@ -0,0 +1,51 @@
+package sync15
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "tree.cache")
+
+	unlock, err := lockCache(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := lockCache(cacheFile)
+		if err != nil {
+			t.Error(err)
+		}
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the cache was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+}
+
+func TestSaveTree(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "tree.cache")
+	tree := &HashTree{cachePath: cacheFile, Hash: "hash", Generation: 3}
+	if err := saveTree(tree); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadTree(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Hash != "hash" || loaded.Generation != 3 {
+		t.Errorf("unexpected tree %+v", loaded)
+	}
+}
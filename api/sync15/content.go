@@ -0,0 +1,164 @@
+package sync15
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/model"
+	"golang.org/x/sync/errgroup"
+)
+
+// errUnchanged stops a sync when there is nothing to commit
+var errUnchanged = errors.New("nothing changed")
+
+// UpdateContent changes the .content of the documents docIds with update and commits
+// all of them at once. Only the fields changed by update are rewritten, the unchanged
+// documents are skipped. The changed documents are returned.
+//
+// update is called for one document at a time in the order of docIds, the files are
+// downloaded and uploaded in parallel.
+func (ctx *ApiCtx) UpdateContent(docIds []string, update func(docId string, content *archive.Content) error) ([]*model.Document, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	var changed []*BlobDoc
+	err := ctx.sync(func(t *HashTree) error {
+		changed = nil
+		known := t.knownHashes()
+
+		docs := make([]*BlobDoc, len(docIds))
+		for i, docId := range docIds {
+			doc, err := t.FindDoc(docId)
+			if err != nil {
+				return err
+			}
+			docs[i] = doc
+		}
+
+		data := make([][]byte, len(docs))
+		var reads errgroup.Group
+		reads.SetLimit(concurrent)
+		for i, doc := range docs {
+			reads.Go(func() (err error) {
+				data[i], err = ctx.readContent(doc)
+				if err != nil {
+					return fmt.Errorf("%s: %w", doc.Metadata.DocName, err)
+				}
+				return nil
+			})
+		}
+		if err := reads.Wait(); err != nil {
+			return err
+		}
+
+		patched := make([][]byte, len(docs))
+		for i, doc := range docs {
+			p, ok, err := archive.PatchContent(data[i], func(content *archive.Content) error {
+				return update(doc.DocumentID, content)
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", doc.Metadata.DocName, err)
+			}
+			if ok {
+				patched[i] = p
+				changed = append(changed, doc)
+			}
+		}
+		if len(changed) == 0 {
+			return errUnchanged
+		}
+
+		var uploads errgroup.Group
+		uploads.SetLimit(concurrent)
+		for i, doc := range docs {
+			if patched[i] == nil {
+				continue
+			}
+			uploads.Go(func() error {
+				if err := ctx.uploadContent(doc, patched[i], known); err != nil {
+					return fmt.Errorf("%s: %w", doc.Metadata.DocName, err)
+				}
+				return nil
+			})
+		}
+		if err := uploads.Wait(); err != nil {
+			return err
+		}
+		return t.Rehash()
+	}, true)
+
+	if err == errUnchanged {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*model.Document, len(changed))
+	for i, doc := range changed {
+		docs[i] = doc.ToDocument()
+	}
+	return docs, nil
+}
+
+// updateContent rewrites the .content of doc and uploads it along with the document index,
+// false is returned if update didn't change anything
+func (ctx *ApiCtx) updateContent(doc *BlobDoc, update func(docId string, content *archive.Content) error, known map[string]bool) (bool, error) {
+	data, err := ctx.readContent(doc)
+	if err != nil {
+		return false, err
+	}
+
+	patched, ok, err := archive.PatchContent(data, func(content *archive.Content) error {
+		return update(doc.DocumentID, content)
+	})
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, ctx.uploadContent(doc, patched, known)
+}
+
+// readContent downloads the .content of doc
+func (ctx *ApiCtx) readContent(doc *BlobDoc) ([]byte, error) {
+	entry := doc.contentEntry()
+	if entry == nil {
+		return nil, errors.New("the document has no content")
+	}
+
+	reader, err := ctx.blobStorage.GetReader(entry.Hash, entry.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// uploadContent uploads the .content data of doc along with the document index
+func (ctx *ApiCtx) uploadContent(doc *BlobDoc, data []byte, known map[string]bool) error {
+	entry := doc.contentEntry()
+	uploaded, err := uploadBlob(ctx.blobStorage, entry.DocumentID, bytes.NewReader(data), known)
+	if err != nil {
+		return err
+	}
+	entry.Hash = uploaded.Hash
+	entry.Size = uploaded.Size
+	if err := doc.setContent(entry, data); err != nil {
+		return err
+	}
+
+	doc.updateSize()
+	if err := doc.Rehash(); err != nil {
+		return err
+	}
+
+	log.Info.Println("Uploading new doc index...", doc.Hash)
+	indexReader, err := doc.IndexReader()
+	if err != nil {
+		return err
+	}
+	return ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+}
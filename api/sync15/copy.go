@@ -0,0 +1,123 @@
+package sync15
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/model"
+	"golang.org/x/sync/errgroup"
+)
+
+// CopyEntry copies src into the directory dstDir as name, a directory is copied
+// with all its entries when recursive is set. The copies get new ids and their files
+// are renamed accordingly, but the blobs are reused: only the metadata and the
+// document indexes are uploaded. All the copies are committed at once.
+// The copied documents are returned, parents before their children.
+func (ctx *ApiCtx) CopyEntry(src, dstDir *model.Node, name string, recursive bool) ([]*model.Document, error) {
+	return ctx.copyEntries([]*model.Node{src}, dstDir, []string{name}, recursive)
+}
+
+// CopyEntries copies srcs into the directory dstDir keeping their names, like CopyEntry
+func (ctx *ApiCtx) CopyEntries(srcs []*model.Node, dstDir *model.Node, recursive bool) ([]*model.Document, error) {
+	names := make([]string, len(srcs))
+	for i, src := range srcs {
+		names[i] = src.Name()
+	}
+	return ctx.copyEntries(srcs, dstDir, names, recursive)
+}
+
+func (ctx *ApiCtx) copyEntries(srcs []*model.Node, dstDir *model.Node, names []string, recursive bool) ([]*model.Document, error) {
+	if dstDir.IsFile() {
+		return nil, errors.New("destination directory is a file")
+	}
+	for _, src := range srcs {
+		if src.IsRoot() {
+			return nil, errors.New("cannot copy the root directory")
+		}
+		if src.IsDirectory() && len(src.Children) > 0 && !recursive {
+			return nil, fmt.Errorf("%s: directory is not empty", src.Name())
+		}
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	var copies []*BlobDoc
+	err := ctx.sync(func(t *HashTree) error {
+		children := make(map[string][]*BlobDoc)
+		if recursive {
+			for _, d := range t.Docs {
+				if !d.Metadata.Deleted {
+					children[d.Metadata.Parent] = append(children[d.Metadata.Parent], d)
+				}
+			}
+		}
+
+		// sources[i] is the document copied into copies[i]
+		var sources []*BlobDoc
+		copies = nil
+		for i, src := range srcs {
+			doc, err := t.FindDoc(src.Id())
+			if err != nil {
+				return err
+			}
+			sources = append(sources, doc)
+			copies = append(copies, copyDoc(doc, dstDir.Id(), names[i]))
+		}
+		for i := 0; i < len(copies); i++ {
+			for _, child := range children[sources[i].DocumentID] {
+				sources = append(sources, child)
+				copies = append(copies, copyDoc(child, copies[i].DocumentID, child.Metadata.DocName))
+			}
+		}
+
+		var wg errgroup.Group
+		wg.SetLimit(concurrent)
+		for _, c := range copies {
+			wg.Go(func() error {
+				return ctx.uploadMetadata(c)
+			})
+		}
+		if err := wg.Wait(); err != nil {
+			return err
+		}
+
+		t.Docs = append(t.Docs, copies...)
+		return t.Rehash()
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*model.Document, len(copies))
+	for i, c := range copies {
+		docs[i] = c.ToDocument()
+	}
+	return docs, nil
+}
+
+// copyDoc returns a copy of doc with a new id in the directory parentId,
+// the files keep their blobs
+func copyDoc(doc *BlobDoc, parentId, name string) *BlobDoc {
+	id := uuid.New().String()
+	c := &BlobDoc{
+		Entry:       Entry{DocumentID: id, Type: doc.Type},
+		Metadata:    doc.Metadata,
+		Content:     doc.Content,
+		ContentHash: doc.ContentHash,
+	}
+	c.Metadata.DocName = name
+	c.Metadata.Parent = parentId
+	c.Metadata.Version = 0
+	c.Metadata.LastModified = archive.UnixTimestamp()
+
+	for _, f := range doc.Files {
+		file := *f
+		file.DocumentID = id + strings.TrimPrefix(f.DocumentID, doc.DocumentID)
+		c.Files = append(c.Files, &file)
+	}
+	return c
+}
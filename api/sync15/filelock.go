@@ -0,0 +1,33 @@
+package sync15
+
+import (
+	"os"
+)
+
+// fileLock is an advisory lock shared between rmapi processes, e.g. a cron job
+// and an interactive shell using the same tree cache
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile blocks until the exclusive lock on path is acquired, the file is created if needed
+func lockFile(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFd(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileLock{f}, nil
+}
+
+// Unlock releases the lock
+func (l *fileLock) Unlock() error {
+	err := unlockFd(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
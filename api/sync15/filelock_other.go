@@ -0,0 +1,14 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package sync15
+
+import "os"
+
+// advisory locks are not supported, concurrent processes are not synchronized
+func lockFd(f *os.File) error {
+	return nil
+}
+
+func unlockFd(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package sync15
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lock the whole file
+const allBytes = ^uint32(0)
+
+func lockFd(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, allBytes, allBytes, ol)
+}
+
+func unlockFd(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, allBytes, allBytes, ol)
+}
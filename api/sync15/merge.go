@@ -0,0 +1,277 @@
+package sync15
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/model"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrSeveralPayloads is returned when documents backed by different PDFs or EPUBs are merged
+var ErrSeveralPayloads = errors.New("only one of the merged documents can be a PDF or an EPUB")
+
+// sourceDoc is a document whose pages are used for a new document
+type sourceDoc struct {
+	doc     *BlobDoc
+	data    []byte
+	content archive.Content
+	pages   []archive.PageInfo
+}
+
+// newPage is a page of a new document with the document it comes from
+type newPage struct {
+	src  *sourceDoc
+	page archive.PageInfo
+}
+
+// MergeDocuments creates the document name in the directory parentId with the pages of the
+// documents docIds, in order. The files of the pages are reused, at most one of the documents
+// can be backed by a PDF or an EPUB, its payload is reused too.
+func (ctx *ApiCtx) MergeDocuments(docIds []string, parentId, name string) (*model.Document, error) {
+	if len(docIds) == 0 {
+		return nil, errors.New("nothing to merge")
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	var created *BlobDoc
+	err := ctx.sync(func(t *HashTree) error {
+		var sources []*sourceDoc
+		var base *sourceDoc
+		for _, id := range docIds {
+			src, err := ctx.readSource(t, id)
+			if err != nil {
+				return err
+			}
+			if src.hasPayload() {
+				if base != nil && base.doc != src.doc {
+					return ErrSeveralPayloads
+				}
+				base = src
+			}
+			sources = append(sources, src)
+		}
+		if base == nil {
+			base = sources[0]
+		}
+
+		var pages []newPage
+		for _, src := range sources {
+			for _, p := range src.pages {
+				pages = append(pages, newPage{src, p})
+			}
+		}
+
+		var err error
+		created, err = ctx.createFromPages(t, base, pages, parentId, name)
+		if err != nil {
+			return err
+		}
+		t.Docs = append(t.Docs, created)
+		return t.Rehash()
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	return created.ToDocument(), nil
+}
+
+// SplitDocument creates a document for each part of the document docId, the parts start
+// at the pages at (starting at 1) and the first page. The parts are named after names and
+// created next to the document, which is kept. The files of the pages are reused.
+func (ctx *ApiCtx) SplitDocument(docId string, at []int, names []string) ([]*model.Document, error) {
+	if len(names) != len(at)+1 {
+		return nil, fmt.Errorf("%d names are needed for %d parts", len(at)+1, len(at)+1)
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	var created []*BlobDoc
+	err := ctx.sync(func(t *HashTree) error {
+		src, err := ctx.readSource(t, docId)
+		if err != nil {
+			return err
+		}
+
+		starts := append([]int{1}, at...)
+		ends := append(append([]int{}, at...), len(src.pages)+1)
+		for i := range starts {
+			if starts[i] < 1 || ends[i] > len(src.pages)+1 || starts[i] >= ends[i] {
+				return fmt.Errorf("invalid split at page %d, the document has %d pages", starts[i], len(src.pages))
+			}
+		}
+
+		created = nil
+		for i := range starts {
+			var pages []newPage
+			for _, p := range src.pages[starts[i]-1 : ends[i]-1] {
+				pages = append(pages, newPage{src, p})
+			}
+			doc, err := ctx.createFromPages(t, src, pages, src.doc.Metadata.Parent, names[i])
+			if err != nil {
+				return err
+			}
+			created = append(created, doc)
+		}
+
+		t.Docs = append(t.Docs, created...)
+		return t.Rehash()
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*model.Document, len(created))
+	for i, d := range created {
+		docs[i] = d.ToDocument()
+	}
+	return docs, nil
+}
+
+func (s *sourceDoc) hasPayload() bool {
+	return s.content.FileType == "pdf" || s.content.FileType == "epub"
+}
+
+// readSource reads the .content and the pages of the document docId
+func (ctx *ApiCtx) readSource(t *HashTree, docId string) (*sourceDoc, error) {
+	doc, err := t.FindDoc(docId)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Metadata.CollectionType != model.DocumentType {
+		return nil, fmt.Errorf("%s is not a document", doc.Metadata.DocName)
+	}
+
+	var files []Entry
+	for _, f := range doc.Files {
+		files = append(files, *f)
+	}
+	data, pagedata, err := ctx.readFiles(doc.DocumentID, files)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &sourceDoc{doc: doc, data: data}
+	if err := json.Unmarshal(data, &src.content); err != nil {
+		return nil, fmt.Errorf("%s: %w", doc.Metadata.DocName, err)
+	}
+	src.pages, err = archive.ReadPages(&src.content, pagedata)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", doc.Metadata.DocName, err)
+	}
+	return src, nil
+}
+
+// createFromPages creates and uploads the document name with pages, the .content and the payload
+// come from base. The document is not added to the tree.
+func (ctx *ApiCtx) createFromPages(t *HashTree, base *sourceDoc, pages []newPage, parentId, name string) (*BlobDoc, error) {
+	id := uuid.New().String()
+	doc := NewBlobDoc(name, id, model.DocumentType, parentId)
+
+	// the page ids must be unique in the document, duplicated ones get a new id
+	used := make(map[string]bool)
+	infos := make([]archive.PageInfo, len(pages))
+	var pageTags []archive.PageTag
+	for i, p := range pages {
+		info := p.page
+		if used[info.ID] {
+			info = info.Duplicate()
+		}
+		used[info.ID] = true
+		if p.src != base {
+			info.DocPage = -1
+		}
+		infos[i] = info
+
+		for _, tag := range p.src.content.PageTags {
+			if tag.PageID == p.page.ID {
+				pageTags = append(pageTags, archive.PageTag{Name: tag.Name, PageID: info.ID, Timestamp: tag.Timestamp})
+			}
+		}
+		files, err := pageFiles(p.src, p.page, info.ID, id)
+		if err != nil {
+			return nil, err
+		}
+		doc.Files = append(doc.Files, files...)
+	}
+
+	// the payload of base
+	for _, f := range base.doc.Files {
+		if base.hasPayload() && f.DocumentID == base.doc.DocumentID+"."+base.content.FileType {
+			file := *f
+			file.DocumentID = id + "." + base.content.FileType
+			doc.Files = append(doc.Files, &file)
+		}
+	}
+
+	var pagedata []byte
+	content, _, err := archive.PatchContent(base.data, func(content *archive.Content) error {
+		for _, p := range pages {
+			for _, tag := range p.src.content.DocumentTags {
+				content.AddTag(tag.Name, "")
+			}
+		}
+		content.PageTags = pageTags
+		content.LastOpenedPage = 0
+		var err error
+		pagedata, err = archive.SetPages(content, infos)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", base.doc.Metadata.DocName, err)
+	}
+
+	known := t.knownHashes()
+	var contentEntry, pagedataEntry *Entry
+	var wg errgroup.Group
+	wg.Go(func() (err error) {
+		contentEntry, err = uploadBlob(ctx.blobStorage, addExt(id, archive.ContentExt), bytes.NewReader(content), known)
+		return
+	})
+	wg.Go(func() (err error) {
+		pagedataEntry, err = uploadBlob(ctx.blobStorage, addExt(id, archive.PagedataExt), bytes.NewReader(pagedata), known)
+		return
+	})
+	if err := wg.Wait(); err != nil {
+		return nil, err
+	}
+	if err := doc.setContent(contentEntry, content); err != nil {
+		return nil, err
+	}
+	doc.Files = append(doc.Files, contentEntry, pagedataEntry, &Entry{DocumentID: addExt(id, archive.MetadataExt), Type: FileType})
+
+	// uploads the metadata and the document index
+	return doc, ctx.uploadMetadata(doc)
+}
+
+// pageFiles returns the files of the page of src renamed for the page pageId of the document docId
+func pageFiles(src *sourceDoc, page archive.PageInfo, pageId, docId string) ([]*Entry, error) {
+	if page.Source == "" {
+		return nil, nil
+	}
+
+	ids := map[string]bool{page.Source: true}
+	var files []*Entry
+	for _, f := range src.doc.Files {
+		if _, ok := archive.PageFileID(f.DocumentID, ids); !ok {
+			continue
+		}
+		if !strings.HasPrefix(f.DocumentID, src.doc.DocumentID) {
+			return nil, fmt.Errorf("unexpected file %s", f.DocumentID)
+		}
+		file := *f
+		name := docId + strings.TrimPrefix(f.DocumentID, src.doc.DocumentID)
+		i := strings.LastIndex(name, page.Source)
+		file.DocumentID = name[:i] + pageId + name[i+len(page.Source):]
+		files = append(files, &file)
+	}
+	return files, nil
+}
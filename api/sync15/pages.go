@@ -0,0 +1,214 @@
+package sync15
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/log"
+	"github.com/juruen/rmapi/model"
+)
+
+// DocumentPages returns the pages of the document docId
+func (ctx *ApiCtx) DocumentPages(docId string) ([]archive.PageInfo, error) {
+	ctx.mu.RLock()
+	doc, err := ctx.hashTree.FindDoc(docId)
+	var files []Entry
+	if err == nil {
+		for _, f := range doc.Files {
+			files = append(files, *f)
+		}
+	}
+	ctx.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	content, pagedata, err := ctx.readPagesFiles(docId, files)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := archive.ReadPages(content, pagedata)
+	if err != nil {
+		return nil, err
+	}
+
+	pageIDs := make(map[string]bool)
+	for _, p := range pages {
+		pageIDs[p.ID] = true
+	}
+	annotated := make(map[string]bool)
+	for _, f := range files {
+		if id, ok := archive.PageFileID(f.DocumentID, pageIDs); ok && strings.HasSuffix(f.DocumentID, ".rm") {
+			annotated[id] = true
+		}
+	}
+	for i := range pages {
+		pages[i].Annotated = annotated[pages[i].ID]
+	}
+	return pages, nil
+}
+
+// UpdatePages changes the pages of the document docId with update, which returns the new pages.
+// The .content and .pagedata are rewritten and the files of the pages are renamed, copied
+// for duplicates or removed from the document index. Nothing is committed if the pages
+// didn't change.
+func (ctx *ApiCtx) UpdatePages(docId string, update func(pages []archive.PageInfo) ([]archive.PageInfo, error)) (*model.Document, error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	var doc *BlobDoc
+	err := ctx.sync(func(t *HashTree) error {
+		var err error
+		doc, err = t.FindDoc(docId)
+		if err != nil {
+			return err
+		}
+		return ctx.updatePages(doc, update, t.knownHashes())
+	}, true)
+
+	if err == errUnchanged {
+		return doc.ToDocument(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.ToDocument(), nil
+}
+
+func (ctx *ApiCtx) updatePages(doc *BlobDoc, update func(pages []archive.PageInfo) ([]archive.PageInfo, error), known map[string]bool) error {
+	var files []Entry
+	for _, f := range doc.Files {
+		files = append(files, *f)
+	}
+	data, pagedata, err := ctx.readFiles(doc.DocumentID, files)
+	if err != nil {
+		return err
+	}
+
+	var oldIDs map[string]bool
+	var pages []archive.PageInfo
+	var newPagedata []byte
+	patched, changed, err := archive.PatchContent(data, func(content *archive.Content) error {
+		old, err := archive.ReadPages(content, pagedata)
+		if err != nil {
+			return err
+		}
+		oldIDs = make(map[string]bool)
+		for _, p := range old {
+			oldIDs[p.ID] = true
+		}
+
+		pages, err = update(old)
+		if err != nil {
+			return err
+		}
+		newPagedata, err = archive.SetPages(content, pages)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !changed && bytes.Equal(pagedata, newPagedata) {
+		return errUnchanged
+	}
+
+	// the files of the document and the ones of its pages
+	var docFiles []*Entry
+	pageFiles := make(map[string][]*Entry)
+	for _, f := range doc.Files {
+		if id, ok := archive.PageFileID(f.DocumentID, oldIDs); ok {
+			pageFiles[id] = append(pageFiles[id], f)
+			continue
+		}
+		if strings.HasSuffix(f.DocumentID, "."+string(archive.ContentExt)) || strings.HasSuffix(f.DocumentID, "."+string(archive.PagedataExt)) {
+			continue
+		}
+		docFiles = append(docFiles, f)
+	}
+	for _, p := range pages {
+		if p.Source == "" {
+			continue
+		}
+		if p.Source != p.ID && oldIDs[p.ID] {
+			return errors.New("duplicated pages need a new id")
+		}
+		for _, f := range pageFiles[p.Source] {
+			file := *f
+			i := strings.LastIndex(file.DocumentID, p.Source)
+			file.DocumentID = file.DocumentID[:i] + p.ID + file.DocumentID[i+len(p.Source):]
+			docFiles = append(docFiles, &file)
+		}
+	}
+
+	contentEntry, err := uploadBlob(ctx.blobStorage, addExt(doc.DocumentID, archive.ContentExt), bytes.NewReader(patched), known)
+	if err != nil {
+		return err
+	}
+	pagedataEntry, err := uploadBlob(ctx.blobStorage, addExt(doc.DocumentID, archive.PagedataExt), bytes.NewReader(newPagedata), known)
+	if err != nil {
+		return err
+	}
+	doc.Files = append(docFiles, contentEntry, pagedataEntry)
+	if err := doc.setContent(contentEntry, patched); err != nil {
+		return err
+	}
+
+	doc.updateSize()
+	if err := doc.Rehash(); err != nil {
+		return err
+	}
+
+	log.Info.Println("Uploading new doc index...", doc.Hash)
+	indexReader, err := doc.IndexReader()
+	if err != nil {
+		return err
+	}
+	return ctx.blobStorage.UploadBlob(doc.Hash, addExt(doc.DocumentID, archive.DocSchemaExt), indexReader)
+}
+
+// readFiles downloads the .content and the .pagedata among the files of the document docId,
+// the .pagedata is empty if there is none
+func (ctx *ApiCtx) readFiles(docId string, files []Entry) (content, pagedata []byte, err error) {
+	for _, f := range files {
+		var data *[]byte
+		switch f.DocumentID {
+		case addExt(docId, archive.ContentExt):
+			data = &content
+		case addExt(docId, archive.PagedataExt):
+			data = &pagedata
+		default:
+			continue
+		}
+
+		reader, err := ctx.blobStorage.GetReader(f.Hash, f.DocumentID)
+		if err != nil {
+			return nil, nil, err
+		}
+		*data, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if content == nil {
+		return nil, nil, errors.New("the document has no content")
+	}
+	return content, pagedata, nil
+}
+
+// readPagesFiles returns the parsed .content and the .pagedata of the document docId
+func (ctx *ApiCtx) readPagesFiles(docId string, files []Entry) (*archive.Content, []byte, error) {
+	data, pagedata, err := ctx.readFiles(docId, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	content := &archive.Content{}
+	if err := json.Unmarshal(data, content); err != nil {
+		return nil, nil, err
+	}
+	return content, pagedata, nil
+}
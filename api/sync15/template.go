@@ -0,0 +1,70 @@
+package sync15
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/model"
+)
+
+// UploadTemplate uploads a custom template with its image of type ext, svg or png, read from r.
+// The template is added to the root index like a document, see archive.PrepareTemplate for its files.
+func (ctx *ApiCtx) UploadTemplate(template *archive.Template, ext string, r io.Reader, notify bool) (*model.Document, error) {
+	image, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	files, id, err := archive.PrepareTemplate(template, ext, image)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := NewBlobDoc(template.Name, id, model.TemplateType, "")
+	err = ctx.uploadMemoryFiles(doc, files)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.addDoc(doc, notify)
+	if err != nil {
+		return nil, err
+	}
+	return doc.ToDocument(), nil
+}
+
+// DocumentTemplate returns the description of the template docId, read from its .template file.
+// Unknown keys of templates synced by the tablet are ignored.
+func (ctx *ApiCtx) DocumentTemplate(docId string) (*archive.Template, error) {
+	ctx.mu.RLock()
+	doc, err := ctx.hashTree.FindDoc(docId)
+	var entry *Entry
+	if err == nil {
+		for _, f := range doc.Files {
+			if f.DocumentID == addExt(docId, archive.TemplateExt) {
+				file := *f
+				entry = &file
+			}
+		}
+	}
+	ctx.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.New("the document is not a template")
+	}
+
+	reader, err := ctx.blobStorage.GetReader(entry.Hash, entry.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	template := &archive.Template{}
+	if err := json.NewDecoder(reader).Decode(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
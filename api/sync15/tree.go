@@ -11,6 +11,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"sync"
 
 	"github.com/juruen/rmapi/archive"
 	"github.com/juruen/rmapi/log"
@@ -190,11 +191,14 @@ func (t *HashTree) IndexReader() (io.Reader, error) {
 }
 
 type HashTree struct {
-	Hash         string
-	Generation   int64
+	Hash          string
+	Generation    int64
 	SchemaVersion string
-	Docs         []*BlobDoc
-	CacheVersion int
+	Docs          []*BlobDoc
+	CacheVersion  int
+
+	// where the tree is cached, not cached if empty
+	cachePath string
 }
 
 func (t *HashTree) FindDoc(id string) (*BlobDoc, error) {
@@ -277,6 +281,12 @@ func addExt(name string, ext archive.RmExt) string {
 
 // / Mirror makes the tree look like the storage
 func (t *HashTree) Mirror(r RemoteStorage, maxconcurrent int) error {
+	return t.mirror(r, maxconcurrent, false)
+}
+
+// mirror is Mirror, cacheLocked tells whether the caller holds the cache lock
+// that the checkpoints need
+func (t *HashTree) mirror(r RemoteStorage, maxconcurrent int, cacheLocked bool) error {
 	rootHash, gen, err := r.GetRootIndex()
 	if err != nil && err != transport.ErrNotFound {
 		return err
@@ -318,22 +328,33 @@ func (t *HashTree) Mirror(r RemoteStorage, maxconcurrent int) error {
 	}
 	wg, ctx := errgroup.WithContext(context.TODO())
 	wg.SetLimit(maxconcurrent)
+	checkpoint := &mirrorCheckpoint{tree: t, locked: cacheLocked}
 
-	//current documents
+	//current documents, the unchanged ones are kept before the workers start
+	var updated []*BlobDoc
 	for _, doc := range t.Docs {
 		if entry, ok := new[doc.DocumentID]; ok {
 			head = append(head, doc)
 			current[doc.DocumentID] = doc
 
 			if entry.Hash != doc.Hash {
-				log.Info.Println("doc updated: ", doc.DocumentID)
-				e := entry
-				d := doc
-				wg.Go(func() error {
-					return d.Mirror(e, r)
-				})
+				updated = append(updated, doc)
+			} else {
+				checkpoint.docs = append(checkpoint.docs, doc)
 			}
 		}
+	}
+	for _, doc := range updated {
+		log.Info.Println("doc updated: ", doc.DocumentID)
+		e := new[doc.DocumentID]
+		d := doc
+		wg.Go(func() error {
+			if err := d.Mirror(e, r); err != nil {
+				return err
+			}
+			checkpoint.add(d)
+			return nil
+		})
 		select {
 		case <-ctx.Done():
 			goto EXIT
@@ -349,7 +370,11 @@ func (t *HashTree) Mirror(r RemoteStorage, maxconcurrent int) error {
 			head = append(head, doc)
 			e := newEntry
 			wg.Go(func() error {
-				return doc.Mirror(e, r)
+				if err := doc.Mirror(e, r); err != nil {
+					return err
+				}
+				checkpoint.add(doc)
+				return nil
 			})
 		}
 		select {
@@ -361,7 +386,9 @@ func (t *HashTree) Mirror(r RemoteStorage, maxconcurrent int) error {
 EXIT:
 	err = wg.Wait()
 	if err != nil {
-		return fmt.Errorf("was not ok: %v", err)
+		// keep what was mirrored so far
+		checkpoint.save()
+		return fmt.Errorf("was not ok: %w", err)
 	}
 	sort.Slice(head, func(i, j int) bool { return head[i].DocumentID < head[j].DocumentID })
 	t.Docs = head
@@ -370,6 +397,67 @@ EXIT:
 	return nil
 }
 
+// number of mirrored documents between two checkpoints
+var checkpointDocs = 1000
+
+// mirrorCheckpoint saves the documents mirrored so far into the cache,
+// so that an interrupted mirror resumes instead of starting over
+type mirrorCheckpoint struct {
+	tree *HashTree
+	// locked tells whether the cache lock is already held
+	locked bool
+
+	mu    sync.Mutex
+	docs  []*BlobDoc
+	added int
+}
+
+// add records a mirrored document, the document must not be changed afterwards
+func (c *mirrorCheckpoint) add(doc *BlobDoc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.docs = append(c.docs, doc)
+	c.added++
+	if c.added%checkpointDocs == 0 {
+		c.write()
+	}
+}
+
+func (c *mirrorCheckpoint) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.write()
+}
+
+func (c *mirrorCheckpoint) write() {
+	if c.tree.cachePath == "" || c.added == 0 {
+		return
+	}
+
+	docs := make([]*BlobDoc, len(c.docs))
+	copy(docs, c.docs)
+	sort.Slice(docs, func(i, j int) bool { return docs[i].DocumentID < docs[j].DocumentID })
+
+	// the old root hash makes the next mirror compare every document
+	snapshot := &HashTree{
+		Hash:          c.tree.Hash,
+		Generation:    c.tree.Generation,
+		SchemaVersion: c.tree.SchemaVersion,
+		Docs:          docs,
+		cachePath:     c.tree.cachePath,
+	}
+	log.Info.Printf("checkpoint: %d documents mirrored", c.added)
+	// the cache is replaced atomically, it can't be corrupted by another process
+	write := saveTree
+	if c.locked {
+		write = writeTree
+	}
+	if err := write(snapshot); err != nil {
+		log.Warning.Println("failed to write the checkpoint", err)
+	}
+}
+
 func BuildTree(provider RemoteStorage) (*HashTree, error) {
 	tree := HashTree{}
 
@@ -0,0 +1,128 @@
+package sync15
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// knownHashes returns the hashes of all the files in the tree, the server already has these blobs
+func (t *HashTree) knownHashes() map[string]bool {
+	known := make(map[string]bool)
+	for _, d := range t.Docs {
+		for _, f := range d.Files {
+			known[f.Hash] = true
+		}
+	}
+	return known
+}
+
+// knownHashes returns the hashes of all the files in the tree
+func (ctx *ApiCtx) knownHashes() map[string]bool {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.hashTree.knownHashes()
+}
+
+// uploadBlob uploads the content of reader as the file name of a document and returns its entry.
+// The upload is skipped when the hash is known.
+func uploadBlob(b *BlobStorage, name string, reader io.ReadSeeker, known map[string]bool) (*Entry, error) {
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fileEntry := &Entry{
+		DocumentID: name,
+		Hash:       hex.EncodeToString(hasher.Sum(nil)),
+		Type:       FileType,
+		Size:       size,
+	}
+	if known[fileEntry.Hash] {
+		log.Info.Printf("File %s is already uploaded", name)
+		return fileEntry, nil
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	//does not accept rm-file in header
+	err = b.UploadBlob(fileEntry.Hash, name, reader)
+	if err != nil {
+		return nil, err
+	}
+	return fileEntry, nil
+}
+
+// uploadFiles uploads the files of a new document in parallel and adds them to doc,
+// open returns the content of the i-th file
+func (ctx *ApiCtx) uploadFiles(doc *BlobDoc, names []string, open func(i int) (io.ReadSeekCloser, error)) error {
+	known := ctx.knownHashes()
+	entries := make([]*Entry, len(names))
+
+	var wg errgroup.Group
+	wg.SetLimit(concurrent)
+	for i, name := range names {
+		wg.Go(func() error {
+			reader, err := open(i)
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+
+			entries[i], err = uploadBlob(ctx.blobStorage, name, reader, known)
+			return err
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := doc.AddFile(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadLocalFiles uploads the prepared files of a document
+func (ctx *ApiCtx) uploadLocalFiles(doc *BlobDoc, files []archive.NamePath) error {
+	names := make([]string, len(files))
+	for i, f := range files {
+		log.Info.Printf("File %s, path: %s", f.Name, f.Path)
+		names[i] = f.Name
+	}
+
+	return ctx.uploadFiles(doc, names, func(i int) (io.ReadSeekCloser, error) {
+		return os.Open(files[i].Path)
+	})
+}
+
+// uploadMemoryFiles uploads the in-memory files of a document
+func (ctx *ApiCtx) uploadMemoryFiles(doc *BlobDoc, files []archive.NameContent) error {
+	names := make([]string, len(files))
+	for i, f := range files {
+		log.Info.Printf("File %s, size: %d", f.Name, len(f.Content))
+		names[i] = f.Name
+	}
+
+	return ctx.uploadFiles(doc, names, func(i int) (io.ReadSeekCloser, error) {
+		return nopCloser{bytes.NewReader(files[i].Content)}, nil
+	})
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -2,6 +2,7 @@ package archive
 
 import (
 	"archive/zip"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,6 +26,7 @@ const (
 	DocSchemaExt RmExt = "docSchema"
 	MetadataExt  RmExt = "metadata"
 	ContentExt   RmExt = "content"
+	PagedataExt  RmExt = "pagedata"
 )
 
 type NamePath struct {
@@ -46,8 +48,9 @@ func (d *DocumentFiles) AddMap(name, filepath string, filetype RmExt) {
 	d.Files = append(d.Files, fs)
 }
 
-// Prepare prepares a file for uploading (creates needed temp files or unpacks a zip)
-func Prepare(name, parentId, sourceDocPath, ext, tmpDir string, coverpage *int) (files *DocumentFiles, id string, err error) {
+// Prepare prepares a file for uploading (creates needed temp files or unpacks a zip),
+// the .content gets the settings of layout unless it is nil
+func Prepare(name, parentId, sourceDocPath, ext, tmpDir string, layout *Layout) (files *DocumentFiles, id string, err error) {
 	files = &DocumentFiles{}
 	if ext == util.ZIP || ext == util.RMDOC {
 		var metadataPath string
@@ -72,6 +75,14 @@ func Prepare(name, parentId, sourceDocPath, ext, tmpDir string, coverpage *int)
 				return
 			}
 		}
+		for _, f := range files.Files {
+			if f.FileType == ContentExt {
+				err = applyLayoutFile(f.Path, layout)
+				if err != nil {
+					return
+				}
+			}
+		}
 	} else {
 		id = uuid.New().String()
 		objectName := id + "." + ext
@@ -91,7 +102,7 @@ func Prepare(name, parentId, sourceDocPath, ext, tmpDir string, coverpage *int)
 		}
 		files.AddMap(objectName, filePath, MetadataExt)
 
-		objectName, filePath, err = CreateContent(id, doctype, tmpDir, pageIds, coverpage)
+		objectName, filePath, err = CreateContent(id, doctype, tmpDir, pageIds, layout)
 		if err != nil {
 			return
 		}
@@ -100,26 +111,159 @@ func Prepare(name, parentId, sourceDocPath, ext, tmpDir string, coverpage *int)
 	return files, id, err
 }
 
+// NameContent is an in-memory file of a document
+type NameContent struct {
+	Name     string
+	Content  []byte
+	FileType RmExt
+}
+
+// PrepareFrom prepares a document read from r for uploading, like Prepare but without temp files
+func PrepareFrom(name, parentId, ext string, r io.Reader, layout *Layout) (files []NameContent, id string, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	if ext == util.ZIP || ext == util.RMDOC {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, "", err
+		}
+
+		hasMetadata := false
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			ext := strings.TrimPrefix(filepath.Ext(f.Name), ".")
+			if ext == string(ContentExt) {
+				id = strings.TrimSuffix(f.Name, path.Ext(f.Name))
+			}
+
+			content, err := readZipFile(f)
+			if err != nil {
+				return nil, "", err
+			}
+
+			if ext == string(MetadataExt) {
+				hasMetadata = true
+				content, err = fixMetadata(parentId, name, content)
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			if ext == string(ContentExt) {
+				content, err = applyLayout(content, layout)
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			files = append(files, NameContent{f.Name, content, RmExt(ext)})
+		}
+		if id == "" {
+			return nil, "", errors.New("could not determine the Document UUID")
+		}
+		if !hasMetadata {
+			log.Warning.Println("missing metadata, creating...", name)
+			content, err := metadataBytes(name, parentId, model.DocumentType)
+			if err != nil {
+				return nil, "", err
+			}
+			files = append(files, NameContent{id + "." + string(MetadataExt), content, MetadataExt})
+		}
+		return files, id, nil
+	}
+
+	id = uuid.New().String()
+	objectName := id + "." + ext
+	doctype := ext
+	var pageIds []string
+	if ext == util.RM {
+		pageId := uuid.New().String()
+		objectName = fmt.Sprintf("%s/%s.rm", id, pageId)
+		doctype = "notebook"
+		pageIds = []string{pageId}
+	}
+	files = append(files, NameContent{objectName, data, RmExt(doctype)})
+
+	metadata, err := metadataBytes(name, parentId, model.DocumentType)
+	if err != nil {
+		return
+	}
+	files = append(files, NameContent{id + "." + string(MetadataExt), metadata, MetadataExt})
+
+	content, err := contentBytes(doctype, pageIds, layout)
+	if err != nil {
+		return
+	}
+	files = append(files, NameContent{id + "." + string(ContentExt), content, ContentExt})
+
+	return files, id, nil
+}
+
+// applyLayout returns the .content data with the settings of layout
+func applyLayout(data []byte, layout *Layout) ([]byte, error) {
+	if layout == nil || layout.IsEmpty() {
+		return data, nil
+	}
+	patched, _, err := PatchContent(data, func(content *Content) error {
+		layout.Apply(content)
+		return nil
+	})
+	return patched, err
+}
+
+// applyLayoutFile sets the settings of layout in the .content file at path
+func applyLayoutFile(path string, layout *Layout) error {
+	if layout == nil || layout.IsEmpty() {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = applyLayout(data, layout)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
 // FixMetadata fixes the metadata with the new parent and filename
 func FixMetadata(parentId, name, path string) error {
-	meta := MetadataFile{}
 	metaData, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(metaData, &meta)
+
+	metaData, err = fixMetadata(parentId, name, metaData)
 	if err != nil {
 		return err
 	}
+	return os.WriteFile(path, metaData, 0600)
+}
+
+func fixMetadata(parentId, name string, metaData []byte) ([]byte, error) {
+	meta := MetadataFile{}
+	err := json.Unmarshal(metaData, &meta)
+	if err != nil {
+		return nil, err
+	}
 	meta.Parent = parentId
 	meta.DocName = name
 	meta.LastModified = UnixTimestamp()
 
-	metaData, err = json.Marshal(meta)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, metaData, 0600)
+	return json.Marshal(meta)
 }
 
 // Unpack unpacks a rmapi .zip file
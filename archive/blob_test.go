@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/juruen/rmapi/util"
+)
+
+func TestPrepareFromPdf(t *testing.T) {
+	file, err := os.Open("zipdoc_test.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	files, id, err := PrepareFrom("doc", "parent", util.PDF, file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+	if files[0].Name != id+".pdf" || len(files[0].Content) == 0 {
+		t.Errorf("unexpected payload %s", files[0].Name)
+	}
+
+	meta := MetadataFile{}
+	if err := json.Unmarshal(files[1].Content, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.DocName != "doc" || meta.Parent != "parent" {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+}
+
+func TestPrepareFromZip(t *testing.T) {
+	file, err := os.Open("test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	files, id, err := PrepareFrom("renamed", "parent", util.ZIP, file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "384327f5-133e-49c8-82ff-30aa19f3cfa4" {
+		t.Errorf("unexpected id %s", id)
+	}
+
+	for _, f := range files {
+		if f.FileType != MetadataExt {
+			continue
+		}
+		meta := MetadataFile{}
+		if err := json.Unmarshal(f.Content, &meta); err != nil {
+			t.Fatal(err)
+		}
+		if meta.DocName != "renamed" || meta.Parent != "parent" {
+			t.Errorf("metadata was not fixed %+v", meta)
+		}
+		return
+	}
+	t.Error("missing metadata")
+}
+
+func TestPrepareFromLayout(t *testing.T) {
+	landscape := "landscape"
+	layout := &Layout{Orientation: &landscape}
+	for _, ext := range []string{util.PDF, util.ZIP} {
+		name := "zipdoc_test.pdf"
+		if ext == util.ZIP {
+			name = "test.zip"
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		files, _, err := PrepareFrom("doc", "parent", ext, file, layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, f := range files {
+			if f.FileType != ContentExt {
+				continue
+			}
+			found = true
+			content := Content{}
+			if err := json.Unmarshal(f.Content, &content); err != nil {
+				t.Fatal(err)
+			}
+			if content.Orientation != landscape {
+				t.Errorf("%s: the layout was not set %+v", ext, content)
+			}
+		}
+		if !found {
+			t.Errorf("%s: missing content", ext)
+		}
+	}
+}
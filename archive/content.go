@@ -0,0 +1,333 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// CPages is the page list of the newer .content format. Only the fields needed to
+// identify and order the pages are decoded, the other ones are kept as they are
+// when it is encoded again.
+type CPages struct {
+	Pages []CPage `json:"pages"`
+	// other are the fields unknown to CPages, like the last opened page and the authors
+	other map[string]json.RawMessage
+}
+
+// CPage is a page of CPages
+type CPage struct {
+	ID  string       `json:"id"`
+	Idx CrdtValue    `json:"idx"`
+	Del *CrdtNumeric `json:"deleted,omitempty"`
+	// Redir is the page of the PDF or EPUB shown by the page
+	Redir *CrdtNumeric `json:"redir,omitempty"`
+	// Template is the background template of the page
+	Template *CrdtValue `json:"template,omitempty"`
+	// other are the fields unknown to CPage, like the scroll position
+	other map[string]json.RawMessage
+}
+
+type cPagesFields CPages
+type cPageFields CPage
+
+func (c *CPages) UnmarshalJSON(data []byte) error {
+	other, err := unmarshalFields(data, (*cPagesFields)(c), "pages")
+	c.other = other
+	return err
+}
+
+func (c CPages) MarshalJSON() ([]byte, error) {
+	return marshalFields(cPagesFields(c), c.other)
+}
+
+func (p *CPage) UnmarshalJSON(data []byte) error {
+	other, err := unmarshalFields(data, (*cPageFields)(p), "id", "idx", "deleted", "redir", "template")
+	p.other = other
+	return err
+}
+
+func (p CPage) MarshalJSON() ([]byte, error) {
+	return marshalFields(cPageFields(p), p.other)
+}
+
+// unmarshalFields decodes data into v and returns the fields of data other than known
+func unmarshalFields(data []byte, v interface{}, known ...string) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, k := range known {
+		delete(fields, k)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// marshalFields encodes v along with the other fields
+func marshalFields(v interface{}, other map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(other) == 0 {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range other {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// CrdtValue is a string value with its timestamp
+type CrdtValue struct {
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	Value     string          `json:"value"`
+}
+
+// CrdtNumeric is a numeric value with its timestamp
+type CrdtNumeric struct {
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	Value     int             `json:"value"`
+}
+
+// PageIDs returns the ids of the pages in order
+func (c *Content) PageIDs() []string {
+	if len(c.Pages) > 0 || c.CPages == nil {
+		return c.Pages
+	}
+
+	pages := c.cPages()
+	ids := make([]string, len(pages))
+	for i, p := range pages {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// cPages returns the pages of cPages which aren't deleted in order
+func (c *Content) cPages() []CPage {
+	pages := make([]CPage, 0, len(c.CPages.Pages))
+	for _, p := range c.CPages.Pages {
+		if p.Del != nil && p.Del.Value != 0 {
+			continue
+		}
+		pages = append(pages, p)
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Idx.Value < pages[j].Idx.Value })
+	return pages
+}
+
+// TagNames returns the names of the document tags
+func (c *Content) TagNames() []string {
+	names := []string{}
+	for _, t := range c.DocumentTags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// AddTag adds a document tag, or a page tag if pageID is set. It returns false if the tag exists.
+func (c *Content) AddTag(name, pageID string) bool {
+	now := time.Now().UnixMilli()
+	if pageID != "" {
+		for _, t := range c.PageTags {
+			if t.Name == name && t.PageID == pageID {
+				return false
+			}
+		}
+		c.PageTags = append(c.PageTags, PageTag{Name: name, PageID: pageID, Timestamp: now})
+		return true
+	}
+
+	for _, t := range c.DocumentTags {
+		if t.Name == name {
+			return false
+		}
+	}
+	c.DocumentTags = append(c.DocumentTags, Tag{Name: name, Timestamp: now})
+	return true
+}
+
+// RemoveTag removes a document tag, or a page tag if pageID is set. It returns false if the tag is missing.
+func (c *Content) RemoveTag(name, pageID string) bool {
+	found := false
+	if pageID != "" {
+		tags := []PageTag{}
+		for _, t := range c.PageTags {
+			if t.Name == name && t.PageID == pageID {
+				found = true
+				continue
+			}
+			tags = append(tags, t)
+		}
+		c.PageTags = tags
+		return found
+	}
+
+	tags := []Tag{}
+	for _, t := range c.DocumentTags {
+		if t.Name == name {
+			found = true
+			continue
+		}
+		tags = append(tags, t)
+	}
+	c.DocumentTags = tags
+	return found
+}
+
+// RenameTag renames the document and page tags old to new. It returns false if old is missing.
+func (c *Content) RenameTag(old, new string) bool {
+	found := false
+	now := time.Now().UnixMilli()
+
+	tags := []Tag{}
+	hasNew := false
+	for _, t := range c.DocumentTags {
+		hasNew = hasNew || t.Name == new
+	}
+	for _, t := range c.DocumentTags {
+		if t.Name == old {
+			found = true
+			if hasNew {
+				continue
+			}
+			t.Name = new
+			t.Timestamp = now
+			hasNew = true
+		}
+		tags = append(tags, t)
+	}
+	c.DocumentTags = tags
+
+	pageTags := []PageTag{}
+	seen := make(map[PageTag]bool)
+	for _, t := range c.PageTags {
+		if t.Name == new {
+			seen[PageTag{Name: new, PageID: t.PageID}] = true
+		}
+	}
+	for _, t := range c.PageTags {
+		if t.Name == old {
+			found = true
+			key := PageTag{Name: new, PageID: t.PageID}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			t.Name = new
+			t.Timestamp = now
+		}
+		pageTags = append(pageTags, t)
+	}
+	c.PageTags = pageTags
+
+	return found
+}
+
+// PatchContent applies update to the .content data. Only the fields changed by update
+// are rewritten, the fields unknown to Content are kept as they are.
+// It returns false if nothing was changed.
+func PatchContent(data []byte, update func(content *Content) error) ([]byte, bool, error) {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, false, err
+	}
+
+	content := Content{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, false, err
+	}
+	before, err := contentFields(&content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := update(&content); err != nil {
+		return nil, false, err
+	}
+	after, err := contentFields(&content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := false
+	for k, v := range after {
+		if !bytes.Equal(before[k], v) {
+			raw[k] = v
+			changed = true
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			delete(raw, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return data, false, nil
+	}
+
+	patched, err := json.Marshal(raw)
+	return patched, true, err
+}
+
+func contentFields(content *Content) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(b, &fields)
+	return fields, err
+}
+
+// Layout holds the reading settings of a document, the nil fields are left unchanged
+type Layout struct {
+	// Orientation is "portrait" or "landscape"
+	Orientation *string
+	FontName    *string
+	Margins     *int
+	TextScale   *float64
+	LineHeight  *int
+	// CoverPageNumber is the page used as cover, -1 for the last opened page
+	CoverPageNumber *int
+}
+
+// IsEmpty returns true if the layout doesn't change anything
+func (l *Layout) IsEmpty() bool {
+	return *l == Layout{}
+}
+
+// Apply sets the settings of the layout in c
+func (l *Layout) Apply(c *Content) {
+	if l.Orientation != nil {
+		c.Orientation = *l.Orientation
+	}
+	if l.FontName != nil {
+		c.FontName = *l.FontName
+	}
+	if l.Margins != nil {
+		c.Margins = *l.Margins
+	}
+	if l.TextScale != nil {
+		c.TextScale = *l.TextScale
+	}
+	if l.LineHeight != nil {
+		c.LineHeight = *l.LineHeight
+	}
+	if l.CoverPageNumber != nil {
+		page := *l.CoverPageNumber
+		c.CoverPageNumber = &page
+	}
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testContent = `{
+  "cPages": {
+    "lastOpened": {"timestamp": "1:1", "value": "p2"},
+    "pages": [
+      {"id": "p2", "idx": {"timestamp": "1:2", "value": "bb"}},
+      {"id": "p1", "idx": {"timestamp": "1:2", "value": "ba"}, "template": {"value": "Blank"}},
+      {"id": "p3", "idx": {"timestamp": "1:2", "value": "bc"}, "deleted": {"timestamp": "1:3", "value": 1}}
+    ]
+  },
+  "fileType": "notebook",
+  "formatVersion": 2,
+  "tags": [{"name": "ml", "timestamp": 1}]
+}`
+
+func TestPageIDs(t *testing.T) {
+	content := Content{}
+	assert.NoError(t, json.Unmarshal([]byte(testContent), &content))
+	assert.Equal(t, []string{"p1", "p2"}, content.PageIDs())
+
+	content.Pages = []string{"a", "b"}
+	assert.Equal(t, []string{"a", "b"}, content.PageIDs())
+}
+
+func TestPatchContent(t *testing.T) {
+	patched, changed, err := PatchContent([]byte(testContent), func(c *Content) error {
+		assert.True(t, c.AddTag("todo", "p1"))
+		assert.True(t, c.RenameTag("ml", "machine learning"))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	raw := map[string]json.RawMessage{}
+	assert.NoError(t, json.Unmarshal(patched, &raw))
+	// unknown fields are kept
+	assert.JSONEq(t, "2", string(raw["formatVersion"]))
+	assert.Contains(t, string(raw["cPages"]), "lastOpened")
+	assert.Contains(t, string(raw["cPages"]), "Blank")
+
+	content := Content{}
+	assert.NoError(t, json.Unmarshal(patched, &content))
+	assert.Equal(t, []string{"machine learning"}, content.TagNames())
+	assert.Equal(t, "p1", content.PageTags[0].PageID)
+
+	_, changed, err = PatchContent(patched, func(c *Content) error {
+		assert.False(t, c.AddTag("todo", "p1"))
+		assert.False(t, c.RemoveTag("missing", ""))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestRenameTagMerges(t *testing.T) {
+	c := Content{
+		DocumentTags: []Tag{{Name: "a"}, {Name: "b"}},
+		PageTags:     []PageTag{{Name: "a", PageID: "p"}, {Name: "b", PageID: "p"}, {Name: "a", PageID: "q"}},
+	}
+	assert.True(t, c.RenameTag("a", "b"))
+	assert.Equal(t, []string{"b"}, c.TagNames())
+	assert.Len(t, c.PageTags, 2)
+}
+
+func TestLayoutApply(t *testing.T) {
+	c := Content{Orientation: "portrait", Margins: 100, TextScale: 1}
+	orientation, scale := "landscape", 1.5
+	l := Layout{Orientation: &orientation, TextScale: &scale}
+	assert.False(t, l.IsEmpty())
+	l.Apply(&c)
+	assert.Equal(t, "landscape", c.Orientation)
+	assert.Equal(t, 1.5, c.TextScale)
+	assert.Equal(t, 100, c.Margins)
+	assert.Nil(t, c.CoverPageNumber)
+	assert.True(t, (&Layout{}).IsEmpty())
+}
@@ -12,8 +12,11 @@ type Zip struct {
 	Content Content
 	Pages   []Page
 	Payload []byte
-	UUID    string
-	pageMap map[string]int
+	// Rendition is the PDF of an EPUB paginated by the device, the pages and their
+	// annotations follow it. Nil when the device hasn't synced it.
+	Rendition []byte
+	UUID      string
+	pageMap   map[string]int
 }
 
 // NewZip creates a File with sane defaults.
@@ -75,6 +78,8 @@ type Page struct {
 	Pagedata string
 	// page number of the underlying document
 	DocPage int
+	// Highlights are the highlights of the older firmwares, see Zip.Highlights
+	Highlights []Highlight
 }
 
 // Metadata represents the structure of a .metadata json file associated to a page.
@@ -121,7 +126,19 @@ type Content struct {
 	RedirectionMap  []int     `json:"redirectionPageMap"`
 	TextScale       float64   `json:"textScale"`
 	CoverPageNumber *int      `json:"coverPageNumber,omitempty"`
-
+	// CPages replaces Pages in the newer format
+	CPages *CPages `json:"cPages,omitempty"`
+
+	// ZoomMode is how the newer firmwares show the pages: "bestFit", "fitToWidth",
+	// "fitToHeight" or "customFit"
+	ZoomMode string `json:"zoomMode,omitempty"`
+	// CustomZoomPageWidth and CustomZoomPageHeight are the size of the pages of a PDF
+	// in the coordinates of the v6 files, 0 when unknown
+	CustomZoomPageWidth  float64 `json:"customZoomPageWidth,omitempty"`
+	CustomZoomPageHeight float64 `json:"customZoomPageHeight,omitempty"`
+
+	// Transform is the zoom and crop of the pages of the older firmwares, it is read
+	// from the "transform" field but not written back
 	Transform *Transform `json:"-"`
 }
 
@@ -143,7 +160,8 @@ type ExtraMetadata struct {
 	LastFinelinerv2Size      string `json:"LastFinelinerv2Size"`
 }
 
-// Transform is a struct contained into a Content struct.
+// Transform is a struct contained into a Content struct. It maps the pages
+// to the screen like a QTransform: x' = m11*x + m21*y + m31, y' = m12*x + m22*y + m32.
 type Transform struct {
 	M11 float32 `json:"m11"`
 	M12 float32 `json:"m12"`
@@ -171,4 +189,6 @@ type MetadataFile struct {
 	Modified         bool   `json:"modified"`
 	Deleted          bool   `json:"deleted"`
 	MetadataModified bool   `json:"metadatamodified"`
+	// TrashedFrom is the parent of an entry moved to the trash by rmapi
+	TrashedFrom string `json:"rmapiTrashedFrom,omitempty"`
 }
@@ -0,0 +1,161 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/juruen/rmapi/encoding/rm"
+)
+
+// A Highlight is a text highlighted in a PDF or an EPUB.
+type Highlight struct {
+	Text string
+	// Page is the page of the PDF or EPUB starting at 1, 0 for the pages added on the device
+	Page  int
+	Color rm.BrushColor
+	// Start is the offset of the text in the page, -1 if unknown
+	Start  int
+	Length int
+	// Rects cover the text in the coordinates of the device
+	Rects []rm.Rect
+}
+
+// highlightsFile is the content of a <page>.json file in the .highlights
+// folder of the documents written by the older firmwares
+type highlightsFile struct {
+	// Highlights has a list of highlights per layer
+	Highlights [][]struct {
+		Text   string `json:"text"`
+		Color  int    `json:"color"`
+		Start  int    `json:"start"`
+		Length int    `json:"length"`
+		Rects  []struct {
+			X      float64 `json:"x"`
+			Y      float64 `json:"y"`
+			Width  float64 `json:"width"`
+			Height float64 `json:"height"`
+		} `json:"rects"`
+	} `json:"highlights"`
+}
+
+// readHighlights reads the highlights of the older firmwares, the newer ones store
+// them in the .rm files
+func (z *Zip) readHighlights(zr *zip.Reader) error {
+	for _, file := range zr.File {
+		if !strings.HasSuffix(path.Dir(file.Name), ".highlights") {
+			continue
+		}
+		name, ext := splitExt(path.Base(file.Name))
+		if ext != ".json" {
+			continue
+		}
+
+		idx, err := z.pageIndex(name)
+		if err != nil {
+			return err
+		}
+		if idx < 0 || len(z.Pages) <= idx {
+			return fmt.Errorf("highlights of unknown page %s", name)
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		var content highlightsFile
+		if err := json.Unmarshal(data, &content); err != nil {
+			return fmt.Errorf("highlights of page %s: %w", name, err)
+		}
+
+		page := &z.Pages[idx]
+		for _, layer := range content.Highlights {
+			for _, h := range layer {
+				highlight := Highlight{
+					Text:   h.Text,
+					Page:   page.DocPage + 1,
+					Color:  rm.BrushColor(h.Color),
+					Start:  h.Start,
+					Length: h.Length,
+				}
+				if h.Color == 0 {
+					// the first firmwares only had yellow highlights
+					highlight.Color = rm.Yellow
+				}
+				for _, r := range h.Rects {
+					highlight.Rects = append(highlight.Rects, rm.Rect{X: r.X, Y: r.Y, W: r.Width, H: r.Height})
+				}
+				page.Highlights = append(page.Highlights, highlight)
+			}
+		}
+	}
+	return nil
+}
+
+// Highlights returns the highlights of the document in the order of the pages, the
+// highlights of a page are in the order of the text.
+func (z *Zip) Highlights() []Highlight {
+	var result []Highlight
+	for i := range z.Pages {
+		result = append(result, z.Pages[i].AllHighlights()...)
+	}
+	return result
+}
+
+// AllHighlights returns the highlights of the older firmwares and those of the
+// .rm file of the page in the order of the text.
+func (p *Page) AllHighlights() []Highlight {
+	highlights := append([]Highlight{}, p.Highlights...)
+	if p.Data != nil && p.Data.Scene != nil && p.Data.Scene.Root != nil {
+		for _, g := range p.Data.Scene.Root.GlyphRanges() {
+			h := Highlight{
+				Text:   g.Text,
+				Page:   p.DocPage + 1,
+				Color:  g.Color,
+				Start:  g.Start,
+				Length: g.Length,
+			}
+			// the x axis of the v6 files starts in the middle of the page
+			for _, r := range g.Rects {
+				r.X += float64(rm.Width) / 2
+				h.Rects = append(h.Rects, r)
+			}
+			highlights = append(highlights, h)
+		}
+	}
+	sortHighlights(highlights)
+	return highlights
+}
+
+// sortHighlights sorts the highlights of a page by offset, or by position
+// when an offset is unknown
+func sortHighlights(highlights []Highlight) {
+	byStart := true
+	for _, h := range highlights {
+		if h.Start < 0 {
+			byStart = false
+		}
+	}
+	sort.SliceStable(highlights, func(i, j int) bool {
+		if byStart {
+			return highlights[i].Start < highlights[j].Start
+		}
+		a, b := highlights[i].Rects, highlights[j].Rects
+		if len(a) == 0 || len(b) == 0 {
+			return len(a) < len(b)
+		}
+		if a[0].Y != b[0].Y {
+			return a[0].Y < b[0].Y
+		}
+		return a[0].X < b[0].X
+	})
+}
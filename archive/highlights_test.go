@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlights(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	id := "0c9f3c4e-3b5e-4a6e-9d5b-6a1f2e3d4c5b"
+	blank, page1, page2 := "5a3e6c0d-8f1b-4f43-9c4e-2b7d9f0e1a2c", "e4b1d2c3-7a6f-4e5d-8c9b-0a1b2c3d4e5f", "9c1d4e6f-0a2b-4c3d-8e5f-6a7b8c9d0e1f"
+	// a blank page was inserted before the first page of the PDF
+	add(id+".content", []byte(`{"fileType":"pdf","pages":["`+blank+`","`+page1+`","`+page2+`"],"redirectionPageMap":[-1,0,1]}`))
+	add(id+".highlights/"+page1+".json", []byte(`{"highlights":[[
+		{"text":"second","start":40,"length":6,"color":4,"rects":[{"x":1,"y":2,"width":3,"height":4}]},
+		{"text":"first","start":10,"length":5,"rects":[]}
+	]]}`))
+
+	scene := &rm.Scene{Root: &rm.Group{ID: rm.RootID, Visible: true, Items: []rm.Item{
+		&rm.Group{ID: rm.CrdtID{Part1: 0, Part2: 11}, Visible: true, Items: []rm.Item{
+			&rm.GlyphRange{Start: -1, Length: 4, Color: rm.Pink, Text: "down", Rects: []rm.Rect{{X: 10, Y: 500, W: 40, H: 20}}},
+			&rm.GlyphRange{Start: -1, Length: 2, Color: rm.Yellow, Text: "up", Rects: []rm.Rect{{X: 10, Y: 100, W: 20, H: 20}}},
+		}},
+	}}}
+	data, err := (&rm.Rm{Version: rm.V6, Scene: scene}).MarshalBinary()
+	assert.NoError(t, err)
+	add(id+"/"+page2+".rm", data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z := NewZip()
+	if !assert.NoError(t, z.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))) {
+		return
+	}
+	assert.Equal(t, []Highlight{
+		{Text: "first", Page: 1, Color: rm.Yellow, Start: 10, Length: 5},
+		{Text: "second", Page: 1, Color: rm.Green, Start: 40, Length: 6, Rects: []rm.Rect{{X: 1, Y: 2, W: 3, H: 4}}},
+		{Text: "up", Page: 2, Color: rm.Yellow, Start: -1, Length: 2, Rects: []rm.Rect{{X: 712, Y: 100, W: 20, H: 20}}},
+		{Text: "down", Page: 2, Color: rm.Pink, Start: -1, Length: 4, Rects: []rm.Rect{{X: 712, Y: 500, W: 40, H: 20}}},
+	}, z.Highlights())
+}
@@ -0,0 +1,319 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// PageInfo describes a page of a document without its drawing
+type PageInfo struct {
+	// ID is the id of the page, its files are named after it
+	ID string
+	// Source is the id of the page whose files the page uses, it is the page itself
+	// for existing pages, another page for duplicates and empty for blank pages
+	Source string
+	// DocPage is the page number of the underlying PDF or EPUB, -1 for inserted pages
+	DocPage int
+	// Pagedata is the name of the background template
+	Pagedata string
+	// Annotated is set if the page has a drawing, it is only informative
+	Annotated bool
+}
+
+// NewBlankPage returns a page without files and with the template
+func NewBlankPage(template string) PageInfo {
+	if template == "" {
+		template = defaultPagadata
+	}
+	return PageInfo{ID: uuid.New().String(), DocPage: -1, Pagedata: template}
+}
+
+// Duplicate returns a copy of the page with a new id sharing its files
+func (p PageInfo) Duplicate() PageInfo {
+	d := p
+	d.ID = uuid.New().String()
+	return d
+}
+
+// ReadPages returns the pages of a document from its .content and .pagedata
+func ReadPages(content *Content, pagedata []byte) ([]PageInfo, error) {
+	if content.CPages != nil && len(content.Pages) == 0 {
+		return pagesFromCPages(content, pagedata), nil
+	}
+
+	templates := readPagedataLines(pagedata)
+	hasDocument := content.FileType == "pdf" || content.FileType == "epub"
+
+	ids := content.Pages
+	if len(ids) == 0 {
+		// the files of the pages of older documents are named after their index
+		for i := 0; i < content.PageCount; i++ {
+			ids = append(ids, strconv.Itoa(i))
+		}
+	}
+
+	pages := make([]PageInfo, len(ids))
+	for i, id := range ids {
+		pages[i] = PageInfo{ID: id, Source: id, DocPage: -1}
+		switch {
+		case i < len(content.RedirectionMap):
+			pages[i].DocPage = content.RedirectionMap[i]
+		case hasDocument:
+			pages[i].DocPage = i
+		}
+		if i < len(templates) {
+			pages[i].Pagedata = templates[i]
+		}
+	}
+	return pages, nil
+}
+
+// pagesFromCPages lists the pages of the cPages format, their template is the one
+// of the .pagedata when the page has none
+func pagesFromCPages(content *Content, pagedata []byte) []PageInfo {
+	templates := readPagedataLines(pagedata)
+	cpages := content.cPages()
+	pages := make([]PageInfo, len(cpages))
+	for i, p := range cpages {
+		// the pages inserted on the device don't show a page of the document
+		pages[i] = PageInfo{ID: p.ID, Source: p.ID, DocPage: -1}
+		if p.Redir != nil {
+			pages[i].DocPage = p.Redir.Value
+		}
+		if p.Template != nil {
+			pages[i].Pagedata = p.Template.Value
+		} else if i < len(templates) {
+			pages[i].Pagedata = templates[i]
+		}
+	}
+	return pages
+}
+
+// SetPages writes pages into content and returns the new .pagedata
+func SetPages(content *Content, pages []PageInfo) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, errors.New("a document needs at least one page")
+	}
+
+	if content.CPages != nil && len(content.Pages) == 0 {
+		setCPages(content.CPages, pages)
+	} else {
+		hasDocument := content.FileType == "pdf" || content.FileType == "epub"
+		content.Pages = make([]string, len(pages))
+		var redirection []int
+		if hasDocument || len(content.RedirectionMap) > 0 {
+			redirection = make([]int, len(pages))
+		}
+		for i, p := range pages {
+			content.Pages[i] = p.ID
+			if redirection != nil {
+				redirection[i] = p.DocPage
+			}
+		}
+		content.RedirectionMap = redirection
+	}
+	content.PageCount = len(pages)
+	if content.LastOpenedPage >= len(pages) {
+		content.LastOpenedPage = len(pages) - 1
+	}
+
+	pageIDs := make(map[string]bool)
+	for _, p := range pages {
+		pageIDs[p.ID] = true
+	}
+	var tags []PageTag
+	for _, tag := range content.PageTags {
+		if pageIDs[tag.PageID] {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) != len(content.PageTags) {
+		content.PageTags = tags
+	}
+
+	var w bytes.Buffer
+	for _, p := range pages {
+		template := p.Pagedata
+		if template == "" {
+			template = defaultPagadata
+		}
+		w.WriteString(template + "\n")
+	}
+	return w.Bytes(), nil
+}
+
+// setCPages changes cPages to list pages in order. The pages are edited like the device
+// does: the values changed get a newer timestamp and the removed pages are marked
+// as deleted, the fields of the pages unknown to CPage are kept. The pages keep their
+// index unless some moved or were added, then all of them are indexed again.
+func setCPages(c *CPages, pages []PageInfo) {
+	clock := newCrdtClock(c)
+	index := make(map[string]int)
+	for i, p := range c.Pages {
+		index[p.ID] = i
+	}
+
+	// the pages are indexed again when they moved or were added
+	reindex := false
+	previous := ""
+	for _, p := range pages {
+		j, ok := index[p.ID]
+		if !ok || c.Pages[j].Idx.Value <= previous {
+			reindex = true
+			break
+		}
+		previous = c.Pages[j].Idx.Value
+	}
+
+	kept := make(map[string]bool)
+	width := idxWidth(len(pages))
+	for i, p := range pages {
+		kept[p.ID] = true
+		j, ok := index[p.ID]
+		if !ok {
+			c.Pages = append(c.Pages, CPage{ID: p.ID})
+			j = len(c.Pages) - 1
+			index[p.ID] = j
+		}
+		page := &c.Pages[j]
+
+		if idx := idxValue(i, width); reindex && page.Idx.Value != idx {
+			page.Idx = CrdtValue{Timestamp: clock.next(), Value: idx}
+		}
+		if page.Del != nil && page.Del.Value != 0 {
+			page.Del = &CrdtNumeric{Timestamp: clock.next(), Value: 0}
+		}
+		switch {
+		case p.DocPage < 0:
+			page.Redir = nil
+		case page.Redir == nil || page.Redir.Value != p.DocPage:
+			page.Redir = &CrdtNumeric{Timestamp: clock.next(), Value: p.DocPage}
+		}
+		if p.Pagedata != "" && (page.Template == nil || page.Template.Value != p.Pagedata) {
+			page.Template = &CrdtValue{Timestamp: clock.next(), Value: p.Pagedata}
+		}
+	}
+
+	for i := range c.Pages {
+		page := &c.Pages[i]
+		if !kept[page.ID] && (page.Del == nil || page.Del.Value == 0) {
+			page.Del = &CrdtNumeric{Timestamp: clock.next(), Value: 1}
+		}
+	}
+}
+
+// idxWidth returns the number of letters of the indexes of n pages
+func idxWidth(n int) int {
+	width := 1
+	for max := 26; max < n; max *= 26 {
+		width++
+	}
+	return width
+}
+
+// idxValue returns the index of the page i, the indexes of the same width sort like
+// the pages: ba, bb... bz for 26 pages, baa, bab... for more
+func idxValue(i, width int) string {
+	idx := make([]byte, width)
+	for j := width - 1; j >= 0; j-- {
+		idx[j] = byte('a' + i%26)
+		i /= 26
+	}
+	return "b" + string(idx)
+}
+
+// A crdtClock makes the timestamps of the changes of cPages, they are newer than
+// those of the document. A timestamp is "<author>:<counter>", the changes are made
+// by the author of the last change.
+type crdtClock struct {
+	author, counter int
+}
+
+func newCrdtClock(c *CPages) *crdtClock {
+	clock := &crdtClock{author: 1}
+	var values []interface{}
+	for _, p := range c.Pages {
+		values = append(values, p.Idx, p.Del, p.Redir, p.Template, p.other)
+	}
+	values = append(values, c.other)
+	data, err := json.Marshal(values)
+	if err != nil {
+		return clock
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return clock
+	}
+	clock.observe(decoded)
+	return clock
+}
+
+// observe moves the clock past the timestamps found in the decoded JSON value
+func (k *crdtClock) observe(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && key == "timestamp" {
+				author, counter, ok := parseTimestamp(s)
+				if ok && counter >= k.counter {
+					k.author, k.counter = author, counter
+				}
+				continue
+			}
+			k.observe(value)
+		}
+	case []interface{}:
+		for _, value := range v {
+			k.observe(value)
+		}
+	}
+}
+
+func (k *crdtClock) next() json.RawMessage {
+	k.counter++
+	return json.RawMessage(strconv.Quote(fmt.Sprintf("%d:%d", k.author, k.counter)))
+}
+
+func parseTimestamp(s string) (author, counter int, ok bool) {
+	a, c, found := strings.Cut(s, ":")
+	if !found {
+		return 0, 0, false
+	}
+	author, err := strconv.Atoi(a)
+	if err != nil {
+		return 0, 0, false
+	}
+	counter, err = strconv.Atoi(c)
+	return author, counter, err == nil
+}
+
+func readPagedataLines(pagedata []byte) []string {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(pagedata))
+	for sc.Scan() {
+		lines = append(lines, strings.TrimSpace(sc.Text()))
+	}
+	return lines
+}
+
+// PageFileID returns the page of pageIDs a file of a document belongs to, e.g.
+// <docId>/<pageId>.rm, <docId>/<pageId>-metadata.json or <docId>.thumbnails/<pageId>.png
+func PageFileID(name string, pageIDs map[string]bool) (string, bool) {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return "", false
+	}
+	base := name[i+1:]
+	id := strings.TrimSuffix(base, "-metadata.json")
+	if j := strings.Index(id, "."); j > 0 && id == base {
+		id = id[:j]
+	}
+	return id, pageIDs[id]
+}
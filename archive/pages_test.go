@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadAndSetPages(t *testing.T) {
+	c := Content{
+		FileType:       "pdf",
+		Pages:          []string{"a", "b", "c"},
+		RedirectionMap: []int{0, -1, 1},
+		PageCount:      3,
+		LastOpenedPage: 2,
+		PageTags:       []PageTag{{Name: "x", PageID: "c"}, {Name: "y", PageID: "a"}},
+	}
+	pages, err := ReadPages(&c, []byte("Blank\nLined\n"))
+	assert.NoError(t, err)
+	assert.Len(t, pages, 3)
+	assert.Equal(t, PageInfo{ID: "b", Source: "b", DocPage: -1, Pagedata: "Lined"}, pages[1])
+	assert.Equal(t, "", pages[2].Pagedata)
+
+	blank := NewBlankPage("")
+	dup := pages[0].Duplicate()
+	assert.Equal(t, "a", dup.Source)
+	assert.NotEqual(t, "a", dup.ID)
+
+	pagedata, err := SetPages(&c, []PageInfo{pages[0], dup, blank})
+	assert.NoError(t, err)
+	assert.Equal(t, "Blank\nBlank\nBlank\n", string(pagedata))
+	assert.Equal(t, []string{"a", dup.ID, blank.ID}, c.Pages)
+	assert.Equal(t, []int{0, 0, -1}, c.RedirectionMap)
+	assert.Equal(t, 3, c.PageCount)
+	// tags of removed pages are dropped
+	assert.Equal(t, []PageTag{{Name: "y", PageID: "a"}}, c.PageTags)
+
+	_, err = SetPages(&c, nil)
+	assert.Error(t, err)
+}
+
+func TestReadLegacyPages(t *testing.T) {
+	c := Content{PageCount: 2}
+	pages, err := ReadPages(&c, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []PageInfo{{ID: "0", Source: "0", DocPage: -1}, {ID: "1", Source: "1", DocPage: -1}}, pages)
+}
+
+func TestPageFileID(t *testing.T) {
+	ids := map[string]bool{"p1": true}
+	for name, expected := range map[string]bool{
+		"doc/p1.rm":              true,
+		"doc//p1-metadata.json":  true,
+		"doc.thumbnails/p1.jpg":  true,
+		"doc.highlights/p1.json": true,
+		"doc/p2.rm":              false,
+		"doc.content":            false,
+	} {
+		_, ok := PageFileID(name, ids)
+		assert.Equal(t, expected, ok, name)
+	}
+
+}
+
+func TestSetCPages(t *testing.T) {
+	data, err := os.ReadFile("testfiles/cpages.content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, inserted, second := "7b3e4f9a-2c1d-4e8b-9f6a-0d5c3b2a1e4f", "2f6c1b0e-9d0a-4a57-8c3b-51f0e8f6a1d2", "c9d8e7f6-5a4b-4c3d-8e2f-1a0b9c8d7e6f"
+
+	var pages []PageInfo
+	readPages := func(data []byte) {
+		content := Content{}
+		assert.NoError(t, json.Unmarshal(data, &content))
+		pages, err = ReadPages(&content, nil)
+		assert.NoError(t, err)
+	}
+	readPages(data)
+	assert.Equal(t, []PageInfo{
+		{ID: first, Source: first, DocPage: 0, Pagedata: "Blank"},
+		{ID: inserted, Source: inserted, DocPage: -1, Pagedata: "P Lines small"},
+		{ID: second, Source: second, DocPage: 1, Pagedata: "Blank"},
+	}, pages)
+
+	// the template changes, the pages keep their index
+	patched, changed, err := PatchContent(data, func(c *Content) error {
+		p := append([]PageInfo{}, pages...)
+		p[2].Pagedata = "Dots S"
+		_, err := SetPages(c, p)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	var raw struct {
+		CPages struct {
+			Pages []map[string]json.RawMessage
+			Uuids json.RawMessage
+		}
+		PageCount int
+	}
+	assert.NoError(t, json.Unmarshal(patched, &raw))
+	assert.JSONEq(t, `{"timestamp":"1:22","value":"Dots S"}`, string(raw.CPages.Pages[2]["template"]))
+	assert.JSONEq(t, `{"timestamp":"1:17","value":"bah"}`, string(raw.CPages.Pages[1]["idx"]))
+	// the fields unknown to CPage and CPages are kept
+	assert.JSONEq(t, `{"timestamp":"1:19","value":0.25}`, string(raw.CPages.Pages[1]["verticalScroll"]))
+	assert.Contains(t, string(raw.CPages.Uuids), "4b1d9a3c-7e2f-4d6a-b8c5-3f0e1a2d9c7b")
+
+	// the second page moves first, the inserted page is removed, the first page is
+	// duplicated and a blank page added
+	readPages(patched)
+	blank := NewBlankPage("Blank")
+	dup := pages[0].Duplicate()
+	patched, _, err = PatchContent(patched, func(c *Content) error {
+		_, err := SetPages(c, []PageInfo{pages[2], pages[0], dup, blank})
+		return err
+	})
+	assert.NoError(t, err)
+	readPages(patched)
+	if assert.Len(t, pages, 4) {
+		assert.Equal(t, []string{second, first, dup.ID, blank.ID}, []string{pages[0].ID, pages[1].ID, pages[2].ID, pages[3].ID})
+		assert.Equal(t, []int{1, 0, 0, -1}, []int{pages[0].DocPage, pages[1].DocPage, pages[2].DocPage, pages[3].DocPage})
+		assert.Equal(t, "Dots S", pages[0].Pagedata)
+	}
+	assert.NoError(t, json.Unmarshal(patched, &raw))
+	assert.Equal(t, 4, raw.PageCount)
+	// the removed page is marked as deleted after the last change
+	for _, p := range raw.CPages.Pages {
+		if string(p["id"]) == `"`+inserted+`"` {
+			assert.JSONEq(t, `{"timestamp":"1:30","value":1}`, string(p["deleted"]))
+			assert.Contains(t, string(p["modifed"]), "1718093437123")
+		}
+	}
+}
@@ -34,7 +34,7 @@ func (z *Zip) Read(r io.ReaderAt, size int64) error {
 	}
 
 	//uploading and then downloading a file results in 0 pages
-	if z.Content.PageCount <= 0 {
+	if len(z.Pages) == 0 {
 		log.Warning.Printf("PageCount is 0")
 		return nil
 	}
@@ -55,6 +55,10 @@ func (z *Zip) Read(r io.ReaderAt, size int64) error {
 		return err
 	}
 
+	if err := z.readHighlights(zr); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -84,6 +88,15 @@ func (z *Zip) readContent(zr *zip.Reader) error {
 	if err = json.Unmarshal(bytes, &z.Content); err != nil {
 		return err
 	}
+	var transform struct {
+		Transform *Transform `json:"transform"`
+	}
+	if err = json.Unmarshal(bytes, &transform); err != nil {
+		return err
+	}
+	if transform.Transform != nil {
+		z.Content.Transform = transform.Transform
+	}
 	p := contentFile.FileInfo().Name()
 	id, _ := util.DocPathToName(p)
 	z.UUID = id
@@ -94,7 +107,7 @@ func (z *Zip) readContent(zr *zip.Reader) error {
 		z.pageMap = make(map[string]int)
 		z.Pages = make([]Page, redirectedCount)
 		for index, docPage := range z.Content.RedirectionMap {
-			if index > pagesCount {
+			if index >= pagesCount {
 				log.Warning.Print("redirection > pages")
 				break
 			}
@@ -110,6 +123,21 @@ func (z *Zip) readContent(zr *zip.Reader) error {
 			z.pageMap[pageUUID] = index
 			z.Pages[index].DocPage = index
 		}
+	} else if z.Content.CPages != nil {
+		// the pages of the newer format
+		pages := z.Content.cPages()
+		z.pageMap = make(map[string]int)
+		z.Pages = make([]Page, len(pages))
+		for index, p := range pages {
+			z.pageMap[p.ID] = index
+			z.Pages[index].DocPage = index
+			if p.Redir != nil {
+				z.Pages[index].DocPage = p.Redir.Value
+			} else if z.Content.FileType == "pdf" || z.Content.FileType == "epub" {
+				// the pages inserted on the device don't show a page of the document
+				z.Pages[index].DocPage = -1
+			}
+		}
 	} else {
 		// instantiate the slice of pages
 		z.Pages = make([]Page, z.Content.PageCount)
@@ -125,6 +153,10 @@ func (z *Zip) readPagedata(zr *zip.Reader) error {
 		return err
 	}
 
+	// the documents of the newer format may have none
+	if len(files) == 0 {
+		return nil
+	}
 	if len(files) != 1 {
 		return errors.New("archive does not contain a unique pagedata file")
 	}
@@ -138,7 +170,7 @@ func (z *Zip) readPagedata(zr *zip.Reader) error {
 	// iterate pagedata file lines
 	sc := bufio.NewScanner(file)
 	var i int = 0
-	for sc.Scan() {
+	for sc.Scan() && i < len(z.Pages) {
 		line := sc.Text()
 		z.Pages[i].Pagedata = line
 		i++
@@ -151,31 +183,41 @@ func (z *Zip) readPagedata(zr *zip.Reader) error {
 	return nil
 }
 
-// readPayload tries to extract the payload from an archive if it exists.
+// readPayload tries to extract the payload from an archive if it exists, and the
+// rendition of an EPUB.
 func (z *Zip) readPayload(zr *zip.Reader) error {
-	ext := z.Content.FileType
-	files, err := zipExtFinder(zr, "."+ext)
-	if err != nil {
+	var err error
+	if z.Payload, err = readSingle(zr, "."+z.Content.FileType); err != nil {
 		return err
 	}
+	if z.Content.FileType == "epub" {
+		if z.Rendition, err = readSingle(zr, ".pdf"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSingle returns the content of the file with the extension, nil if there
+// isn't exactly one.
+func readSingle(zr *zip.Reader, ext string) ([]byte, error) {
+	files, err := zipExtFinder(zr, ext)
+	if err != nil {
+		return nil, err
+	}
 
 	// return if not found
 	if len(files) != 1 {
-		return nil
+		return nil, nil
 	}
 
 	file, err := files[0].Open()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
-	z.Payload, err = io.ReadAll(file)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return io.ReadAll(file)
 }
 
 // readData extracts existing .rm files from an archive.
@@ -319,7 +361,7 @@ func splitExt(name string) (string, string) {
 }
 
 // zipExtFinder searches for a file matching the substr pattern
-// in a zip file.
+// in a zip file. The highlights are skipped, see readHighlights.
 func zipExtFinder(zr *zip.Reader, ext string) ([]*zip.File, error) {
 	var files []*zip.File
 
@@ -1,8 +1,13 @@
 package archive
 
 import (
+	"archive/zip"
+	"bytes"
 	"os"
 	"testing"
+
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRead(t *testing.T) {
@@ -26,3 +31,43 @@ func TestRead(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestReadCPages(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name, data string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	id := "0c9f3c4e-3b5e-4a6e-9d5b-6a1f2e3d4c5b"
+	page1, page2, inserted := "5a3e6c0d-8f1b-4f43-9c4e-2b7d9f0e1a2c", "e4b1d2c3-7a6f-4e5d-8c9b-0a1b2c3d4e5f", "9c1d4e6f-0a2b-4c3d-8e5f-6a7b8c9d0e1f"
+	add(id+".content", `{"fileType":"pdf","cPages":{"pages":[`+
+		`{"id":"`+page2+`","idx":{"value":"bb"},"redir":{"value":3}},`+
+		`{"id":"`+inserted+`","idx":{"value":"bc"}},`+
+		`{"id":"`+page1+`","idx":{"value":"ba"},"redir":{"value":0}}]},`+
+		`"zoomMode":"customFit","customZoomPageWidth":1872,"customZoomPageHeight":2646,`+
+		`"transform":{"m11":2,"m12":0,"m13":0,"m21":0,"m22":2,"m23":0,"m31":-100,"m32":-50,"m33":1}}`)
+	add(id+"/"+page2+".rm", rm.HeaderV6)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z := NewZip()
+	assert.NoError(t, z.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	if assert.Len(t, z.Pages, 3) {
+		assert.Equal(t, 0, z.Pages[0].DocPage)
+		assert.Nil(t, z.Pages[0].Data)
+		assert.Equal(t, 3, z.Pages[1].DocPage)
+		if assert.NotNil(t, z.Pages[1].Data) {
+			assert.Equal(t, rm.V6, z.Pages[1].Data.Version)
+		}
+		// the page inserted on the device
+		assert.Equal(t, -1, z.Pages[2].DocPage)
+	}
+	assert.Equal(t, "customFit", z.Content.ZoomMode)
+	assert.Equal(t, 1872.0, z.Content.CustomZoomPageWidth)
+	assert.Equal(t, &Transform{M11: 2, M22: 2, M31: -100, M32: -50, M33: 1}, z.Content.Transform)
+}
@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/juruen/rmapi/model"
+)
+
+// TemplateExt is the extension of the description of a custom template
+const TemplateExt RmExt = "template"
+
+// Template describes a custom template, the fields are the ones of the entries
+// of templates.json on the tablet
+type Template struct {
+	// Name is the name shown on the tablet
+	Name string `json:"name"`
+	// Filename is the name of the image without extension, the .pagedata of documents refer to it
+	Filename   string   `json:"filename"`
+	IconCode   string   `json:"iconCode,omitempty"`
+	Categories []string `json:"categories"`
+	Landscape  bool     `json:"landscape,omitempty"`
+}
+
+// PrepareTemplate prepares a template document with its description and its image of type ext,
+// svg or png.
+//
+// The layout is assumed from the templates.json of the tablet, it hasn't been checked against
+// a capture of a tablet sync:
+//   - <id>.template holds the Template as JSON, the same keys as an entry of templates.json
+//   - <id>.svg or <id>.png holds the image, the tablet refers to it by Filename
+//   - <id>.metadata has the type TemplateType and no parent, templates live outside the folders
+//
+// No .content is written as templates aren't opened like documents.
+func PrepareTemplate(template *Template, ext string, image []byte) (files []NameContent, id string, err error) {
+	if ext != "svg" && ext != "png" {
+		return nil, "", errors.New("templates must be svg or png images")
+	}
+	if template.Name == "" {
+		return nil, "", errors.New("template name is invalid")
+	}
+	t := *template
+	if t.Filename == "" {
+		t.Filename = t.Name
+	}
+	if len(t.Categories) == 0 {
+		t.Categories = []string{"Custom"}
+	}
+
+	id = uuid.New().String()
+	description, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	files = append(files, NameContent{id + "." + string(TemplateExt), description, TemplateExt})
+	files = append(files, NameContent{id + "." + ext, image, RmExt(ext)})
+
+	metadata, err := metadataBytes(t.Name, "", model.TemplateType)
+	if err != nil {
+		return
+	}
+	files = append(files, NameContent{id + "." + string(MetadataExt), metadata, MetadataExt})
+	return files, id, nil
+}
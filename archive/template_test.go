@@ -0,0 +1,28 @@
+package archive
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/juruen/rmapi/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareTemplate(t *testing.T) {
+	files, id, err := PrepareTemplate(&Template{Name: "Meeting"}, "svg", []byte("<svg/>"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	assert.Equal(t, id+".template", files[0].Name)
+	template := Template{}
+	assert.NoError(t, json.Unmarshal(files[0].Content, &template))
+	assert.Equal(t, Template{Name: "Meeting", Filename: "Meeting", Categories: []string{"Custom"}}, template)
+
+	assert.Equal(t, id+".svg", files[1].Name)
+	metadata := MetadataFile{}
+	assert.NoError(t, json.Unmarshal(files[2].Content, &metadata))
+	assert.Equal(t, model.TemplateType, metadata.CollectionType)
+
+	_, _, err = PrepareTemplate(&Template{Name: "Meeting"}, "pdf", nil)
+	assert.Error(t, err)
+}
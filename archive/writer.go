@@ -73,21 +73,32 @@ func (z *Zip) writeContent(zw *zip.Writer) error {
 	return nil
 }
 
-// writePdf writes a pdf file to the archive if existing in the struct.
+// writePayload writes the pdf or epub file to the archive if existing in the struct,
+// and the rendition of an epub.
 func (z *Zip) writePayload(zw *zip.Writer) error {
-	// skip if no pdf
-	if z.Payload == nil {
+	if err := z.writeFile(zw, z.Content.FileType, z.Payload); err != nil {
+		return err
+	}
+	if z.Content.FileType == "epub" {
+		return z.writeFile(zw, "pdf", z.Rendition)
+	}
+	return nil
+}
+
+// writeFile writes the file of the document with the extension, skipped if nil
+func (z *Zip) writeFile(zw *zip.Writer, ext string, data []byte) error {
+	if data == nil {
 		return nil
 	}
 
-	name := fmt.Sprintf("%s.%s", z.UUID, z.Content.FileType)
+	name := fmt.Sprintf("%s.%s", z.UUID, ext)
 
 	w, err := addToZip(zw, name)
 	if err != nil {
 		return err
 	}
 
-	if _, err := w.Write(z.Payload); err != nil {
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
 
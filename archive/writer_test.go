@@ -1,8 +1,11 @@
 package archive
 
 import (
+	"bytes"
 	"os"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestWrite(t *testing.T) {
@@ -25,3 +28,18 @@ func TestWrite(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestWriteEpubRendition(t *testing.T) {
+	z := NewZip()
+	z.Content.FileType = "epub"
+	z.Payload = []byte("epub")
+	z.Rendition = []byte("pdf")
+
+	var buf bytes.Buffer
+	assert.NoError(t, z.Write(&buf))
+
+	read := NewZip()
+	assert.NoError(t, read.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	assert.Equal(t, []byte("epub"), read.Payload)
+	assert.Equal(t, []byte("pdf"), read.Rendition)
+}
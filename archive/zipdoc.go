@@ -179,7 +179,8 @@ func CreateZipDirectory(id string) (string, error) {
 	return tmp.Name(), nil
 }
 
-func createZipContent(ext string, pageIDs []string, coverpage *int) (string, error) {
+// createZipContent returns the .content of a new document with the settings of layout, which can be nil
+func createZipContent(ext string, pageIDs []string, layout *Layout) (string, error) {
 	c := Content{
 		DummyDocument: false,
 		ExtraMetadata: ExtraMetadata{
@@ -204,8 +205,10 @@ func createZipContent(ext string, pageIDs []string, coverpage *int) (string, err
 			M32: 0,
 			M33: 1,
 		},
-		Pages:           pageIDs,
-		CoverPageNumber: coverpage,
+		Pages: pageIDs,
+	}
+	if layout != nil {
+		layout.Apply(&c)
 	}
 
 	cstring, err := json.Marshal(c)
@@ -218,22 +221,29 @@ func createZipContent(ext string, pageIDs []string, coverpage *int) (string, err
 	return string(cstring), nil
 }
 
-func CreateContent(id, ext, fpath string, pageIds []string, coverpage *int) (fileName, filePath string, err error) {
+func CreateContent(id, ext, fpath string, pageIds []string, layout *Layout) (fileName, filePath string, err error) {
 	fileName = id + "." + string(ContentExt)
 	filePath = path.Join(fpath, fileName)
-	content := "{}"
 
-	if ext != "" {
-		content, err = createZipContent(ext, pageIds, coverpage)
-		if err != nil {
-			return
-		}
+	content, err := contentBytes(ext, pageIds, layout)
+	if err != nil {
+		return
 	}
 
-	err = os.WriteFile(filePath, []byte(content), 0600)
+	err = os.WriteFile(filePath, content, 0600)
 	return
 }
 
+// contentBytes returns the .content of a new document, an empty ext is used for directories
+func contentBytes(ext string, pageIds []string, layout *Layout) ([]byte, error) {
+	if ext == "" {
+		return []byte("{}"), nil
+	}
+
+	content, err := createZipContent(ext, pageIds, layout)
+	return []byte(content), err
+}
+
 func UnixTimestamp() string {
 	t := time.Now().UnixNano() / 1000000
 	tf := strconv.FormatInt(t, 10)
@@ -243,6 +253,18 @@ func UnixTimestamp() string {
 func CreateMetadata(id, name, parent, colType, fpath string) (fileName string, filePath string, err error) {
 	fileName = id + "." + string(MetadataExt)
 	filePath = path.Join(fpath, fileName)
+
+	c, err := metadataBytes(name, parent, colType)
+	if err != nil {
+		return
+	}
+
+	err = os.WriteFile(filePath, c, 0600)
+	return
+}
+
+// metadataBytes returns the .metadata of a new document
+func metadataBytes(name, parent, colType string) ([]byte, error) {
 	meta := MetadataFile{
 		DocName:        name,
 		Version:        0,
@@ -252,11 +274,5 @@ func CreateMetadata(id, name, parent, colType, fpath string) (fileName string, f
 		LastModified:   UnixTimestamp(),
 	}
 
-	c, err := json.Marshal(meta)
-	if err != nil {
-		return
-	}
-
-	err = os.WriteFile(filePath, c, 0600)
-	return
+	return json.Marshal(meta)
 }
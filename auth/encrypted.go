@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	encryptedHeader = "rmapi-encrypted-v1\n"
+	saltSize        = 16
+	keySize         = 32
+	kdfIterations   = 200000
+)
+
+// ErrWrongPassphrase is returned when the tokens can't be decrypted.
+var ErrWrongPassphrase = errors.New("auth: wrong passphrase or corrupted token file")
+
+// EncryptedFileTokenStore implements TokenStore by saving the tokens
+// into a file encrypted with a key derived from a passphrase
+// (PBKDF2-SHA256 and AES-GCM).
+//
+// A plain yaml file found at Path is loaded as is and gets
+// encrypted the next time the tokens are saved.
+type EncryptedFileTokenStore struct {
+	Path       string
+	Passphrase []byte
+}
+
+// Save will encrypt a TokenSet and persist it.
+func (es *EncryptedFileTokenStore) Save(t TokenSet) error {
+	content, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(es.Passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	sealed := append(salt, nonce...)
+	sealed = gcm.Seal(sealed, nonce, content, nil)
+
+	var buf bytes.Buffer
+	buf.WriteString(encryptedHeader)
+	buf.WriteString(base64.StdEncoding.EncodeToString(sealed))
+	buf.WriteString("\n")
+
+	return os.WriteFile(es.Path, buf.Bytes(), 0600)
+}
+
+// Load will decrypt the token file and return its TokenSet.
+func (es *EncryptedFileTokenStore) Load() (TokenSet, error) {
+	content, err := os.ReadFile(es.Path)
+	if os.IsNotExist(err) {
+		return TokenSet{}, nil
+	}
+	if err != nil {
+		return TokenSet{}, err
+	}
+
+	var tks TokenSet
+	if !bytes.HasPrefix(content, []byte(encryptedHeader)) {
+		// not encrypted yet
+		err = yaml.Unmarshal(content, &tks)
+		return tks, err
+	}
+
+	encoded := bytes.TrimSpace(content[len(encryptedHeader):])
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return TokenSet{}, ErrWrongPassphrase
+	}
+
+	if len(sealed) < saltSize {
+		return TokenSet{}, ErrWrongPassphrase
+	}
+
+	gcm, err := newGCM(es.Passphrase, sealed[:saltSize])
+	if err != nil {
+		return TokenSet{}, err
+	}
+
+	sealed = sealed[saltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return TokenSet{}, ErrWrongPassphrase
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return TokenSet{}, ErrWrongPassphrase
+	}
+
+	err = yaml.Unmarshal(plain, &tks)
+	return tks, err
+}
+
+func newGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("auth: empty passphrase")
+	}
+
+	block, err := aes.NewCipher(deriveKey(passphrase, salt, kdfIterations))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the key of the cipher from the passphrase with PBKDF2-SHA256
+func deriveKey(passphrase, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(passphrase, salt, iterations, keySize, sha256.New)
+}
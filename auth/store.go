@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -86,3 +87,76 @@ func (ft *FileTokenStore) Load() (TokenSet, error) {
 
 	return tks, nil
 }
+
+// MemoryTokenStore implements TokenStore by keeping the tokens in memory.
+// It is mostly useful for embedding applications managing the tokens themselves.
+type MemoryTokenStore struct {
+	Tokens TokenSet
+}
+
+// Save keeps the TokenSet in memory.
+func (ms *MemoryTokenStore) Save(t TokenSet) error {
+	ms.Tokens = t
+	return nil
+}
+
+// Load returns the TokenSet kept in memory.
+func (ms *MemoryTokenStore) Load() (TokenSet, error) {
+	return ms.Tokens, nil
+}
+
+const (
+	// DeviceTokenEnvVar holds the device token.
+	DeviceTokenEnvVar = "RMAPI_DEVICE_TOKEN"
+	// DeviceTokenFileEnvVar holds the path to a file containing the device token,
+	// e.g. a mounted secret.
+	DeviceTokenFileEnvVar = "RMAPI_DEVICE_TOKEN_FILE"
+	// UserTokenEnvVar optionally holds a user token.
+	UserTokenEnvVar = "RMAPI_USER_TOKEN"
+)
+
+// EnvTokenStore implements TokenStore by reading tokens from
+// environment variables or from a secrets file referenced by one.
+//
+// The environment can't be written, tokens passed to Save (e.g. a renewed
+// UserToken) are kept in memory and take precedence over the environment.
+type EnvTokenStore struct {
+	saved *TokenSet
+}
+
+// HasEnvTokens reports whether a device token is provided by the environment.
+func HasEnvTokens() bool {
+	return os.Getenv(DeviceTokenEnvVar) != "" || os.Getenv(DeviceTokenFileEnvVar) != ""
+}
+
+// Save keeps the TokenSet in memory for the lifetime of the store.
+func (es *EnvTokenStore) Save(t TokenSet) error {
+	es.saved = &t
+	return nil
+}
+
+// Load will return a TokenSet populated from the environment.
+func (es *EnvTokenStore) Load() (TokenSet, error) {
+	if es.saved != nil {
+		return *es.saved, nil
+	}
+
+	tks := TokenSet{
+		DeviceToken: os.Getenv(DeviceTokenEnvVar),
+		UserToken:   os.Getenv(UserTokenEnvVar),
+	}
+
+	if tks.DeviceToken != "" {
+		return tks, nil
+	}
+
+	if path := os.Getenv(DeviceTokenFileEnvVar); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return TokenSet{}, err
+		}
+		tks.DeviceToken = strings.TrimSpace(string(content))
+	}
+
+	return tks, nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 7914 section 11 test vectors, the keys are the first 32 bytes
+	key := deriveKey([]byte("passwd"), []byte("salt"), 1)
+	assert.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc", hex.EncodeToString(key))
+	key = deriveKey([]byte("Password"), []byte("NaCl"), 80000)
+	assert.Equal(t, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56", hex.EncodeToString(key))
+}
+
+func TestEncryptedFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	tokens := TokenSet{DeviceToken: "foo", UserToken: "bar"}
+
+	plain := FileTokenStore{Path: path}
+	assert.NoError(t, plain.Save(tokens))
+
+	store := EncryptedFileTokenStore{Path: path, Passphrase: []byte("secret")}
+
+	// plain files are still readable
+	loaded, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, tokens, loaded)
+
+	assert.NoError(t, store.Save(tokens))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "foo")
+
+	loaded, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, tokens, loaded)
+
+	wrong := EncryptedFileTokenStore{Path: path, Passphrase: []byte("wrong")}
+	_, err = wrong.Load()
+	assert.Equal(t, ErrWrongPassphrase, err)
+}
+
+func TestEnvTokenStore(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "device_token")
+	assert.NoError(t, os.WriteFile(secret, []byte("device\n"), 0600))
+
+	t.Setenv(DeviceTokenEnvVar, "")
+	t.Setenv(DeviceTokenFileEnvVar, secret)
+	assert.True(t, HasEnvTokens())
+
+	store := EnvTokenStore{}
+	tks, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "device", tks.DeviceToken)
+
+	t.Setenv(DeviceTokenEnvVar, "env")
+	tks, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "env", tks.DeviceToken)
+
+	tks.UserToken = "user"
+	assert.NoError(t, store.Save(tks))
+	tks, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "user", tks.UserToken)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/juruen/rmapi/api"
+	"github.com/juruen/rmapi/auth"
+)
+
+const authUsage = `Usage: rmapi auth <command>
+
+Commands:
+  register --code <code>	register this device with a one-time code
+				(https://my.remarkable.com/device/browser/connect)
+  status			show the token status (user, scopes, expiry)
+  refresh			request a new user token
+  logout			remove the stored tokens`
+
+// runAuthCommand handles the auth command group, which works without a valid user token
+func runAuthCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(authUsage)
+		return nil
+	}
+
+	store, err := api.DefaultTokenStore()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "register":
+		flagSet := flag.NewFlagSet("register", flag.ContinueOnError)
+		code := flagSet.String("code", "", "one-time code")
+		if err := flagSet.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *code == "" {
+			*code = os.Getenv("RMAPI_CODE")
+		}
+		if *code == "" {
+			return errors.New("missing --code")
+		}
+		if err := api.RegisterDevice(store, *code); err != nil {
+			return fmt.Errorf("failed to register device, %w", err)
+		}
+		if _, err := api.RefreshUserToken(store); err != nil {
+			return fmt.Errorf("device registered, but failed to get a user token, %w", err)
+		}
+		fmt.Println("device registered")
+		if _, ok := store.(*auth.EnvTokenStore); ok {
+			fmt.Println("tokens come from the environment and were not persisted")
+		}
+		return nil
+	case "status":
+		return printAuthStatus(store)
+	case "refresh":
+		if _, err := api.RefreshUserToken(store); err != nil {
+			return fmt.Errorf("failed to refresh the user token, %w", err)
+		}
+		return printAuthStatus(store)
+	case "logout":
+		if err := api.Logout(store); err != nil {
+			return err
+		}
+		fmt.Println("tokens removed")
+		return nil
+	default:
+		fmt.Println(authUsage)
+		return fmt.Errorf("unknown auth command: %s", args[0])
+	}
+}
+
+func printAuthStatus(store auth.TokenStore) error {
+	tokens, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	if tokens.DeviceToken == "" {
+		fmt.Println("device: not registered")
+		return nil
+	}
+	fmt.Println("device: registered")
+
+	if tokens.UserToken == "" {
+		fmt.Println("user token: missing")
+		return nil
+	}
+
+	status, err := api.ParseTokenStatus(tokens.UserToken)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("user:", status.User)
+	fmt.Println("sync version:", status.SyncVersion)
+	fmt.Println("scopes:", strings.Join(status.Scopes, " "))
+	if !status.IssuedAt.IsZero() {
+		fmt.Println("issued:", status.IssuedAt.Local().Format(time.RFC3339))
+	}
+	if !status.ExpiresAt.IsZero() {
+		state := "valid"
+		if status.Expired {
+			state = "expired"
+		}
+		fmt.Printf("expires: %s (%s)\n", status.ExpiresAt.Local().Format(time.RFC3339), state)
+	}
+	return nil
+}
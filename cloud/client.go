@@ -1,38 +1,47 @@
 package cloud
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
 	"net/http"
 	"net/url"
+	"sync"
 
+	"github.com/juruen/rmapi/api/sync15"
+	"github.com/juruen/rmapi/config"
+	"github.com/juruen/rmapi/model"
+	"github.com/juruen/rmapi/transport"
 	"github.com/pkg/errors"
 )
 
 const defaultUserAgent = "rmapi"
-const defaultBaseURL = "https://document-storage-production-dot-remarkable-production.appspot.com"
 
 // A Client manages communication with the Remarkable Cloud API.
 type Client struct {
 	// By making the base URL configurable we can make it
 	// testable by passing the URL of a httptest.Server.
 	// That also means that the Client is acting upon a same base URL for all requests.
+	// It has to be set before the first request.
 	BaseURL *url.URL
 
 	UserAgent string
 
+	// CachePath is the file where the document tree is cached between runs.
+	// If empty, the tree is kept in memory and fetched again by every new Client.
+	CachePath string
+
 	// The cloud package does not directly handle authentication.
 	// Instead, when creating a new client, pass an http.Client that
 	// can handle authentication for you.
 	// The easiest and recommended way to do this is using the auth package.
 	httpClient *http.Client
+
+	mu  sync.Mutex
+	ctx *sync15.ApiCtx
 }
 
 // NewClient instanciates and configures the default URL and
 // user agent for the http client.
 func NewClient(httpClient *http.Client) *Client {
-	url, _ := url.Parse(defaultBaseURL)
+	url, _ := url.Parse(config.SyncHost)
 
 	return &Client{
 		httpClient: httpClient,
@@ -41,49 +50,30 @@ func NewClient(httpClient *http.Client) *Client {
 	}
 }
 
-// newRequest creates an http.Request with a method, a relative url path
-// and a payload. Query string parameters are not handled.
-func (c *Client) newRequest(method, path string, body interface{}) (*http.Request, error) {
-	rel := &url.URL{Path: path}
-	url := c.BaseURL.ResolveReference(rel)
-
-	var buf io.ReadWriter
-	if body != nil {
-		buf = new(bytes.Buffer)
-		err := json.NewEncoder(buf).Encode(body)
-		if err != nil {
-			return nil, errors.Wrap(err, "can't encode payload")
+// apiCtx returns the sync context, the remote tree is mirrored on the first call.
+// With refresh, the tree is updated if the remote one has changed.
+func (c *Client) apiCtx(refresh bool) (*sync15.ApiCtx, error) {
+	if c.ctx != nil {
+		if refresh {
+			if _, _, err := c.ctx.Refresh(); err != nil {
+				return nil, errors.Wrap(err, "can't refresh the document tree")
+			}
 		}
+		return c.ctx, nil
 	}
 
-	req, err := http.NewRequest(method, url.String(), buf)
-	if err != nil {
-		return nil, errors.Wrapf(err, "can't create request: %s", url.String())
-	}
-
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+	httpCtx := &transport.HttpClientCtx{
+		Client:    c.httpClient,
+		Tokens:    model.AuthTokens{},
+		UserAgent: c.UserAgent,
 	}
 
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", c.UserAgent)
-
-	return req, nil
-}
-
-// do proceeds to the execution of the request and fills v with the answer.
-func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.httpClient.Do(req)
+	storage := sync15.NewBlobStorageForHost(httpCtx, c.BaseURL.String())
+	ctx, err := sync15.NewCtx(httpCtx, storage, c.CachePath)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't execute request")
-	}
-	defer resp.Body.Close()
-
-	if v != nil {
-		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-			return nil, errors.Wrap(err, "can't decode response content")
-		}
+		return nil, errors.Wrap(err, "can't fetch the document tree")
 	}
 
-	return resp, nil
+	c.ctx = ctx
+	return ctx, nil
 }
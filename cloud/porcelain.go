@@ -4,24 +4,24 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"net/http"
 	"sort"
-	"time"
 
-	"github.com/google/uuid"
+	"github.com/juruen/rmapi/api/sync15"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
+	"github.com/juruen/rmapi/util"
 	"github.com/pkg/errors"
 )
 
 const (
 	// DirectoryType is used as directory type.
-	DirectoryType = "CollectionType"
+	DirectoryType = model.DirectoryType
 	// DocumentType is used a regular document type.
-	DocumentType = "DocumentType"
+	DocumentType = model.DocumentType
 )
 
 // Document represents a human readable format of a Remarkable document.
-// It is transformed into a more technical object and used as a
-// payload or response during http calls with the Remarkable API.
 type Document struct {
 	ID          string
 	Version     int
@@ -44,45 +44,73 @@ func (d Document) String() string {
 	return buffer.String()
 }
 
-// toRawDocument returns a technical rawDocument created from a public Document
-func (d Document) toRawDocument() rawDocument {
-	return rawDocument{
-		ID:           d.ID,
-		Version:      d.Version,
-		Type:         d.Type,
-		VissibleName: d.Name,
-		CurrentPage:  d.CurrentPage,
-		Bookmarked:   d.Bookmarked,
-		Parent:       d.Parent,
+// toDocument transforms a model.Document to a public Document
+func toDocument(d *model.Document) Document {
+	return Document{
+		ID:          d.ID,
+		Version:     d.Version,
+		Type:        d.Type,
+		Name:        d.Name,
+		CurrentPage: d.CurrentPage,
+		Bookmarked:  d.Starred,
+		Parent:      d.Parent,
 	}
 }
 
+// node returns the tree node of the document uuid
+func node(ctx *sync15.ApiCtx, uuid string) (*model.Node, error) {
+	if uuid == "" || uuid == filetree.TrashID {
+		return nil, errors.New("undefined document id")
+	}
+
+	n := ctx.Filetree().NodeById(uuid)
+	if n == nil {
+		return nil, errors.Errorf("document %s not found", uuid)
+	}
+	return n, nil
+}
+
 // Get is a first class method used to fetch information of a document.
 //
 // It takes a uuid as parameter and a Document is returned to the end user.
 func (c *Client) Get(uuid string) (Document, error) {
-	rdoc, err := c.getDoc(uuid)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, err := c.apiCtx(true)
+	if err != nil {
+		return Document{}, err
+	}
+
+	n, err := node(ctx, uuid)
 	if err != nil {
 		return Document{}, errors.Wrap(err, "can't get document")
 	}
 
-	return rdoc.toDocument(), nil
+	return toDocument(n.Document), nil
 }
 
 // List is a first class method used to fetch the list of all documents on a device.
 //
 // It returns a list of Documents.
 func (c *Client) List() ([]Document, error) {
-	// use empty uuid to have them all
-	rdocs, err := c.getDocs("")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, err := c.apiCtx(true)
 	if err != nil {
 		return nil, errors.Wrap(err, "can't get documents")
 	}
 
 	var docs []Document
-	for _, rdoc := range rdocs {
-		docs = append(docs, rdoc.toDocument())
-	}
+	filetree.WalkTree(ctx.Filetree().Root(), filetree.FileTreeVistor{
+		Visit: func(n *model.Node, path []string) bool {
+			if !n.IsRoot() && n.Id() != filetree.TrashID {
+				docs = append(docs, toDocument(n.Document))
+			}
+			return filetree.ContinueVisiting
+		},
+	})
 
 	// sort by name
 	sort.Slice(docs, func(i, j int) bool {
@@ -101,72 +129,86 @@ func (c *Client) List() ([]Document, error) {
 // The content received will be a zip file containing all the document files.
 // To make use of it, you can have a look to the archive package.
 func (c *Client) Download(uuid string, w io.Writer) error {
-	rdoc, err := c.getDoc(uuid)
-	if err != nil {
-		return errors.Wrap(err, "can't get document")
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// direct call to the http.Client.Get because different domain
-	resp, err := c.httpClient.Get(rdoc.BlobURLGet)
+	ctx, err := c.apiCtx(true)
 	if err != nil {
-		return errors.Wrap(err, "download failed")
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("wrong http return code: %d", resp.StatusCode)
+	if _, err := node(ctx, uuid); err != nil {
+		return errors.Wrap(err, "can't get document")
 	}
 
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "can't write file")
+	if err := ctx.FetchDocumentTo(uuid, w); err != nil {
+		return errors.Wrap(err, "download failed")
 	}
 
 	return nil
 }
 
-// Upload is a first class method used to upload content to a document.
+// UploadDocument is a first class method used to upload content to a document.
 //
 // The Document given as parameter will identify which real document to
-// target. If the Document uuid already exists, the content will be
-// updated. If not, a new document will be created.
+// target. If the Document uuid already exists, the document is
+// replaced. If not, a new document will be created.
 // For creating a new document however, you may want to use the Upload method instead.
 //
 // An io.Reader is expected as parameter to provide the content of the
 // document. This way, we can upload a content not only from a file
 // but as well from other sources.
 //
-// The content should be shaped as a zip file as expected by the Remarkable.
+// The content should be shaped as a zip file as expected by the Remarkable
+// and its files have to be named after the Document uuid.
 // You can have a look to the archive package to help easily creating
 // a correctly formatted file.
 func (c *Client) UploadDocument(doc Document, r io.Reader) error {
 	if doc.ID == "" {
 		return errors.New("undefined document id")
 	}
+	if doc.Name == "" {
+		return errors.Errorf("invalid document name '%s'", doc.Name)
+	}
 
-	url, err := c.uploadRequest(doc.toRawDocument())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, err := c.apiCtx(true)
 	if err != nil {
-		return errors.Wrap(err, "can't create upload request")
+		return err
 	}
 
-	// direct upload to url as endpoint is different
-	req, err := http.NewRequest("PUT", url, r)
+	content, err := io.ReadAll(r)
 	if err != nil {
-		return errors.Wrap(err, "can't initiate upload")
+		return errors.Wrap(err, "can't read content")
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "can't upload document")
+	var zipDoc archive.Zip
+	if err := zipDoc.Read(bytes.NewReader(content), int64(len(content))); err != nil {
+		return errors.Wrap(err, "invalid archive")
+	}
+	if zipDoc.UUID != doc.ID {
+		return errors.Errorf("archive contains document %s instead of %s", zipDoc.UUID, doc.ID)
+	}
+
+	if existing := ctx.Filetree().NodeById(doc.ID); existing != nil && existing.IsDirectory() {
+		return errors.New("can't replace a folder")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("wrong http return code: %d", resp.StatusCode)
+	var update func(metadata *archive.MetadataFile) error
+	if doc.Bookmarked || doc.CurrentPage != 0 {
+		update = func(metadata *archive.MetadataFile) error {
+			metadata.Pinned = doc.Bookmarked
+			metadata.LastOpenedPage = doc.CurrentPage
+			return nil
+		}
 	}
 
-	if err := c.Metadata(doc); err != nil {
-		return errors.Wrap(err, "can't update metadata")
+	// the existing document is replaced in the same commit
+	_, err = ctx.ReplaceDocumentFrom(doc.Parent, doc.Name, util.ZIP, bytes.NewReader(content), update, true)
+	if err != nil {
+		return errors.Wrap(err, "can't upload document")
 	}
 
 	return nil
@@ -174,7 +216,7 @@ func (c *Client) UploadDocument(doc Document, r io.Reader) error {
 
 // Upload is a first class method used to upload a new document.
 //
-// A newly generated uuid should be provided and a name should be given.
+// The uuid of the document contained in the archive should be provided and a name should be given.
 //
 // An io.Reader is expected as parameter to provide the content of the
 // document. This way, we can upload a content not only from a file
@@ -202,20 +244,20 @@ func (c *Client) Upload(uuid string, name string, r io.Reader) error {
 //
 // The UUID of the created folder is returned.
 func (c *Client) CreateFolder(name string, parent string) (string, error) {
-	id := uuid.New().String()
-	doc := Document{
-		ID:      id,
-		Parent:  parent,
-		Type:    DirectoryType,
-		Version: 1,
-		Name:    name,
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, err := c.apiCtx(false)
+	if err != nil {
+		return "", err
 	}
 
-	if err := c.Metadata(doc); err != nil {
+	doc, err := ctx.CreateDir(parent, name, true)
+	if err != nil {
 		return "", errors.Wrap(err, "can't create folder")
 	}
 
-	return id, nil
+	return doc.ID, nil
 }
 
 // Metadata is a first class method used to update metadata of
@@ -224,85 +266,62 @@ func (c *Client) CreateFolder(name string, parent string) (string, error) {
 // It takes a Document as input containing the metadata changes
 // that will be sent to the API.
 //
-// This method can be used to make a document visible, to move,
-// rename, bookmark a document.
+// This method can be used to move, rename, bookmark a document
+// or to set its current page. An empty Name keeps the current one.
 //
-// Calling this method will reset the ModifiedClient time to now.
-// If the document Version is not explicitly defined, it will be
-// defined by fetching the current document version and adding one.
+// Calling this method will reset the modification time to now
+// and increase the document Version.
 func (c *Client) Metadata(doc Document) error {
-	rdoc := doc.toRawDocument()
-
-	if rdoc.ID == "" {
+	if doc.ID == "" {
 		return errors.New("undefined document id")
 	}
 
-	// set modified to now
-	rdoc.ModifiedClient = time.Now().UTC().Format(time.RFC3339Nano)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// set Version to current version +1 if not defined
-	if rdoc.Version == 0 {
-		cur, err := c.getCurrentVersion(doc.ID)
-		if err != nil {
-			return errors.Wrap(err, "can't get current version of document")
-		}
-		rdoc.Version = cur + 1
-	}
-
-	payload := []rawDocument{rdoc}
-
-	req, err := c.newRequest("PUT", "document-storage/json/2/upload/update-status", payload)
+	ctx, err := c.apiCtx(false)
 	if err != nil {
 		return err
 	}
 
-	var rdocs []rawDocument
-	resp, err := c.do(req, &rdocs)
+	return c.updateMetadata(ctx, doc)
+}
+
+func (c *Client) updateMetadata(ctx *sync15.ApiCtx, doc Document) error {
+	_, err := ctx.UpdateMetadata(doc.ID, func(metadata *archive.MetadataFile) error {
+		if doc.Name != "" {
+			metadata.DocName = doc.Name
+		}
+		metadata.Parent = doc.Parent
+		metadata.Pinned = doc.Bookmarked
+		metadata.LastOpenedPage = doc.CurrentPage
+		metadata.LastModified = archive.UnixTimestamp()
+		return nil
+	})
 	if err != nil {
 		return errors.Wrap(err, "request failed")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("wrong http return code: %d", resp.StatusCode)
-	}
-
-	if len(rdocs) == 0 {
-		return errors.Wrap(err, "empty document list received")
-	}
-
-	if !rdocs[0].Success {
-		return errors.Errorf("success false received: %s", rdocs[0].Message)
-	}
-
 	return nil
 }
 
-// Delete is a first class method used to delete a document or folder.
+// Delete is a first class method used to delete a document or an empty folder.
 func (c *Client) Delete(uuid string) error {
-	cur, err := c.getCurrentVersion(uuid)
-	if err != nil {
-		return errors.Wrap(err, "can't get current version of document")
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	doc := Document{
-		ID:      uuid,
-		Version: cur,
-	}
-
-	payload := []rawDocument{doc.toRawDocument()}
-
-	req, err := c.newRequest("PUT", "/document-storage/json/2/delete", payload)
+	ctx, err := c.apiCtx(true)
 	if err != nil {
 		return err
 	}
 
-	resp, err := c.do(req, nil)
+	n, err := node(ctx, uuid)
 	if err != nil {
-		return errors.Wrap(err, "request failed")
+		return errors.Wrap(err, "can't get document")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("wrong http return code: %d", resp.StatusCode)
+	if err := ctx.DeleteEntry(n, false, true); err != nil {
+		return errors.Wrap(err, "request failed")
 	}
 
 	return nil
@@ -0,0 +1,130 @@
+package cloud
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) *Client {
+	srv := httptest.NewServer(apitest.NewServer())
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.Client())
+	c.BaseURL, _ = url.Parse(srv.URL)
+	return c
+}
+
+// id of the document in test.zip
+const testZipUUID = "883ba04f-606c-41b7-8903-8d113356850f"
+
+func TestClient(t *testing.T) {
+	c := newTestClient(t)
+
+	docs, err := c.List()
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+
+	folder, err := c.CreateFolder("folder", "")
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile("test.zip")
+	assert.NoError(t, err)
+
+	err = c.UploadDocument(Document{ID: testZipUUID, Name: "test", Parent: folder}, bytes.NewReader(content))
+	assert.NoError(t, err)
+
+	// a new client sees the changes
+	other := NewClient(c.httpClient)
+	other.BaseURL = c.BaseURL
+
+	docs, err = other.List()
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+
+	doc, err := other.Get(testZipUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", doc.Name)
+	assert.Equal(t, folder, doc.Parent)
+	assert.Equal(t, DocumentType, doc.Type)
+
+	doc.Name = "renamed"
+	doc.Parent = ""
+	doc.Bookmarked = true
+	assert.NoError(t, other.Metadata(doc))
+
+	doc, err = c.Get(testZipUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", doc.Name)
+	assert.Equal(t, "", doc.Parent)
+	assert.True(t, doc.Bookmarked)
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.Download(testZipUUID, &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, testZipUUID+".pdf")
+	assert.Contains(t, names, testZipUUID+".metadata")
+
+	// uploading again replaces the document
+	err = c.Upload(testZipUUID, "again", bytes.NewReader(content))
+	assert.NoError(t, err)
+	docs, err = c.List()
+	assert.NoError(t, err)
+	assert.Len(t, docs, 2)
+
+	assert.NoError(t, other.Delete(testZipUUID))
+	assert.NoError(t, other.Delete(folder))
+
+	docs, err = c.List()
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+
+	_, err = c.Get(testZipUUID)
+	assert.Error(t, err)
+}
+
+func TestUploadWrongID(t *testing.T) {
+	c := newTestClient(t)
+
+	content, err := os.ReadFile("test.zip")
+	assert.NoError(t, err)
+
+	err = c.Upload("00000000-0000-0000-0000-000000000000", "test", bytes.NewReader(content))
+	assert.Error(t, err)
+}
+
+func TestUploadReplacesInOneCommit(t *testing.T) {
+	api := apitest.NewServer()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.Client())
+	c.BaseURL, _ = url.Parse(srv.URL)
+
+	content, err := os.ReadFile("test.zip")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Upload(testZipUUID, "test", bytes.NewReader(content)))
+
+	generation := api.Generation()
+	doc := Document{ID: testZipUUID, Name: "again", Bookmarked: true, CurrentPage: 2}
+	assert.NoError(t, c.UploadDocument(doc, bytes.NewReader(content)))
+	assert.Equal(t, generation+1, api.Generation())
+
+	docs, err := c.List()
+	assert.NoError(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, "again", docs[0].Name)
+		assert.True(t, docs[0].Bookmarked)
+		assert.Equal(t, 2, docs[0].CurrentPage)
+	}
+}
@@ -17,6 +17,16 @@ var RootGet string
 var RootPut string
 var BlobUrl string
 
+// SyncHost is the host of the sync api
+var SyncHost string
+
+// paths of the sync api, relative to SyncHost
+const (
+	BlobPath    = "/sync/v3/files/"
+	RootGetPath = "/sync/v4/root"
+	RootPutPath = "/sync/v3/root"
+)
+
 func init() {
 	docHost := "https://document-storage-production-dot-remarkable-production.appspot.com"
 	authHost := "https://webapp-prod.cloud.remarkable.engineering"
@@ -52,7 +62,8 @@ func init() {
 	SyncComplete = syncHost + "/sync/v2/sync-complete"
 
 	// v3
-	BlobUrl = syncHost + "/sync/v3/files/"
-	RootGet = syncHost + "/sync/v4/root"
-	RootPut = syncHost + "/sync/v3/root"
+	SyncHost = syncHost
+	BlobUrl = syncHost + BlobPath
+	RootGet = syncHost + RootGetPath
+	RootPut = syncHost + RootPutPath
 }
@@ -1,8 +1,55 @@
 package rm
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
 // MarshalBinary implements encoding.MarshalBinary for
 // transforming a Rm page into bytes
-// TODO
+//
+// The layers are written for v3 and v5, the output of UnmarshalBinary is written back
+// unchanged. The scene is written for v6, a scene is made of the layers if there is none.
 func (rm *Rm) MarshalBinary() (data []byte, err error) {
-	return nil, nil
+	switch rm.Version {
+	case V3, V5:
+	case V6:
+		scene := rm.Scene
+		if scene == nil {
+			scene = sceneOfLayers(rm.Layers)
+		}
+		return marshalV6(scene), nil
+	default:
+		return nil, fmt.Errorf("unknown version %d", rm.Version)
+	}
+
+	var w bytes.Buffer
+	if rm.Version == V5 {
+		w.WriteString(HeaderV5)
+	} else {
+		w.WriteString(HeaderV3)
+	}
+
+	write := func(v interface{}) {
+		// writing to a buffer can't fail
+		_ = binary.Write(&w, binary.LittleEndian, v)
+	}
+	write(uint32(len(rm.Layers)))
+	for _, layer := range rm.Layers {
+		write(uint32(len(layer.Lines)))
+		for _, line := range layer.Lines {
+			write(line.BrushType)
+			write(line.BrushColor)
+			write(line.Padding)
+			write(line.BrushSize)
+			// this attribute has been added in v5
+			if rm.Version == V5 {
+				write(line.Unknown)
+			}
+			write(uint32(len(line.Points)))
+			write(line.Points)
+		}
+	}
+	return w.Bytes(), nil
 }
@@ -0,0 +1,204 @@
+package rm
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalBinaryV3V5(t *testing.T) {
+	for _, fn := range []string{"test_v3.rm", "test_v5.rm"} {
+		b, err := os.ReadFile(fn)
+		assert.NoError(t, err)
+
+		rm := New()
+		assert.NoError(t, rm.UnmarshalBinary(b))
+		out, err := rm.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, b, out, fn)
+	}
+}
+
+func roundTrip(t *testing.T, rm *Rm) *Rm {
+	b, err := rm.MarshalBinary()
+	assert.NoError(t, err)
+	out := New()
+	assert.NoError(t, out.UnmarshalBinary(b))
+	return out
+}
+
+func TestMarshalBinaryV6(t *testing.T) {
+	rm := New()
+	assert.NoError(t, rm.UnmarshalBinary(testV6File()))
+	out := roundTrip(t, rm)
+	assert.Equal(t, V6, out.Version)
+
+	layers, outLayers := rm.Scene.Layers(), out.Scene.Layers()
+	if !assert.Len(t, outLayers, len(layers)) {
+		return
+	}
+	for i, layer := range layers {
+		assert.Equal(t, layer.ID, outLayers[i].ID)
+		assert.Equal(t, layer.Label, outLayers[i].Label)
+		assert.Equal(t, layer.Visible, outLayers[i].Visible)
+		assert.Equal(t, layer.GlyphRanges(), outLayers[i].GlyphRanges())
+
+		lines, outLines := layer.Lines(), outLayers[i].Lines()
+		if !assert.Len(t, outLines, len(lines)) {
+			continue
+		}
+		for j, l := range lines {
+			o := outLines[j]
+			assert.Equal(t, l.ID, o.ID)
+			assert.Equal(t, l.Tool, o.Tool)
+			assert.Equal(t, l.Color, o.Color)
+			assert.Equal(t, l.ARGB, o.ARGB)
+			assert.Equal(t, l.ThicknessScale, o.ThicknessScale)
+			if !assert.Len(t, o.Points, len(l.Points)) {
+				continue
+			}
+			// the points are packed in integers
+			for k, p := range l.Points {
+				q := o.Points[k]
+				assert.Equal(t, p.X, q.X)
+				assert.Equal(t, p.Y, q.Y)
+				assert.InDelta(t, p.Speed, q.Speed, 0.125)
+				assert.InDelta(t, p.Width, q.Width, 0.125)
+				assert.InDelta(t, p.Direction, q.Direction, 0.02)
+				assert.InDelta(t, p.Pressure, q.Pressure, 0.002)
+			}
+		}
+	}
+
+	// the packed points are written back unchanged
+	assert.Equal(t, out.Scene, roundTrip(t, out).Scene)
+}
+
+// itemValues returns the values of the strokes and of the highlights of a v6 file by id
+func itemValues(t *testing.T, data []byte) map[CrdtID][]byte {
+	values := make(map[CrdtID][]byte)
+	r := &v6Reader{data: data, pos: HeaderLen, end: len(data)}
+	for r.pos < len(data) {
+		r.end = len(data)
+		length, err := r.readUint32()
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		header, err := r.next(4)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		end := r.pos + int(length)
+		r.end = end
+		if header[3] == lineItemBlock || header[3] == glyphItemBlock {
+			_, err = r.readID(1)
+			assert.NoError(t, err)
+			id, err := r.readID(2)
+			assert.NoError(t, err)
+			for i := uint64(3); i <= 4; i++ {
+				_, err = r.readID(i)
+				assert.NoError(t, err)
+			}
+			_, err = r.readInt(5)
+			assert.NoError(t, err)
+			assert.NoError(t, r.readSubblock(6, func() error {
+				values[id] = r.data[r.pos:r.end]
+				return nil
+			}))
+		}
+		r.pos = end
+	}
+	return values
+}
+
+func TestMarshalBinaryV6File(t *testing.T) {
+	b, err := os.ReadFile("test_v6.rm")
+	assert.NoError(t, err)
+	rm := New()
+	assert.NoError(t, rm.UnmarshalBinary(b))
+
+	out, err := rm.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := New()
+	assert.NoError(t, decoded.UnmarshalBinary(out))
+	assert.Equal(t, rm.Scene, decoded.Scene)
+
+	// the strokes and the highlights are written like in the file
+	values, outValues := itemValues(t, b), itemValues(t, out)
+	assert.Len(t, values, 4)
+	assert.Equal(t, values, outValues)
+}
+
+func TestMarshalBinaryV6Text(t *testing.T) {
+	rm := New()
+	assert.NoError(t, rm.UnmarshalBinary(testV6Text()))
+	out := roundTrip(t, rm)
+	assert.Equal(t, rm.Scene.Text, out.Scene.Text)
+}
+
+func TestMarshalBinaryV6Layers(t *testing.T) {
+	b, err := os.ReadFile("test_v5.rm")
+	assert.NoError(t, err)
+	rm := New()
+	assert.NoError(t, rm.UnmarshalBinary(b))
+
+	rm.Version = V6
+	out := roundTrip(t, rm)
+	if !assert.Len(t, out.Layers, len(rm.Layers)) {
+		return
+	}
+	assert.Equal(t, "Layer 1", out.Scene.Layers()[0].Label)
+	for i, layer := range rm.Layers {
+		if !assert.Len(t, out.Layers[i].Lines, len(layer.Lines)) {
+			continue
+		}
+		for j, line := range layer.Lines {
+			o := out.Layers[i].Lines[j]
+			assert.Equal(t, line.BrushType, o.BrushType)
+			assert.Equal(t, line.BrushColor, o.BrushColor)
+			assert.Equal(t, line.BrushSize, o.BrushSize)
+			assert.Len(t, o.Points, len(line.Points))
+		}
+	}
+}
+
+func TestMarshalBinaryUnknownVersion(t *testing.T) {
+	rm := New()
+	rm.Version = Version(42)
+	_, err := rm.MarshalBinary()
+	assert.Error(t, err)
+}
+
+// Removes the highlighter strokes of a page
+func ExampleRm_MarshalBinary() {
+	rm := New()
+	if err := rm.UnmarshalBinary(testV6File()); err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, layer := range rm.Scene.Layers() {
+		var items []Item
+		for _, item := range layer.Items {
+			if l, ok := item.(*SceneLine); ok && (l.Tool == HighlighterV5 || l.Tool == Highlighter) {
+				continue
+			}
+			items = append(items, item)
+		}
+		layer.Items = items
+	}
+
+	b, err := rm.MarshalBinary()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	out := New()
+	if err := out.UnmarshalBinary(b); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(len(out.Scene.Layers()[0].Lines()))
+	// Output: 1
+}
@@ -0,0 +1,460 @@
+package rm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// v6Writer writes the tagged values of a v6 file
+type v6Writer struct {
+	bytes.Buffer
+}
+
+func (w *v6Writer) varuint(n uint64) {
+	for n >= 0x80 {
+		w.WriteByte(byte(n) | 0x80)
+		n >>= 7
+	}
+	w.WriteByte(byte(n))
+}
+
+// le writes fixed size values in little endian
+func (w *v6Writer) le(v interface{}) {
+	// writing to a buffer can't fail
+	_ = binary.Write(w, binary.LittleEndian, v)
+}
+
+func (w *v6Writer) crdtID(id CrdtID) {
+	w.WriteByte(id.Part1)
+	w.varuint(id.Part2)
+}
+
+func (w *v6Writer) tag(index, tagType uint64) {
+	w.varuint(index<<4 | tagType)
+}
+
+func (w *v6Writer) id(index uint64, id CrdtID) {
+	w.tag(index, tagID)
+	w.crdtID(id)
+}
+
+func (w *v6Writer) int(index uint64, n uint32) {
+	w.tag(index, tagByte4)
+	w.le(n)
+}
+
+func (w *v6Writer) byte(index uint64, b uint8) {
+	w.tag(index, tagByte1)
+	w.WriteByte(b)
+}
+
+func (w *v6Writer) bool(index uint64, b bool) {
+	var v uint8
+	if b {
+		v = 1
+	}
+	w.byte(index, v)
+}
+
+func (w *v6Writer) float(index uint64, f float32) {
+	w.tag(index, tagByte4)
+	w.le(f)
+}
+
+func (w *v6Writer) double(index uint64, f float64) {
+	w.tag(index, tagByte8)
+	w.le(f)
+}
+
+// subblock writes the values written by write prefixed by their length
+func (w *v6Writer) subblock(index uint64, write func(w *v6Writer)) {
+	var sub v6Writer
+	write(&sub)
+	w.tag(index, tagLength4)
+	w.le(uint32(sub.Len()))
+	w.Write(sub.Bytes())
+}
+
+func (w *v6Writer) rawString(s string) {
+	w.varuint(uint64(len(s)))
+	// the strings are always flagged as ascii
+	w.WriteByte(1)
+	w.WriteString(s)
+}
+
+func (w *v6Writer) string(index uint64, s string) {
+	w.subblock(index, func(w *v6Writer) { w.rawString(s) })
+}
+
+// lww writes a last-write-wins register, the values written by rmapi have no timestamp
+func (w *v6Writer) lww(index uint64, write func(w *v6Writer)) {
+	w.subblock(index, func(w *v6Writer) {
+		w.id(1, CrdtID{})
+		write(w)
+	})
+}
+
+// block writes a block of the type blockType whose values are written by write
+func (w *v6Writer) block(blockType, minVersion, version uint8, write func(w *v6Writer)) {
+	var b v6Writer
+	write(&b)
+	w.le(uint32(b.Len()))
+	w.Write([]byte{0, minVersion, version, blockType})
+	w.Write(b.Bytes())
+}
+
+// author is the author of the items written by rmapi
+const author = 1
+
+// v6Encoder writes a scene
+type v6Encoder struct {
+	w v6Writer
+	// used are the ids of the items, written the ones already written
+	// and lastID the last id given to an item
+	used    map[CrdtID]bool
+	written map[CrdtID]bool
+	lastID  uint64
+}
+
+// newID returns an id which isn't used by another item
+func (e *v6Encoder) newID() CrdtID {
+	for {
+		e.lastID++
+		id := CrdtID{author, e.lastID}
+		if !e.used[id] {
+			e.used[id] = true
+			e.written[id] = true
+			return id
+		}
+	}
+}
+
+// itemID returns the id of the item unless it's missing or already used
+func (e *v6Encoder) itemID(id CrdtID) CrdtID {
+	if id == (CrdtID{}) || e.written[id] {
+		return e.newID()
+	}
+	e.written[id] = true
+	return id
+}
+
+// marshalV6 writes the scene in the v6 format, the items keep their ids except the groups
+// in the sequences of their parent, which aren't kept by the scene. The characters of the
+// typed text get new ids too.
+func marshalV6(scene *Scene) []byte {
+	e := &v6Encoder{used: make(map[CrdtID]bool), written: make(map[CrdtID]bool)}
+	root := scene.Root
+	if root == nil {
+		root = &Group{ID: RootID, Visible: true}
+	}
+
+	// the ids of the groups and of the items can't be given to new items
+	var groups []*Group
+	var collect func(g *Group)
+	collect = func(g *Group) {
+		groups = append(groups, g)
+		for _, item := range g.Items {
+			e.used[item.ItemID()] = true
+			if child, ok := item.(*Group); ok {
+				collect(child)
+			}
+		}
+	}
+	collect(root)
+	e.used[root.ID] = true
+
+	w := &e.w
+	w.WriteString(HeaderV6)
+	w.block(authorIdsBlock, 1, 1, func(w *v6Writer) {
+		w.varuint(1)
+		w.subblock(0, func(w *v6Writer) {
+			id := uuid.New()
+			w.varuint(uint64(len(id)))
+			w.Write(id[:])
+			w.le(uint16(author))
+		})
+	})
+	w.block(migrationInfoBlock, 1, 1, func(w *v6Writer) {
+		w.id(1, CrdtID{author, 1})
+		w.bool(2, true)
+	})
+	w.block(pageInfoBlock, 0, 1, func(w *v6Writer) {
+		w.int(1, 1)
+		w.int(2, 0)
+		chars, lines := 0, 0
+		if scene.Text != nil {
+			lines = len(scene.Text.Paragraphs)
+			for _, p := range scene.Text.Paragraphs {
+				chars += len([]rune(p.String()))
+			}
+		}
+		w.int(3, uint32(chars))
+		w.int(4, uint32(lines))
+	})
+
+	for _, g := range groups[1:] {
+		g := g
+		w.block(sceneTreeBlock, 1, 1, func(w *v6Writer) {
+			w.id(1, g.ID)
+			w.id(2, CrdtID{})
+			w.bool(3, true)
+			w.subblock(4, func(w *v6Writer) { w.id(1, parentOf(groups, g).ID) })
+		})
+	}
+	if scene.Text != nil {
+		e.writeText(scene.Text)
+	}
+	for _, g := range groups {
+		writeTreeNode(w, g)
+	}
+	for _, g := range groups {
+		e.writeItems(g)
+	}
+	return w.Bytes()
+}
+
+// parentOf returns the group containing g
+func parentOf(groups []*Group, g *Group) *Group {
+	for _, p := range groups {
+		for _, item := range p.Items {
+			if item == Item(g) {
+				return p
+			}
+		}
+	}
+	return groups[0]
+}
+
+func writeTreeNode(w *v6Writer, g *Group) {
+	w.block(treeNodeBlock, 1, 1, func(w *v6Writer) {
+		w.id(1, g.ID)
+		w.lww(2, func(w *v6Writer) { w.string(2, g.Label) })
+		w.lww(3, func(w *v6Writer) { w.bool(2, g.Visible) })
+		if g.AnchorID == nil {
+			return
+		}
+		w.lww(7, func(w *v6Writer) { w.id(2, *g.AnchorID) })
+		w.lww(8, func(w *v6Writer) { w.byte(2, g.AnchorType) })
+		w.lww(9, func(w *v6Writer) { w.float(2, g.AnchorThreshold) })
+		w.lww(10, func(w *v6Writer) { w.float(2, g.AnchorOriginX) })
+	})
+}
+
+// writeItems writes the items of the group g in order
+func (e *v6Encoder) writeItems(g *Group) {
+	left := CrdtID{}
+	for _, item := range g.Items {
+		var blockType, itemType, minVersion, version uint8
+		var id CrdtID
+		var value func(w *v6Writer)
+		switch i := item.(type) {
+		case *Group:
+			blockType, itemType, minVersion, version = groupItemBlock, groupItem, 0, 1
+			id = e.newID()
+			value = func(w *v6Writer) { w.id(2, i.ID) }
+		case *SceneLine:
+			blockType, itemType, minVersion, version = lineItemBlock, lineItem, 2, 2
+			id = e.itemID(i.ID)
+			value = func(w *v6Writer) { writeLine(w, i, id) }
+		case *GlyphRange:
+			blockType, itemType, minVersion, version = glyphItemBlock, glyphItem, 0, 1
+			id = e.itemID(i.ID)
+			value = func(w *v6Writer) { writeGlyphRange(w, i) }
+		default:
+			continue
+		}
+
+		e.w.block(blockType, minVersion, version, func(w *v6Writer) {
+			w.id(1, g.ID)
+			w.id(2, id)
+			w.id(3, left)
+			w.id(4, CrdtID{})
+			w.int(5, 0)
+			w.subblock(6, func(w *v6Writer) {
+				w.WriteByte(itemType)
+				value(w)
+			})
+		})
+		left = id
+	}
+}
+
+// writeLine writes a line with the points of the version 2
+func writeLine(w *v6Writer, line *SceneLine, id CrdtID) {
+	w.int(1, uint32(line.Tool))
+	w.int(2, uint32(line.Color))
+	w.double(3, line.ThicknessScale)
+	w.float(4, line.StartingLength)
+	w.subblock(5, func(w *v6Writer) {
+		for _, p := range line.Points {
+			w.le(p.X)
+			w.le(p.Y)
+			w.le(uint16(clamp(math.Round(float64(p.Speed)*4), math.MaxUint16)))
+			w.le(uint16(clamp(math.Round(float64(p.Width)*4), math.MaxUint16)))
+			w.le(uint8(clamp(math.Round(float64(p.Direction)*255/(2*math.Pi)), math.MaxUint8)))
+			w.le(uint8(clamp(math.Round(float64(p.Pressure)*255), math.MaxUint8)))
+		}
+	})
+	w.id(6, id)
+	if line.ARGB != 0 {
+		w.int(8, line.ARGB)
+	}
+}
+
+func clamp(v, max float64) float64 {
+	return math.Max(0, math.Min(v, max))
+}
+
+func writeGlyphRange(w *v6Writer, glyph *GlyphRange) {
+	if glyph.Start >= 0 {
+		w.int(2, uint32(glyph.Start))
+	}
+	w.int(3, uint32(glyph.Length))
+	w.int(4, uint32(glyph.Color))
+	w.string(5, glyph.Text)
+	w.subblock(6, func(w *v6Writer) {
+		w.varuint(uint64(len(glyph.Rects)))
+		for _, r := range glyph.Rects {
+			w.le([]float64{r.X, r.Y, r.W, r.H})
+		}
+	})
+	if glyph.ARGB != 0 {
+		w.int(7, glyph.ARGB)
+	}
+}
+
+// writeText writes the typed text, an item per span and per formatting code
+func (e *v6Encoder) writeText(text *Text) {
+	type textItem struct {
+		id    CrdtID
+		text  string
+		code  uint32
+		style *ParagraphStyle
+	}
+	var items []textItem
+	// the ids of the characters of an item follow the id of the item
+	next := uint64(0)
+	add := func(s string, code uint32) CrdtID {
+		id := CrdtID{author, next + 1}
+		n := uint64(len([]rune(s)))
+		if code != 0 {
+			n = 1
+		}
+		next += n
+		items = append(items, textItem{id: id, text: s, code: code})
+		return id
+	}
+
+	styles := make(map[CrdtID]ParagraphStyle)
+	var bold, italic bool
+	for i, p := range text.Paragraphs {
+		if i == 0 {
+			styles[CrdtID{}] = p.Style
+		} else {
+			styles[add("\n", 0)] = p.Style
+		}
+		for _, s := range p.Spans {
+			if s.Text == "" {
+				continue
+			}
+			if s.Bold != bold {
+				bold = s.Bold
+				add("", map[bool]uint32{true: formatBoldOn, false: formatBoldOff}[bold])
+			}
+			if s.Italic != italic {
+				italic = s.Italic
+				add("", map[bool]uint32{true: formatItalicOn, false: formatItalicOff}[italic])
+			}
+			add(s.Text, 0)
+		}
+	}
+
+	e.w.block(rootTextBlock, 0, 1, func(w *v6Writer) {
+		w.id(1, CrdtID{})
+		w.subblock(2, func(w *v6Writer) {
+			w.subblock(1, func(w *v6Writer) {
+				w.subblock(1, func(w *v6Writer) {
+					w.varuint(uint64(len(items)))
+					left := CrdtID{}
+					for _, item := range items {
+						item := item
+						w.subblock(0, func(w *v6Writer) {
+							w.id(2, item.id)
+							w.id(3, left)
+							w.id(4, CrdtID{})
+							w.int(5, 0)
+							w.subblock(6, func(w *v6Writer) {
+								w.rawString(item.text)
+								if item.code != 0 {
+									w.int(2, item.code)
+								}
+							})
+						})
+						// the last character of the item
+						left = item.id
+						if n := len([]rune(item.text)); n > 1 {
+							left.Part2 += uint64(n - 1)
+						}
+					}
+				})
+			})
+			w.subblock(2, func(w *v6Writer) {
+				w.subblock(1, func(w *v6Writer) {
+					w.varuint(uint64(len(styles)))
+					for _, id := range sortedIDs(styles) {
+						w.crdtID(id)
+						w.id(1, CrdtID{})
+						w.subblock(2, func(w *v6Writer) {
+							w.Write([]byte{17, byte(styles[id])})
+						})
+					}
+				})
+			})
+		})
+		w.subblock(3, func(w *v6Writer) {
+			w.le([]float64{text.X, text.Y})
+		})
+		w.float(4, text.Width)
+	})
+}
+
+func sortedIDs(styles map[CrdtID]ParagraphStyle) []CrdtID {
+	ids := make([]CrdtID, 0, len(styles))
+	for id := range styles {
+		ids = append(ids, id)
+	}
+	for i := 1; i < len(ids); i++ {
+		for j := i; j > 0 && ids[j].less(ids[j-1]); j-- {
+			ids[j], ids[j-1] = ids[j-1], ids[j]
+		}
+	}
+	return ids
+}
+
+// sceneOfLayers returns a scene with a group per layer
+func sceneOfLayers(layers []Layer) *Scene {
+	root := &Group{ID: RootID, Visible: true}
+	for i, layer := range layers {
+		g := &Group{ID: CrdtID{0, uint64(11 + i)}, Label: fmt.Sprintf("Layer %d", i+1), Visible: true}
+		for _, line := range layer.Lines {
+			l := &SceneLine{
+				Tool:           line.BrushType,
+				Color:          line.BrushColor,
+				ThicknessScale: float64(line.BrushSize),
+				StartingLength: line.Unknown,
+				Points:         make([]Point, len(line.Points)),
+			}
+			for j, p := range line.Points {
+				p.X -= float32(Width) / 2
+				l.Points[j] = p
+			}
+			g.Items = append(g.Items, l)
+		}
+		root.Items = append(root.Items, g)
+	}
+	return &Scene{Root: root}
+}
@@ -11,6 +11,11 @@
 // To mention that the format has since evolve to a new version labeled as v3 in the
 // header. This implementation is targeting this new version.
 //
+// The v6 format written by the current firmwares is a stream of tagged blocks describing
+// a tree of groups, lines and highlights. It is decoded into a Scene, the lines are also
+// available as layers like for the older versions.
+// https://github.com/ricklupton/rmscene documents this format.
+//
 // As Ben Johnson says, "In the Go standard library, we use the term encoding
 // and marshaling for two separate but related ideas. An encoder in Go is an object
 // that applies structure to a stream of bytes while marshaling refers
@@ -35,6 +40,7 @@ package rm
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -44,12 +50,14 @@ type Version int
 const (
 	V3 Version = iota
 	V5
+	V6
 )
 
 // Header starting a .rm binary file. This can help recognizing a .rm file.
 const (
 	HeaderV3  = "reMarkable .lines file, version=3          "
 	HeaderV5  = "reMarkable .lines file, version=5          "
+	HeaderV6  = "reMarkable .lines file, version=6          "
 	HeaderLen = 43
 )
 
@@ -59,16 +67,55 @@ const (
 	Height int = 1872
 )
 
-// BrushColor defines the 3 colors of the brush.
+// BrushColor defines the colors of the brush.
 type BrushColor uint32
 
-// Mapping of the three colors.
+// Mapping of the colors, the v6 format brings the colors after White.
 const (
 	Black BrushColor = 0
 	Grey  BrushColor = 1
 	White BrushColor = 2
+
+	Yellow      BrushColor = 3
+	Green       BrushColor = 4
+	Pink        BrushColor = 5
+	Blue        BrushColor = 6
+	Red         BrushColor = 7
+	GreyOverlap BrushColor = 8
+	// Highlight and the next ones are the colors of the highlighters and of the shader,
+	// the exact color is given by the ARGB value of the line
+	Highlight BrushColor = 9
+	Green2    BrushColor = 10
+	Cyan      BrushColor = 11
+	Magenta   BrushColor = 12
+	Yellow2   BrushColor = 13
 )
 
+var colorNames = map[BrushColor]string{
+	Black:       "black",
+	Grey:        "grey",
+	White:       "white",
+	Yellow:      "yellow",
+	Green:       "green",
+	Pink:        "pink",
+	Blue:        "blue",
+	Red:         "red",
+	GreyOverlap: "grey-overlap",
+	Highlight:   "highlight",
+	Green2:      "green-2",
+	Cyan:        "cyan",
+	Magenta:     "magenta",
+	Yellow2:     "yellow-2",
+}
+
+// String returns the name of the color, or its number if unknown
+func (c BrushColor) String() string {
+	if name, ok := colorNames[c]; ok {
+		return name
+	}
+	return strconv.Itoa(int(c))
+}
+
 // BrushType respresents the type of brush.
 //
 // The different types of brush are explained here:
@@ -95,6 +142,10 @@ const (
 	TiltPencilV5  BrushType = 14
 	BrushV5       BrushType = 12
 	HighlighterV5 BrushType = 18
+
+	// v6 brings new brushes
+	Calligraphy BrushType = 21
+	Shader      BrushType = 23
 )
 
 // BrushSize represents the base brush sizes.
@@ -109,9 +160,14 @@ const (
 
 // A Rm represents an entire .rm file
 // and is composed of layers.
+//
+// The layers of a v6 file are the visible layers of its scene, their points use
+// the coordinates of the older versions.
 type Rm struct {
 	Version Version
 	Layers  []Layer
+	// Scene is the full content of a v6 file, nil for the older versions
+	Scene *Scene
 }
 
 // A Layer contains lines.
@@ -130,6 +186,8 @@ type Line struct {
 }
 
 // A Point has coordinates.
+//
+// The direction is in radians and the pressure between 0 and 1 for all the versions.
 type Point struct {
 	X         float32
 	Y         float32
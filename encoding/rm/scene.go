@@ -0,0 +1,181 @@
+package rm
+
+import "fmt"
+
+// CrdtID identifies an item of a v6 scene, it is made of the id of
+// the author of the item and of a counter.
+type CrdtID struct {
+	Part1 uint8
+	Part2 uint64
+}
+
+// String implements the fmt.Stringer interface
+func (id CrdtID) String() string {
+	return fmt.Sprintf("%d:%d", id.Part1, id.Part2)
+}
+
+// less orders the ids like the device does when two items are at the same place
+func (id CrdtID) less(other CrdtID) bool {
+	if id.Part1 != other.Part1 {
+		return id.Part1 < other.Part1
+	}
+	return id.Part2 < other.Part2
+}
+
+// RootID is the id of the root group of every scene.
+var RootID = CrdtID{0, 1}
+
+// A Scene is the content of a v6 .rm file.
+//
+// It is a tree of groups, the layers are the groups of the root.
+// The x coordinates of a scene start at the middle of the page.
+type Scene struct {
+	Root *Group
+	// Text is the typed text of the page, nil if there is none
+	Text *Text
+}
+
+// An Item is an element of a group: a *Group, a *SceneLine or a *GlyphRange.
+type Item interface {
+	ItemID() CrdtID
+}
+
+// A Group contains items in drawing order.
+type Group struct {
+	ID      CrdtID
+	Label   string
+	Visible bool
+	// AnchorID is set when the group follows a character of the typed text,
+	// it is then placed relatively to this character with the other anchor fields
+	AnchorID        *CrdtID
+	AnchorType      uint8
+	AnchorThreshold float32
+	AnchorOriginX   float32
+	Items           []Item
+}
+
+// A SceneLine is a stroke of a v6 scene.
+type SceneLine struct {
+	ID    CrdtID
+	Tool  BrushType
+	Color BrushColor
+	// ARGB is the exact color of the lines using the highlighter colors, 0 if unknown
+	ARGB           uint32
+	ThicknessScale float64
+	StartingLength float32
+	Points         []Point
+}
+
+// A GlyphRange is a highlighted text of a PDF or an EPUB.
+type GlyphRange struct {
+	ID CrdtID
+	// Start is the offset of the text in the page, -1 if unknown
+	Start  int
+	Length int
+	Color  BrushColor
+	// ARGB is the exact color of the highlight, 0 if unknown
+	ARGB  uint32
+	Text  string
+	Rects []Rect
+}
+
+// A Rect is an area of the page, the rectangles of a highlight cover its text.
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// ItemID implements the Item interface
+func (g *Group) ItemID() CrdtID { return g.ID }
+
+// ItemID implements the Item interface
+func (l *SceneLine) ItemID() CrdtID { return l.ID }
+
+// ItemID implements the Item interface
+func (g *GlyphRange) ItemID() CrdtID { return g.ID }
+
+// Layers returns the groups of the root, which the device shows as layers.
+func (s *Scene) Layers() []*Group {
+	if s.Root == nil {
+		return nil
+	}
+	var layers []*Group
+	for _, item := range s.Root.Items {
+		if g, ok := item.(*Group); ok {
+			layers = append(layers, g)
+		}
+	}
+	return layers
+}
+
+// Lines returns the lines of the group and of its subgroups in drawing order.
+func (g *Group) Lines() []*SceneLine {
+	var lines []*SceneLine
+	for _, item := range g.Items {
+		switch i := item.(type) {
+		case *SceneLine:
+			lines = append(lines, i)
+		case *Group:
+			lines = append(lines, i.Lines()...)
+		}
+	}
+	return lines
+}
+
+// GlyphRanges returns the highlights of the group and of its subgroups.
+func (g *Group) GlyphRanges() []*GlyphRange {
+	var ranges []*GlyphRange
+	for _, item := range g.Items {
+		switch i := item.(type) {
+		case *GlyphRange:
+			ranges = append(ranges, i)
+		case *Group:
+			ranges = append(ranges, i.GlyphRanges()...)
+		}
+	}
+	return ranges
+}
+
+// line returns the line in the coordinates of the older versions.
+func (l *SceneLine) line() Line {
+	line := Line{
+		BrushType:  l.Tool,
+		BrushColor: l.Color,
+		BrushSize:  BrushSize(l.ThicknessScale),
+		Unknown:    l.StartingLength,
+		Points:     make([]Point, len(l.Points)),
+	}
+	for i, p := range l.Points {
+		p.X += float32(Width) / 2
+		line.Points[i] = p
+	}
+	return line
+}
+
+// layers returns the visible layers of the scene like the older versions,
+// the lines outside of the layers make a last layer
+func (s *Scene) layers() []Layer {
+	if s.Root == nil {
+		return nil
+	}
+	var layers []Layer
+	var loose Layer
+	for _, item := range s.Root.Items {
+		switch i := item.(type) {
+		case *Group:
+			if !i.Visible {
+				continue
+			}
+			var layer Layer
+			for _, l := range i.Lines() {
+				layer.Lines = append(layer.Lines, l.line())
+			}
+			layers = append(layers, layer)
+		case *SceneLine:
+			loose.Lines = append(loose.Lines, i.line())
+		}
+	}
+	if len(loose.Lines) > 0 {
+		layers = append(layers, loose)
+	}
+	return layers
+}
@@ -0,0 +1,179 @@
+package rm
+
+import "strings"
+
+// ParagraphStyle is the style of a paragraph of typed text.
+type ParagraphStyle uint8
+
+// Mapping of the paragraph styles of the device.
+const (
+	StyleBasic           ParagraphStyle = 0
+	StylePlain           ParagraphStyle = 1
+	StyleHeading         ParagraphStyle = 2
+	StyleBold            ParagraphStyle = 3
+	StyleBullet          ParagraphStyle = 4
+	StyleBullet2         ParagraphStyle = 5
+	StyleCheckbox        ParagraphStyle = 6
+	StyleCheckboxChecked ParagraphStyle = 7
+)
+
+// codes of the inline formatting of typed text
+const (
+	formatBoldOn    = 1
+	formatBoldOff   = 2
+	formatItalicOn  = 3
+	formatItalicOff = 4
+)
+
+// A Text is the typed text of a v6 page.
+type Text struct {
+	// X, Y and Width place the text block on the page
+	X, Y       float64
+	Width      float32
+	Paragraphs []Paragraph
+}
+
+// A Paragraph is a line of typed text with its style.
+type Paragraph struct {
+	Style ParagraphStyle
+	Spans []Span
+}
+
+// A Span is a part of a paragraph with the same inline formatting.
+type Span struct {
+	Text   string
+	Bold   bool
+	Italic bool
+}
+
+// String returns the text of the paragraph without formatting.
+func (p Paragraph) String() string {
+	var sb strings.Builder
+	for _, s := range p.Spans {
+		sb.WriteString(s.Text)
+	}
+	return sb.String()
+}
+
+// String returns the text without formatting, a line per paragraph.
+func (t *Text) String() string {
+	lines := make([]string, len(t.Paragraphs))
+	for i, p := range t.Paragraphs {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Markdown returns the text in Markdown: headings, bullets, checkboxes,
+// bold and italic spans. Empty paragraphs are dropped.
+func (t *Text) Markdown() string {
+	var sb strings.Builder
+	wasList := false
+	for _, p := range t.Paragraphs {
+		text := p.markdownSpans()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		prefix, isList := "", true
+		switch p.Style {
+		case StyleHeading:
+			prefix, isList = "# ", false
+		case StyleBold:
+			prefix, isList = "## ", false
+		case StyleBullet:
+			prefix = "- "
+		case StyleBullet2:
+			prefix = "  - "
+		case StyleCheckbox:
+			prefix = "- [ ] "
+		case StyleCheckboxChecked:
+			prefix = "- [x] "
+		default:
+			isList = false
+		}
+
+		if sb.Len() > 0 {
+			// the items of a list are on consecutive lines
+			if isList && wasList {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(prefix + text)
+		wasList = isList
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// markdownSpans returns the spans of the paragraph with their inline formatting
+func (p Paragraph) markdownSpans() string {
+	var sb strings.Builder
+	for _, s := range p.Spans {
+		marker := ""
+		if s.Bold {
+			marker += "**"
+		}
+		if s.Italic {
+			marker += "*"
+		}
+		text := strings.TrimSpace(s.Text)
+		if marker == "" || text == "" {
+			sb.WriteString(s.Text)
+			continue
+		}
+		// the markers must be next to the text
+		i := strings.Index(s.Text, text)
+		sb.WriteString(s.Text[:i] + marker + text + marker + s.Text[i+len(text):])
+	}
+	return sb.String()
+}
+
+// paragraphs builds the paragraphs of the characters of a text in order, a character
+// is a string or a formatting code. The paragraphs start after a new line, their
+// style is given by the id of the new line or by the zero id for the first one.
+func paragraphs(chars []*sceneItem, styles map[CrdtID]ParagraphStyle) []Paragraph {
+	style := func(id CrdtID) ParagraphStyle {
+		if s, ok := styles[id]; ok {
+			return s
+		}
+		return StylePlain
+	}
+
+	result := []Paragraph{{Style: style(CrdtID{})}}
+	var bold, italic bool
+	for _, c := range chars {
+		if c.deleted > 0 {
+			continue
+		}
+		switch v := c.value.(type) {
+		case uint32:
+			switch v {
+			case formatBoldOn:
+				bold = true
+			case formatBoldOff:
+				bold = false
+			case formatItalicOn:
+				italic = true
+			case formatItalicOff:
+				italic = false
+			}
+		case string:
+			if v == "\n" {
+				result = append(result, Paragraph{Style: style(c.id)})
+				continue
+			}
+			p := &result[len(result)-1]
+			if n := len(p.Spans); n > 0 && p.Spans[n-1].Bold == bold && p.Spans[n-1].Italic == italic {
+				p.Spans[n-1].Text += v
+			} else {
+				p.Spans = append(p.Spans, Span{Text: v, Bold: bold, Italic: italic})
+			}
+		}
+	}
+	return result
+}
@@ -15,6 +15,16 @@ func (rm *Rm) UnmarshalBinary(data []byte) error {
 	}
 	rm.Version = r.version
 
+	if rm.Version == V6 {
+		scene, err := unmarshalV6(data)
+		if err != nil {
+			return err
+		}
+		rm.Scene = scene
+		rm.Layers = scene.layers()
+		return nil
+	}
+
 	nbLayers, err := r.readNumber()
 	if err != nil {
 		return err
@@ -66,6 +76,8 @@ func (r *reader) checkHeader() error {
 	}
 
 	switch string(buf) {
+	case HeaderV6:
+		r.version = V6
 	case HeaderV5:
 		r.version = V5
 	case HeaderV3:
@@ -0,0 +1,851 @@
+package rm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// types of the blocks of the v6 format
+const (
+	migrationInfoBlock = 0x00
+	sceneTreeBlock     = 0x01
+	treeNodeBlock      = 0x02
+	glyphItemBlock     = 0x03
+	groupItemBlock     = 0x04
+	lineItemBlock      = 0x05
+	textItemBlock      = 0x06
+	rootTextBlock      = 0x07
+	tombstoneItemBlock = 0x08
+	authorIdsBlock     = 0x09
+	pageInfoBlock      = 0x0a
+	sceneInfoBlock     = 0x0d
+)
+
+// types of the values of the v6 format, given by their tag
+const (
+	tagByte1   = 0x1
+	tagByte4   = 0x4
+	tagByte8   = 0x8
+	tagLength4 = 0xc
+	tagID      = 0xf
+)
+
+// types of the items of the v6 format
+const (
+	glyphItem = 0x01
+	groupItem = 0x02
+	lineItem  = 0x03
+)
+
+var errV6Truncated = errors.New("truncated v6 data")
+
+// v6Reader reads the tagged values of a v6 file
+type v6Reader struct {
+	data []byte
+	pos  int
+	// end is the end of the current block or subblock
+	end int
+}
+
+func (r *v6Reader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > r.end {
+		return nil, errV6Truncated
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *v6Reader) readUint8() (uint8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *v6Reader) readUint16() (uint16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (r *v6Reader) readUint32() (uint32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *v6Reader) readFloat32() (float32, error) {
+	n, err := r.readUint32()
+	return math.Float32frombits(n), err
+}
+
+func (r *v6Reader) readFloat64() (float64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (r *v6Reader) readVaruint() (uint64, error) {
+	var n uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b, err := r.readUint8()
+		if err != nil {
+			return 0, err
+		}
+		n |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return n, nil
+		}
+	}
+	return 0, errors.New("invalid varuint")
+}
+
+func (r *v6Reader) readCrdtID() (CrdtID, error) {
+	part1, err := r.readUint8()
+	if err != nil {
+		return CrdtID{}, err
+	}
+	part2, err := r.readVaruint()
+	return CrdtID{part1, part2}, err
+}
+
+// hasTag tells whether the next value has the tag index and type
+func (r *v6Reader) hasTag(index, tagType uint64) bool {
+	pos := r.pos
+	tag, err := r.readVaruint()
+	r.pos = pos
+	return err == nil && tag == index<<4|tagType
+}
+
+func (r *v6Reader) readTag(index, tagType uint64) error {
+	tag, err := r.readVaruint()
+	if err != nil {
+		return err
+	}
+	if tag != index<<4|tagType {
+		return fmt.Errorf("unexpected tag %#x at %d, expected %#x", tag, r.pos, index<<4|tagType)
+	}
+	return nil
+}
+
+func (r *v6Reader) readID(index uint64) (CrdtID, error) {
+	if err := r.readTag(index, tagID); err != nil {
+		return CrdtID{}, err
+	}
+	return r.readCrdtID()
+}
+
+func (r *v6Reader) readBool(index uint64) (bool, error) {
+	b, err := r.readByte(index)
+	return b != 0, err
+}
+
+func (r *v6Reader) readByte(index uint64) (uint8, error) {
+	if err := r.readTag(index, tagByte1); err != nil {
+		return 0, err
+	}
+	return r.readUint8()
+}
+
+func (r *v6Reader) readInt(index uint64) (uint32, error) {
+	if err := r.readTag(index, tagByte4); err != nil {
+		return 0, err
+	}
+	return r.readUint32()
+}
+
+func (r *v6Reader) readFloat(index uint64) (float32, error) {
+	if err := r.readTag(index, tagByte4); err != nil {
+		return 0, err
+	}
+	return r.readFloat32()
+}
+
+func (r *v6Reader) readDouble(index uint64) (float64, error) {
+	if err := r.readTag(index, tagByte8); err != nil {
+		return 0, err
+	}
+	return r.readFloat64()
+}
+
+// readSubblock calls read with the reader limited to the subblock index,
+// the rest of the subblock is skipped
+func (r *v6Reader) readSubblock(index uint64, read func() error) error {
+	if err := r.readTag(index, tagLength4); err != nil {
+		return err
+	}
+	length, err := r.readUint32()
+	if err != nil {
+		return err
+	}
+	end := r.pos + int(length)
+	if end > r.end {
+		return errV6Truncated
+	}
+
+	parentEnd := r.end
+	r.end = end
+	err = read()
+	r.pos, r.end = end, parentEnd
+	return err
+}
+
+func (r *v6Reader) readString(index uint64) (s string, err error) {
+	err = r.readSubblock(index, func() error {
+		length, err := r.readVaruint()
+		if err != nil {
+			return err
+		}
+		// the flag telling whether the string is ascii
+		if _, err := r.readUint8(); err != nil {
+			return err
+		}
+		b, err := r.next(int(length))
+		s = string(b)
+		return err
+	})
+	return
+}
+
+// readLww calls read for the value of the last-write-wins register index
+func (r *v6Reader) readLww(index uint64, read func() error) error {
+	return r.readSubblock(index, func() error {
+		if _, err := r.readID(1); err != nil {
+			return err
+		}
+		return read()
+	})
+}
+
+// sceneItem is an item of the sequence of the children of a group
+type sceneItem struct {
+	parent, id, left, right CrdtID
+	deleted                 uint32
+	// run is the number of characters of a deleted item of the text, their ids follow id
+	run uint64
+	// value is a group id, a *SceneLine, a *GlyphRange, a character or a formatting
+	// code of the text, or nil for deleted items
+	value interface{}
+}
+
+// sceneBuilder collects the blocks of a scene
+type sceneBuilder struct {
+	groups map[CrdtID]*Group
+	items  map[CrdtID][]*sceneItem
+	text   *Text
+	// chars are the characters of the text, a string or a formatting code each
+	chars  []*sceneItem
+	styles map[CrdtID]ParagraphStyle
+}
+
+func (b *sceneBuilder) group(id CrdtID) *Group {
+	g, ok := b.groups[id]
+	if !ok {
+		g = &Group{ID: id, Visible: true}
+		b.groups[id] = g
+	}
+	return g
+}
+
+// unmarshalV6 decodes the blocks following the header of a v6 file
+func unmarshalV6(data []byte) (*Scene, error) {
+	b := &sceneBuilder{
+		groups: make(map[CrdtID]*Group),
+		items:  make(map[CrdtID][]*sceneItem),
+	}
+	r := &v6Reader{data: data, pos: HeaderLen, end: len(data)}
+	for r.pos < len(data) {
+		r.end = len(data)
+		length, err := r.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		header, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		version, blockType := header[2], header[3]
+
+		end := r.pos + int(length)
+		if end > len(data) {
+			return nil, errV6Truncated
+		}
+		r.end = end
+		if err := b.readBlock(r, blockType, version); err != nil {
+			return nil, fmt.Errorf("block %#x: %w", blockType, err)
+		}
+		r.pos = end
+	}
+
+	root := b.group(RootID)
+	if err := b.fill(root, make(map[CrdtID]bool)); err != nil {
+		return nil, err
+	}
+	if b.text != nil {
+		chars, err := sortSequence(b.chars)
+		if err != nil {
+			return nil, fmt.Errorf("text: %w", err)
+		}
+		b.text.Paragraphs = paragraphs(chars, b.styles)
+	}
+	return &Scene{Root: root, Text: b.text}, nil
+}
+
+func (b *sceneBuilder) readBlock(r *v6Reader, blockType, version uint8) error {
+	switch blockType {
+	case sceneTreeBlock:
+		id, err := r.readID(1)
+		if err != nil {
+			return err
+		}
+		b.group(id)
+	case treeNodeBlock:
+		return b.readTreeNode(r)
+	case glyphItemBlock, groupItemBlock, lineItemBlock, tombstoneItemBlock:
+		item, err := readSceneItem(r, version)
+		if err != nil {
+			return err
+		}
+		b.items[item.parent] = append(b.items[item.parent], item)
+	case rootTextBlock:
+		return b.readRootText(r)
+	}
+	// the other blocks don't change the drawing
+	return nil
+}
+
+func (b *sceneBuilder) readTreeNode(r *v6Reader) error {
+	id, err := r.readID(1)
+	if err != nil {
+		return err
+	}
+	g := b.group(id)
+
+	err = r.readLww(2, func() (err error) {
+		g.Label, err = r.readString(2)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	err = r.readLww(3, func() (err error) {
+		g.Visible, err = r.readBool(2)
+		return
+	})
+	if err != nil || !r.hasTag(7, tagLength4) {
+		return err
+	}
+
+	var anchor CrdtID
+	err = r.readLww(7, func() (err error) {
+		anchor, err = r.readID(2)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	g.AnchorID = &anchor
+	err = r.readLww(8, func() (err error) {
+		g.AnchorType, err = r.readByte(2)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	err = r.readLww(9, func() (err error) {
+		g.AnchorThreshold, err = r.readFloat(2)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	return r.readLww(10, func() (err error) {
+		g.AnchorOriginX, err = r.readFloat(2)
+		return
+	})
+}
+
+func readSceneItem(r *v6Reader, version uint8) (*sceneItem, error) {
+	item := &sceneItem{}
+	var err error
+	if item.parent, err = r.readID(1); err != nil {
+		return nil, err
+	}
+	if item.id, err = r.readID(2); err != nil {
+		return nil, err
+	}
+	if item.left, err = r.readID(3); err != nil {
+		return nil, err
+	}
+	if item.right, err = r.readID(4); err != nil {
+		return nil, err
+	}
+	if item.deleted, err = r.readInt(5); err != nil {
+		return nil, err
+	}
+	if !r.hasTag(6, tagLength4) {
+		return item, nil
+	}
+
+	err = r.readSubblock(6, func() error {
+		itemType, err := r.readUint8()
+		if err != nil {
+			return err
+		}
+		switch itemType {
+		case groupItem:
+			item.value, err = r.readID(2)
+		case lineItem:
+			item.value, err = readLine(r, item.id, version)
+		case glyphItem:
+			item.value, err = readGlyphRange(r, item.id)
+		}
+		return err
+	})
+	return item, err
+}
+
+func readLine(r *v6Reader, id CrdtID, version uint8) (*SceneLine, error) {
+	line := &SceneLine{ID: id}
+	tool, err := r.readInt(1)
+	if err != nil {
+		return nil, err
+	}
+	line.Tool = BrushType(tool)
+	color, err := r.readInt(2)
+	if err != nil {
+		return nil, err
+	}
+	line.Color = BrushColor(color)
+	if line.ThicknessScale, err = r.readDouble(3); err != nil {
+		return nil, err
+	}
+	if line.StartingLength, err = r.readFloat(4); err != nil {
+		return nil, err
+	}
+
+	err = r.readSubblock(5, func() error {
+		for r.pos < r.end {
+			p, err := readPoint(r, version)
+			if err != nil {
+				return err
+			}
+			line.Points = append(line.Points, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the timestamp and the move id are not needed
+	if _, err := r.readID(6); err != nil {
+		return nil, err
+	}
+	if r.hasTag(7, tagID) {
+		if _, err := r.readID(7); err != nil {
+			return nil, err
+		}
+	}
+	if r.hasTag(8, tagByte4) {
+		if line.ARGB, err = r.readInt(8); err != nil {
+			return nil, err
+		}
+	}
+	return line, nil
+}
+
+// readPoint reads a point, the version 1 stores floats while the version 2
+// packs the speed, the width, the direction and the pressure in integers
+func readPoint(r *v6Reader, version uint8) (p Point, err error) {
+	if p.X, err = r.readFloat32(); err != nil {
+		return
+	}
+	if p.Y, err = r.readFloat32(); err != nil {
+		return
+	}
+
+	if version == 1 {
+		if p.Speed, err = r.readFloat32(); err != nil {
+			return
+		}
+		if p.Direction, err = r.readFloat32(); err != nil {
+			return
+		}
+		if p.Width, err = r.readFloat32(); err != nil {
+			return
+		}
+		p.Pressure, err = r.readFloat32()
+		return
+	}
+
+	speed, err := r.readUint16()
+	if err != nil {
+		return
+	}
+	width, err := r.readUint16()
+	if err != nil {
+		return
+	}
+	direction, err := r.readUint8()
+	if err != nil {
+		return
+	}
+	pressure, err := r.readUint8()
+	if err != nil {
+		return
+	}
+	p.Speed = float32(speed) / 4
+	p.Width = float32(width) / 4
+	p.Direction = float32(direction) * 2 * math.Pi / 255
+	p.Pressure = float32(pressure) / 255
+	return
+}
+
+func readGlyphRange(r *v6Reader, id CrdtID) (*GlyphRange, error) {
+	glyph := &GlyphRange{ID: id, Start: -1}
+	if r.hasTag(2, tagByte4) {
+		start, err := r.readInt(2)
+		if err != nil {
+			return nil, err
+		}
+		glyph.Start = int(start)
+	}
+	length, err := r.readInt(3)
+	if err != nil {
+		return nil, err
+	}
+	glyph.Length = int(length)
+	color, err := r.readInt(4)
+	if err != nil {
+		return nil, err
+	}
+	glyph.Color = BrushColor(color)
+	if glyph.Text, err = r.readString(5); err != nil {
+		return nil, err
+	}
+
+	err = r.readSubblock(6, func() error {
+		n, err := r.readVaruint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			var v [4]float64
+			for j := range v {
+				if v[j], err = r.readFloat64(); err != nil {
+					return err
+				}
+			}
+			glyph.Rects = append(glyph.Rects, Rect{v[0], v[1], v[2], v[3]})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if r.hasTag(7, tagByte4) {
+		if glyph.ARGB, err = r.readInt(7); err != nil {
+			return nil, err
+		}
+	}
+	return glyph, nil
+}
+
+func (b *sceneBuilder) readRootText(r *v6Reader) error {
+	if _, err := r.readID(1); err != nil {
+		return err
+	}
+	b.text = &Text{}
+	b.chars = nil
+	b.styles = make(map[CrdtID]ParagraphStyle)
+
+	err := r.readSubblock(2, func() error {
+		// the characters
+		err := r.readSubblock(1, func() error {
+			return r.readSubblock(1, func() error {
+				n, err := r.readVaruint()
+				if err != nil {
+					return err
+				}
+				for i := uint64(0); i < n; i++ {
+					if err := b.readTextItem(r); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		// the styles of the paragraphs
+		return r.readSubblock(2, func() error {
+			return r.readSubblock(1, func() error {
+				n, err := r.readVaruint()
+				if err != nil {
+					return err
+				}
+				for i := uint64(0); i < n; i++ {
+					id, err := r.readCrdtID()
+					if err != nil {
+						return err
+					}
+					if _, err := r.readID(1); err != nil {
+						return err
+					}
+					err = r.readSubblock(2, func() error {
+						// always 17
+						if _, err := r.readUint8(); err != nil {
+							return err
+						}
+						style, err := r.readUint8()
+						b.styles[id] = ParagraphStyle(style)
+						return err
+					})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = r.readSubblock(3, func() (err error) {
+		if b.text.X, err = r.readFloat64(); err != nil {
+			return
+		}
+		b.text.Y, err = r.readFloat64()
+		return
+	})
+	if err != nil {
+		return err
+	}
+	b.text.Width, err = r.readFloat(4)
+	return err
+}
+
+// readTextItem reads an item of the text and adds its characters, the id of the
+// first character is the one of the item and the next ones follow
+func (b *sceneBuilder) readTextItem(r *v6Reader) error {
+	return r.readSubblock(0, func() error {
+		item := sceneItem{}
+		var err error
+		if item.id, err = r.readID(2); err != nil {
+			return err
+		}
+		if item.left, err = r.readID(3); err != nil {
+			return err
+		}
+		if item.right, err = r.readID(4); err != nil {
+			return err
+		}
+		if item.deleted, err = r.readInt(5); err != nil {
+			return err
+		}
+
+		// a deleted item is kept whole, its length doesn't come with any data
+		if item.deleted > 0 {
+			b.chars = append(b.chars, &sceneItem{id: item.id, left: item.left, right: item.right, deleted: 1, run: uint64(item.deleted)})
+			return nil
+		}
+
+		var values []interface{}
+		if r.hasTag(6, tagLength4) {
+			err = r.readSubblock(6, func() error {
+				length, err := r.readVaruint()
+				if err != nil {
+					return err
+				}
+				if _, err := r.readUint8(); err != nil {
+					return err
+				}
+				s, err := r.next(int(length))
+				if err != nil {
+					return err
+				}
+				for _, c := range string(s) {
+					values = append(values, string(c))
+				}
+				if r.pos < r.end {
+					code, err := r.readInt(2)
+					if err != nil {
+						return err
+					}
+					values = []interface{}{code}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		id, left := item.id, item.left
+		for i := range values {
+			right := item.right
+			if i < len(values)-1 {
+				right = CrdtID{id.Part1, id.Part2 + 1}
+			}
+			b.chars = append(b.chars, &sceneItem{id: id, left: left, right: right, value: values[i]})
+			left, id = id, right
+		}
+		return nil
+	})
+}
+
+// fill adds the items of the group g in order, seen guards against cycles
+func (b *sceneBuilder) fill(g *Group, seen map[CrdtID]bool) error {
+	if seen[g.ID] {
+		return fmt.Errorf("group %s is in itself", g.ID)
+	}
+	seen[g.ID] = true
+
+	items, err := sortSequence(b.items[g.ID])
+	if err != nil {
+		return fmt.Errorf("group %s: %w", g.ID, err)
+	}
+	for _, item := range items {
+		if item.deleted > 0 {
+			continue
+		}
+		switch v := item.value.(type) {
+		case CrdtID:
+			child := b.group(v)
+			if err := b.fill(child, seen); err != nil {
+				return err
+			}
+			g.Items = append(g.Items, child)
+		case *SceneLine:
+			g.Items = append(g.Items, v)
+		case *GlyphRange:
+			g.Items = append(g.Items, v)
+		}
+	}
+	return nil
+}
+
+// seqKey is an item of a sequence or one of its ends
+type seqKey struct {
+	id CrdtID
+	// end is -1 for the start of the sequence, 1 for its end
+	end int
+}
+
+// sortSequence orders the items of a CRDT sequence: each item is after its left
+// item and before its right item, the zero id standing for the ends. The items
+// at the same place are ordered by id. An id in a deleted run of characters stands
+// for the run, an item before such an id is only placed after the run.
+func sortSequence(items []*sceneItem) ([]*sceneItem, error) {
+	byID := make(map[seqKey]*sceneItem)
+	var runs []*sceneItem
+	for _, item := range items {
+		byID[seqKey{id: item.id}] = item
+		if item.run > 1 {
+			runs = append(runs, item)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].id.less(runs[j].id) })
+
+	// inRun returns the run containing id
+	inRun := func(id CrdtID) *sceneItem {
+		i := sort.Search(len(runs), func(i int) bool { return id.less(runs[i].id) })
+		if i == 0 {
+			return nil
+		}
+		run := runs[i-1]
+		if run.id.Part1 != id.Part1 || id.Part2-run.id.Part2 >= run.run {
+			return nil
+		}
+		return run
+	}
+
+	// side returns the key of the item id on the side end of another item,
+	// false if the other item isn't ordered by id
+	side := func(id CrdtID, end int) (seqKey, bool) {
+		if id == (CrdtID{}) {
+			return seqKey{end: end}, true
+		}
+		if _, ok := byID[seqKey{id: id}]; ok {
+			return seqKey{id: id}, true
+		}
+		if run := inRun(id); run != nil {
+			return seqKey{id: run.id}, end < 0
+		}
+		return seqKey{id: id}, true
+	}
+
+	// deps are the keys a key comes after
+	deps := make(map[seqKey]map[seqKey]bool)
+	dependents := make(map[seqKey][]seqKey)
+	addDep := func(k, after seqKey) {
+		if deps[k] == nil {
+			deps[k] = make(map[seqKey]bool)
+		}
+		if deps[after] == nil {
+			deps[after] = make(map[seqKey]bool)
+		}
+		if !deps[k][after] {
+			deps[k][after] = true
+			dependents[after] = append(dependents[after], k)
+		}
+	}
+	for _, item := range byID {
+		key := seqKey{id: item.id}
+		if left, ok := side(item.left, -1); ok {
+			addDep(key, left)
+		}
+		if right, ok := side(item.right, 1); ok {
+			addDep(right, key)
+		}
+	}
+
+	count := make(map[seqKey]int)
+	var ready []seqKey
+	for k, d := range deps {
+		count[k] = len(d)
+		if len(d) == 0 {
+			ready = append(ready, k)
+		}
+	}
+
+	var sorted []*sceneItem
+	done := 0
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i].id.less(ready[j].id) })
+		var next []seqKey
+		for _, k := range ready {
+			done++
+			if item, ok := byID[k]; ok {
+				sorted = append(sorted, item)
+			}
+			for _, d := range dependents[k] {
+				count[d]--
+				if count[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		ready = next
+	}
+	if done != len(deps) {
+		return nil, errors.New("cyclic sequence")
+	}
+	return sorted, nil
+}
@@ -32,6 +32,12 @@ func parseOfflineCommands(cmd []string) bool {
 	case "version":
 		fmt.Println(version.Version)
 		return true
+	case "auth":
+		if err := runAuthCommand(cmd[1:]); err != nil {
+			log.Error.Println("Error: ", err)
+			os.Exit(1)
+		}
+		return true
 	}
 	return false
 }
@@ -45,7 +51,8 @@ func main() {
 
 Offline Commands:
   version	prints the version
-  reset		removes the config file `)
+  reset		removes the config file
+  auth		register, status, refresh, logout (see auth help) `)
 
 		flag.PrintDefaults()
 	}
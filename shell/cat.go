@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	flag "github.com/ogier/pflag"
+)
+
+func catCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "cat",
+		Help:      "print the typed text of a notebook, usage: cat [--markdown] <path>",
+		Completer: createFileCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("cat", flag.ContinueOnError)
+			markdown := flagSet.BoolP("markdown", "m", false, "print the text in Markdown")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) != 1 {
+				c.Err(errors.New("usage: cat [--markdown] <path>"))
+				return
+			}
+
+			node, err := ctx.api.Filetree().NodeByPath(args[0], ctx.node)
+			if err != nil || node.IsDirectory() {
+				c.Err(errors.New("file doesn't exist"))
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := ctx.api.FetchDocumentTo(node.Id(), &buf); err != nil {
+				c.Err(fmt.Errorf("failed to download file %s, %w", args[0], err))
+				return
+			}
+			zip := archive.NewZip()
+			if err := zip.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+				c.Err(fmt.Errorf("failed to read file %s, %w", args[0], err))
+				return
+			}
+
+			text := typedText(zip, *markdown)
+			if text == "" {
+				c.Err(fmt.Errorf("%s has no typed text", args[0]))
+				return
+			}
+			c.Print(text)
+		},
+	}
+}
+
+// typedText returns the typed text of the pages of a document separated by an empty line
+func typedText(zip *archive.Zip, markdown bool) string {
+	var pages []string
+	for _, p := range zip.Pages {
+		if p.Data == nil || p.Data.Scene == nil || p.Data.Scene.Text == nil {
+			continue
+		}
+		text := p.Data.Scene.Text
+		if markdown {
+			pages = append(pages, strings.TrimSuffix(text.Markdown(), "\n"))
+		} else {
+			pages = append(pages, text.String())
+		}
+	}
+	if len(pages) == 0 {
+		return ""
+	}
+	return strings.Join(pages, "\n\n") + "\n"
+}
@@ -0,0 +1,35 @@
+package shell
+
+import (
+	"os"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCat(t *testing.T) {
+	minutes, err := os.ReadFile("minutes.rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := apitest.NewServer()
+	uploadNotebookPages(t, srv, "minutes",
+		[]string{"2b0e9a55-5d0c-4f0e-9b8e-3d6c1f0a7e21", "9c1d4e6f-0a2b-4c3d-8e5f-6a7b8c9d0e1f"},
+		[]string{string(minutes), string(minutes)})
+	uploadNotebook(t, srv, "drawings", 1)
+	shell, out := newTestShellFor(t, srv)
+
+	out.Reset()
+	assert.NoError(t, shell.Process("cat", "minutes"))
+	assert.Equal(t, "Minutes\nAgreed budget\nship it\nreview\n\nMinutes\nAgreed budget\nship it\nreview\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("cat", "-m", "minutes"))
+	assert.Equal(t, "# Minutes\n\nAgreed **budget**\n\n- ship it\n- review\n\n"+
+		"# Minutes\n\nAgreed **budget**\n\n- ship it\n- review\n", out.String())
+
+	assert.Error(t, shell.Process("cat", "drawings"))
+	assert.Error(t, shell.Process("cat", "missing"))
+}
@@ -0,0 +1,89 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/abiosoft/ishell"
+	flag "github.com/ogier/pflag"
+)
+
+func cpCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "cp",
+		Help:      "copy file or directory (-r for directories with entries)",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("cp", flag.ContinueOnError)
+			recursive := flagSet.BoolP("recursive", "r", false, "copy directories recursively")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) < 2 {
+				c.Err(errors.New("missing source and/or destination"))
+				return
+			}
+
+			src := args[0]
+			dst := args[1]
+
+			srcNodes, err := ctx.api.Filetree().NodesByPath(src, ctx.node, false)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			if len(srcNodes) < 1 {
+				c.Err(errors.New("no nodes found"))
+				return
+			}
+
+			dstNode, _ := ctx.api.Filetree().NodeByPath(dst, ctx.node)
+			if dstNode != nil && dstNode.IsFile() {
+				c.Err(errors.New("destination entry already exists"))
+				return
+			}
+
+			if dstNode != nil && dstNode.IsDirectory() {
+				// We are copying the nodes into another directory
+				for _, node := range srcNodes {
+					if isSubdir(node, dstNode) {
+						c.Err(fmt.Errorf("cannot copy: %s in itself", node.Name()))
+						return
+					}
+				}
+				_, err = ctx.api.CopyEntries(srcNodes, dstNode, *recursive)
+			} else {
+				// We are copying the node under a new name
+				if len(srcNodes) > 1 {
+					c.Err(errors.New("cannot copy multiple nodes to a new name"))
+					return
+				}
+
+				parentNode, perr := ctx.api.Filetree().NodeByPath(path.Dir(dst), ctx.node)
+				if perr != nil || parentNode.IsFile() {
+					c.Err(errors.New("directory doesn't exist"))
+					return
+				}
+				if isSubdir(srcNodes[0], parentNode) {
+					c.Err(fmt.Errorf("cannot copy: %s in itself", srcNodes[0].Name()))
+					return
+				}
+				_, err = ctx.api.CopyEntry(srcNodes[0], parentNode, path.Base(dst), *recursive)
+			}
+			if err != nil {
+				c.Err(fmt.Errorf("failed to copy entry, %w", err))
+				return
+			}
+
+			err = ctx.api.SyncComplete()
+			if err != nil {
+				c.Err(fmt.Errorf("cannot notify, %w", err))
+			}
+		},
+	}
+}
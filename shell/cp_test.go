@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCpCommand(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadTestDocs(t, srv, "paper")
+	shell, out := newTestShellFor(t, srv)
+
+	assert.NoError(t, shell.Process("tag", "add", "paper", "ml"))
+	assert.NoError(t, shell.Process("mkdir", "books"))
+	assert.NoError(t, shell.Process("mkdir", "books/novels"))
+	assert.NoError(t, shell.Process("mv", "paper", "books/novels"))
+
+	writes := srv.BlobWrites()
+	assert.NoError(t, shell.Process("cp", "books/novels/paper", "copy"))
+	// metadata, document index and root index
+	assert.Equal(t, 3, srv.BlobWrites()-writes)
+
+	out.Reset()
+	assert.NoError(t, shell.Process("tag", "list", "copy"))
+	assert.Equal(t, "ml\n", out.String())
+
+	assert.Error(t, shell.Process("cp", "books", "library"))
+	assert.Error(t, shell.Process("cp", "-r", "books", "books/novels"))
+
+	generation := srv.Generation()
+	assert.NoError(t, shell.Process("cp", "-r", "books", "library"))
+	// a single commit for the whole directory
+	assert.Equal(t, generation+1, srv.Generation())
+
+	// a new session sees the copies with new ids
+	other, out := newTestShellFor(t, srv)
+	assert.NoError(t, other.Process("ls"))
+	assert.Equal(t, "[d]\tbooks\n[f]\tcopy\n[d]\tlibrary\n[d]\ttrash\n", out.String())
+	out.Reset()
+	assert.NoError(t, other.Process("ls", "library/novels"))
+	assert.Equal(t, "[f]\tpaper\n", out.String())
+
+	source, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	original, err := source.Filetree().NodeByPath("/books/novels/paper", nil)
+	assert.NoError(t, err)
+	copied, err := source.Filetree().NodeByPath("/library/novels/paper", nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, original.Id(), copied.Id())
+
+	var buf bytes.Buffer
+	assert.NoError(t, source.FetchDocumentTo(copied.Id(), &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	for _, f := range zr.File {
+		assert.True(t, strings.HasPrefix(f.Name, copied.Id()), f.Name)
+	}
+}
@@ -8,18 +8,19 @@ import (
 
 	"github.com/abiosoft/ishell"
 	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/log"
 	"github.com/juruen/rmapi/model"
 	flag "github.com/ogier/pflag"
 )
 
-// tagSlice implements flag.Value to collect multiple --tag flags
-type tagSlice []string
+// stringSlice implements flag.Value to collect repeated flags, e.g. --tag
+type stringSlice []string
 
-func (t *tagSlice) String() string {
+func (t *stringSlice) String() string {
 	return strings.Join(*t, ",")
 }
 
-func (t *tagSlice) Set(value string) error {
+func (t *stringSlice) Set(value string) error {
 	*t = append(*t, value)
 	return nil
 }
@@ -32,7 +33,7 @@ func findCmd(ctx *ShellCtxt) *ishell.Cmd {
 		Func: func(c *ishell.Context) {
 			flagSet := flag.NewFlagSet("find", flag.ContinueOnError)
 			var compact bool
-			var tags tagSlice
+			var tags stringSlice
 			var starred bool
 			flagSet.BoolVarP(&compact, "compact", "c", false, "compact format")
 			flagSet.Var(&tags, "tag", "filter by tag (can be specified multiple times, matches files with ANY of the tags)")
@@ -96,7 +97,7 @@ func findCmd(ctx *ShellCtxt) *ishell.Cmd {
 
 					// Filter by tags if specified - using OR semantics
 					if len(tags) > 0 && node.Document != nil {
-						nodeTags := node.Document.Tags
+						nodeTags := documentTags(ctx, node)
 						hasMatch := false
 						for _, requiredTag := range tags {
 							for _, nodeTag := range nodeTags {
@@ -158,3 +159,22 @@ func formatEntry(compact bool, path []string, node *model.Node) string {
 	}
 	return entryType + fullpath
 }
+
+// documentTags returns the tags of node, they are loaded on demand after a lazy mirror
+func documentTags(ctx *ShellCtxt, node *model.Node) []string {
+	if node.IsRoot() || node.Id() == filetree.TrashID {
+		return node.Document.Tags
+	}
+
+	content, err := ctx.api.DocumentContent(node.Id())
+	if err != nil {
+		log.Warning.Printf("cannot get the tags of %s: %v", node.Name(), err)
+		return node.Document.Tags
+	}
+
+	tags := []string{}
+	for _, tag := range content.DocumentTags {
+		tags = append(tags, tag.Name)
+	}
+	return tags
+}
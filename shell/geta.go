@@ -4,6 +4,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 
 	"github.com/abiosoft/ishell"
 	"github.com/juruen/rmapi/annotations"
@@ -12,7 +13,7 @@ import (
 func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 	return &ishell.Cmd{
 		Name:      "geta",
-		Help:      "copy remote file to local and generate a PDF with its annotations",
+		Help:      "copy remote file to local and generate a PDF or images with its annotations",
 		Completer: createEntryCompleter(ctx),
 		Func: func(c *ishell.Context) {
 
@@ -20,6 +21,10 @@ func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 			addPageNumbers := flagSet.Bool("p", false, "add page numbers")
 			allPages := flagSet.Bool("a", false, "all pages")
 			annotationsOnly := flagSet.Bool("n", false, "annotations only")
+			format := flagSet.String("format", "pdf", "output format: pdf, svg or png (a file per page)")
+			pageRanges := flagSet.String("pages", "", "pages to render as images, e.g. 1-3,5")
+			dpi := flagSet.Float64("dpi", annotations.DeviceDPI, "resolution of the png images")
+			native := flagSet.Bool("native", false, "add the annotations as PDF annotations instead of drawing them")
 			if err := flagSet.Parse(c.Args); err != nil {
 				if err != flag.ErrHelp {
 					c.Err(err)
@@ -41,6 +46,23 @@ func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 				return
 			}
 
+			if *native && *format != "pdf" {
+				c.Err(errors.New("--native needs --format pdf"))
+				return
+			}
+
+			var pages []int
+			if *pageRanges != "" {
+				if *format == "pdf" {
+					c.Err(errors.New("--pages needs --format svg or png"))
+					return
+				}
+				if pages, err = getaPages(ctx, node.Document.ID, *pageRanges); err != nil {
+					c.Err(err)
+					return
+				}
+			}
+
 			c.Println(fmt.Sprintf("downloading: [%s]...", srcName))
 
 			zipName := fmt.Sprintf("%s.zip", node.Name())
@@ -51,8 +73,21 @@ func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 				return
 			}
 
+			if *format != "pdf" {
+				options := annotations.ImageGeneratorOptions{AllPages: *allPages, AnnotationsOnly: *annotationsOnly, Pages: pages, DPI: *dpi}
+				files, err := annotations.CreateImageGenerator(zipName, node.Name(), *format, options).Generate()
+				if err != nil {
+					c.Err(fmt.Errorf("Failed to generate annotations for %s with %w", srcName, err))
+					return
+				}
+				for _, name := range files {
+					c.Printf("Annotations generated in: %s\n", name)
+				}
+				return
+			}
+
 			pdfName := fmt.Sprintf("%s-annotations.pdf", node.Name())
-			options := annotations.PdfGeneratorOptions{AddPageNumbers: *addPageNumbers, AllPages: *allPages, AnnotationsOnly: *annotationsOnly}
+			options := annotations.PdfGeneratorOptions{AddPageNumbers: *addPageNumbers, AllPages: *allPages, AnnotationsOnly: *annotationsOnly, NativeAnnotations: *native}
 			generator := annotations.CreatePdfGenerator(zipName, pdfName, options)
 			err = generator.Generate()
 
@@ -65,3 +100,22 @@ func getACmd(ctx *ShellCtxt) *ishell.Cmd {
 		},
 	}
 }
+
+// getaPages returns the numbers of the pages in the comma separated ranges
+func getaPages(ctx *ShellCtxt, docId, ranges string) ([]int, error) {
+	info, err := ctx.api.DocumentPages(docId)
+	if err != nil {
+		return nil, err
+	}
+	var pages []int
+	for _, r := range strings.Split(ranges, ",") {
+		from, to, err := parsePageRange(r, len(info))
+		if err != nil {
+			return nil, err
+		}
+		for n := from; n <= to; n++ {
+			pages = append(pages, n)
+		}
+	}
+	return pages, nil
+}
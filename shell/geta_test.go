@@ -0,0 +1,99 @@
+package shell
+
+import (
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/juruen/rmapi/encoding/rm"
+	"github.com/stretchr/testify/assert"
+)
+
+// inTempDir runs the test in a temporary directory for the commands writing local files
+func inTempDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestGetaSVG(t *testing.T) {
+	page := &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Lines: []rm.Line{{
+		BrushType: rm.FinelinerV5,
+		BrushSize: rm.Medium,
+		Points:    []rm.Point{{X: 100, Y: 100, Width: 2}, {X: 200, Y: 200, Width: 2}},
+	}}}}}
+	drawing, err := page.MarshalBinary()
+	assert.NoError(t, err)
+
+	srv := apitest.NewServer()
+	uploadNotebookPages(t, srv, "sketch", []string{"2b0e9a55-5d0c-4f0e-9b8e-3d6c1f0a7e21", "9c1d4e6f-0a2b-4c3d-8e5f-6a7b8c9d0e1f"}, []string{string(drawing), ""})
+	shell, out := newTestShellFor(t, srv)
+	inTempDir(t)
+
+	assert.NoError(t, shell.Process("geta", "--format", "svg", "sketch"))
+	assert.Contains(t, out.String(), "Annotations generated in: sketch-1.svg\n")
+	svg, err := os.ReadFile("sketch-1.svg")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(svg), "<polyline"))
+	// the second page has no drawing
+	_, err = os.Stat("sketch-2.svg")
+	assert.True(t, os.IsNotExist(err))
+
+	out.Reset()
+	assert.NoError(t, shell.Process("geta", "-a", "--format", "svg", "sketch"))
+	assert.Contains(t, out.String(), "sketch-2.svg")
+
+	assert.Error(t, shell.Process("geta", "--format", "bmp", "sketch"))
+}
+
+func TestGetaPNG(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadNotebookPages(t, srv, "sketch", []string{"2b0e9a55-5d0c-4f0e-9b8e-3d6c1f0a7e21", "9c1d4e6f-0a2b-4c3d-8e5f-6a7b8c9d0e1f"}, []string{"", ""})
+	shell, out := newTestShellFor(t, srv)
+	inTempDir(t)
+
+	assert.NoError(t, shell.Process("geta", "--format", "png", "--dpi", "113", "--pages", "2", "sketch"))
+	assert.Contains(t, out.String(), "Annotations generated in: sketch-2.png\n")
+	assert.NotContains(t, out.String(), "sketch-1.png")
+	f, err := os.Open("sketch-2.png")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	config, err := png.DecodeConfig(f)
+	assert.NoError(t, err)
+	assert.Equal(t, rm.Width/2, config.Width)
+
+	assert.Error(t, shell.Process("geta", "--format", "png", "--pages", "3", "sketch"))
+	assert.Error(t, shell.Process("geta", "--pages", "1", "sketch"))
+}
+
+func TestGetaNative(t *testing.T) {
+	page := &rm.Rm{Version: rm.V5, Layers: []rm.Layer{{Lines: []rm.Line{{
+		BrushType: rm.BallPointV5,
+		BrushSize: rm.Medium,
+		Points:    []rm.Point{{X: 100, Y: 100, Width: 2, Pressure: 1}, {X: 200, Y: 200, Width: 2, Pressure: 1}},
+	}}}}}
+	drawing, err := page.MarshalBinary()
+	assert.NoError(t, err)
+
+	srv := apitest.NewServer()
+	uploadNotebookPages(t, srv, "sketch", []string{"2b0e9a55-5d0c-4f0e-9b8e-3d6c1f0a7e21"}, []string{string(drawing)})
+	shell, out := newTestShellFor(t, srv)
+	inTempDir(t)
+
+	assert.NoError(t, shell.Process("geta", "--native", "sketch"))
+	assert.Contains(t, out.String(), "Annotations generated in: sketch-annotations.pdf\n")
+	data, err := os.ReadFile("sketch-annotations.pdf")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "/Ink")
+
+	assert.Error(t, shell.Process("geta", "--native", "--format", "svg", "sketch"))
+}
@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	flag "github.com/ogier/pflag"
+)
+
+const highlightsUsage = "usage: highlights [--format=markdown|json|csv] [-o <file>] <path>"
+
+func highlightsCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "highlights",
+		Help:      "export the highlights of a PDF or EPUB, " + highlightsUsage,
+		Completer: createFileCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("highlights", flag.ContinueOnError)
+			format := flagSet.StringP("format", "f", "markdown", "markdown, json or csv")
+			output := flagSet.StringP("output", "o", "", "write to a file instead of the output")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) != 1 {
+				c.Err(errors.New(highlightsUsage))
+				return
+			}
+
+			node, err := ctx.api.Filetree().NodeByPath(args[0], ctx.node)
+			if err != nil || node.IsDirectory() {
+				c.Err(errors.New("file doesn't exist"))
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := ctx.api.FetchDocumentTo(node.Id(), &buf); err != nil {
+				c.Err(fmt.Errorf("failed to download file %s, %w", args[0], err))
+				return
+			}
+			zip := archive.NewZip()
+			if err := zip.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+				c.Err(fmt.Errorf("failed to read file %s, %w", args[0], err))
+				return
+			}
+
+			highlights := zip.Highlights()
+			if len(highlights) == 0 {
+				c.Err(fmt.Errorf("%s has no highlights", args[0]))
+				return
+			}
+
+			var out string
+			switch *format {
+			case "markdown", "md":
+				out = highlightsMarkdown(node.Name(), highlights)
+			case "json":
+				out, err = highlightsJSON(highlights)
+			case "csv":
+				out, err = highlightsCSV(highlights)
+			default:
+				err = fmt.Errorf("unknown format %s", *format)
+			}
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			if *output == "" {
+				c.Print(out)
+				return
+			}
+			if err := os.WriteFile(*output, []byte(out), 0644); err != nil {
+				c.Err(err)
+				return
+			}
+			c.Printf("%d highlights written to %s\n", len(highlights), *output)
+		},
+	}
+}
+
+// highlightsMarkdown returns the highlights as quotes under a heading per page
+func highlightsMarkdown(name string, highlights []archive.Highlight) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n", name)
+	page := -1
+	for _, h := range highlights {
+		if h.Page != page {
+			page = h.Page
+			if page > 0 {
+				fmt.Fprintf(&sb, "\n## Page %d\n", page)
+			} else {
+				sb.WriteString("\n## Added pages\n")
+			}
+		}
+		sb.WriteString("\n> " + strings.Join(strings.Fields(h.Text), " ") + "\n")
+	}
+	return sb.String()
+}
+
+type highlightRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type highlightRecord struct {
+	Page   int             `json:"page"`
+	Text   string          `json:"text"`
+	Color  string          `json:"color"`
+	Start  int             `json:"start"`
+	Length int             `json:"length"`
+	Rects  []highlightRect `json:"rects"`
+}
+
+func highlightsJSON(highlights []archive.Highlight) (string, error) {
+	records := make([]highlightRecord, len(highlights))
+	for i, h := range highlights {
+		records[i] = highlightRecord{
+			Page:   h.Page,
+			Text:   h.Text,
+			Color:  h.Color.String(),
+			Start:  h.Start,
+			Length: h.Length,
+			Rects:  []highlightRect{},
+		}
+		for _, r := range h.Rects {
+			records[i].Rects = append(records[i].Rects, highlightRect{r.X, r.Y, r.W, r.H})
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+func highlightsCSV(highlights []archive.Highlight) (string, error) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"page", "color", "start", "length", "text"})
+	for _, h := range highlights {
+		w.Write([]string{strconv.Itoa(h.Page), h.Color.String(), strconv.Itoa(h.Start), strconv.Itoa(h.Length), h.Text})
+	}
+	w.Flush()
+	return sb.String(), w.Error()
+}
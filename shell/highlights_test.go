@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/juruen/rmapi/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadHighlightedPDF uploads a PDF with highlights on its second page, after an inserted page
+func uploadHighlightedPDF(t *testing.T, srv *apitest.Server, name string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name, data string) {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(data))
+	}
+	id := "0c9f3c4e-3b5e-4a6e-9d5b-6a1f2e3d4c5b"
+	blank, page1, page2 := "5a3e6c0d-8f1b-4f43-9c4e-2b7d9f0e1a2c", "e4b1d2c3-7a6f-4e5d-8c9b-0a1b2c3d4e5f", "9c1d4e6f-0a2b-4c3d-8e5f-6a7b8c9d0e1f"
+	add(id+".content", `{"fileType":"pdf","pages":["`+blank+`","`+page1+`","`+page2+`"],"redirectionPageMap":[-1,0,1]}`)
+	add(id+".pdf", "%PDF-1.4")
+	add(id+".highlights/"+page2+".json", `{"highlights":[[
+		{"text":"a \"quoted\",\nclaim","start":40,"length":17,"color":4,"rects":[{"x":1,"y":2,"width":3,"height":4}]},
+		{"text":"first","start":10,"length":5,"color":3,"rects":[]}
+	]]}`)
+	assert.NoError(t, zw.Close())
+
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	_, err = apiCtx.UploadDocumentFrom("", name, util.ZIP, bytes.NewReader(buf.Bytes()), false)
+	assert.NoError(t, err)
+}
+
+func TestHighlights(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadHighlightedPDF(t, srv, "paper")
+	uploadTestDocs(t, srv, "plain")
+	shell, out := newTestShellFor(t, srv)
+
+	out.Reset()
+	assert.NoError(t, shell.Process("highlights", "paper"))
+	assert.Equal(t, "# paper\n\n## Page 2\n\n> first\n\n> a \"quoted\", claim\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("highlights", "--format=csv", "paper"))
+	assert.Equal(t, "page,color,start,length,text\n2,yellow,10,5,first\n2,green,40,17,\"a \"\"quoted\"\",\nclaim\"\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("highlights", "-f", "json", "paper"))
+	assert.JSONEq(t, `[
+		{"page":2,"text":"first","color":"yellow","start":10,"length":5,"rects":[]},
+		{"page":2,"text":"a \"quoted\",\nclaim","color":"green","start":40,"length":17,"rects":[{"x":1,"y":2,"width":3,"height":4}]}
+	]`, out.String())
+
+	inTempDir(t)
+	out.Reset()
+	assert.NoError(t, shell.Process("highlights", "-o", "quotes.md", "paper"))
+	assert.Equal(t, "2 highlights written to quotes.md\n", out.String())
+	data, err := os.ReadFile("quotes.md")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "> first\n")
+
+	assert.Error(t, shell.Process("highlights", "plain"))
+	assert.Error(t, shell.Process("highlights", "--format=xml", "paper"))
+	assert.Error(t, shell.Process("highlights", "missing"))
+}
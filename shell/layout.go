@@ -0,0 +1,180 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
+	flag "github.com/ogier/pflag"
+)
+
+const layoutUsage = `  --landscape          Use the landscape orientation
+  --portrait           Use the portrait orientation
+  --font=<name>        Set the font of EPUBs, e.g. "EB Garamond"
+  --margins=<n>        Set the margins, e.g. 50, 125 or 200
+  --text-scale=<f>     Set the text scale, e.g. 0.8 or 1.5
+  --line-height=<n>    Set the line height, e.g. 100, 150 or 200, -1 for the default`
+
+// layoutFlags are the flags changing the reading settings of documents
+type layoutFlags struct {
+	landscape  *bool
+	portrait   *bool
+	font       *string
+	margins    *int
+	textScale  *float64
+	lineHeight *int
+	flagSet    *flag.FlagSet
+}
+
+func addLayoutFlags(flagSet *flag.FlagSet) *layoutFlags {
+	return &layoutFlags{
+		landscape:  flagSet.Bool("landscape", false, "use the landscape orientation"),
+		portrait:   flagSet.Bool("portrait", false, "use the portrait orientation"),
+		font:       flagSet.String("font", "", "font name"),
+		margins:    flagSet.Int("margins", 0, "margins"),
+		textScale:  flagSet.Float64("text-scale", 0, "text scale"),
+		lineHeight: flagSet.Int("line-height", 0, "line height"),
+		flagSet:    flagSet,
+	}
+}
+
+// layout returns the settings given on the command line, call it after parsing the flags
+func (f *layoutFlags) layout() (*archive.Layout, error) {
+	l := &archive.Layout{}
+	var err error
+	f.flagSet.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "landscape", "portrait":
+			if *f.landscape && *f.portrait {
+				err = errors.New("--landscape and --portrait cannot be used together")
+			}
+			if fl.Value.String() == "true" {
+				orientation := fl.Name
+				l.Orientation = &orientation
+			}
+		case "font":
+			if *f.font == "" {
+				err = errors.New("--font can't be empty")
+			}
+			l.FontName = f.font
+		case "margins":
+			if *f.margins <= 0 {
+				err = errors.New("--margins must be positive")
+			}
+			l.Margins = f.margins
+		case "text-scale":
+			if *f.textScale <= 0 {
+				err = errors.New("--text-scale must be positive")
+			}
+			l.TextScale = f.textScale
+		case "line-height":
+			if *f.lineHeight <= 0 && *f.lineHeight != -1 {
+				err = errors.New("--line-height must be positive or -1")
+			}
+			l.LineHeight = f.lineHeight
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// setLayout applies layout to the .content of the documents ids with a single commit
+func setLayout(ctx *ShellCtxt, ids []string, layout *archive.Layout) ([]*model.Document, error) {
+	return ctx.api.UpdateContent(ids, func(_ string, content *archive.Content) error {
+		layout.Apply(content)
+		return nil
+	})
+}
+
+func setContentCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "setcontent",
+		Help:      "change the layout settings of documents",
+		Completer: createEntryCompleter(ctx),
+		LongHelp: `Usage: setcontent [options] <path>...
+
+Paths can be globs, the documents of directories are changed recursively.
+
+Options:
+` + layoutUsage + `
+  --coverpage=<0|1>    Use the first page (1) or the last opened page (0) as cover`,
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("setcontent", flag.ContinueOnError)
+			layoutFlags := addLayoutFlags(flagSet)
+			coverpage := flagSet.String("coverpage", "", "use the first page (1) or the last opened page (0) as cover")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+
+			layout, err := layoutFlags.layout()
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			switch *coverpage {
+			case "":
+			case "0":
+				page := -1
+				layout.CoverPageNumber = &page
+			case "1":
+				page := 0
+				layout.CoverPageNumber = &page
+			default:
+				c.Err(errors.New("--coverpage value must be 0 or 1"))
+				return
+			}
+			if layout.IsEmpty() {
+				c.Err(errors.New("nothing to change, see help setcontent"))
+				return
+			}
+
+			nodes, err := entryNodes(ctx, flagSet.Args())
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			var ids []string
+			for _, n := range withDescendants(nodes) {
+				ids = append(ids, n.Id())
+			}
+			docs, err := setLayout(ctx, ids, layout)
+			if err != nil {
+				c.Err(fmt.Errorf("failed to change the content, %w", err))
+				return
+			}
+			c.Printf("changed %d documents\n", len(docs))
+
+			err = ctx.api.SyncComplete()
+			if err != nil {
+				c.Err(fmt.Errorf("cannot notify, %w", err))
+			}
+		},
+	}
+}
+
+// withDescendants returns the documents of nodes, the documents in directories included
+func withDescendants(nodes []*model.Node) []*model.Node {
+	var docs []*model.Node
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		filetree.WalkTree(node, filetree.FileTreeVistor{
+			Visit: func(n *model.Node, path []string) bool {
+				if n.IsFile() && !seen[n.Id()] {
+					seen[n.Id()] = true
+					docs = append(docs, n)
+				}
+				return filetree.ContinueVisiting
+			},
+		})
+	}
+	return docs
+}
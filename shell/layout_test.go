@@ -0,0 +1,88 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetContentCommand(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadTestDocs(t, srv, "slides", "book")
+	shell, out := newTestShellFor(t, srv)
+
+	assert.NoError(t, shell.Process("mkdir", "epubs"))
+	assert.NoError(t, shell.Process("mv", "book", "epubs"))
+
+	generation := srv.Generation()
+	out.Reset()
+	assert.NoError(t, shell.Process("setcontent", "--font=EB Garamond", "--margins=50", "--text-scale=1.5", "epubs"))
+	assert.Equal(t, "changed 1 documents\n", out.String())
+	assert.NoError(t, shell.Process("setcontent", "--landscape", "--coverpage=1", "slides"))
+	assert.Equal(t, generation+2, srv.Generation())
+
+	// unchanged documents are not committed
+	out.Reset()
+	assert.NoError(t, shell.Process("setcontent", "--landscape", "slides"))
+	assert.Equal(t, "changed 0 documents\n", out.String())
+	assert.Equal(t, generation+2, srv.Generation())
+
+	assert.Error(t, shell.Process("setcontent", "slides"))
+	assert.Error(t, shell.Process("setcontent", "--landscape", "--portrait", "slides"))
+	assert.Error(t, shell.Process("setcontent", "--margins=-5", "slides"))
+
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	book, err := apiCtx.Filetree().NodeByPath("/epubs/book", nil)
+	assert.NoError(t, err)
+	content, err := apiCtx.DocumentContent(book.Id())
+	assert.NoError(t, err)
+	assert.Equal(t, "EB Garamond", content.FontName)
+	assert.Equal(t, 50, content.Margins)
+	assert.Equal(t, 1.5, content.TextScale)
+	assert.NotEqual(t, "landscape", content.Orientation)
+
+	slides, err := apiCtx.Filetree().NodeByPath("/slides", nil)
+	assert.NoError(t, err)
+	content, err = apiCtx.DocumentContent(slides.Id())
+	assert.NoError(t, err)
+	assert.Equal(t, "landscape", content.Orientation)
+	if assert.NotNil(t, content.CoverPageNumber) {
+		assert.Equal(t, 0, *content.CoverPageNumber)
+	}
+}
+
+func TestPutLayout(t *testing.T) {
+	shell, _, srv := newTestShell(t)
+
+	// the layout is part of the upload
+	generation := srv.Generation()
+	assert.NoError(t, shell.Process("put", "--landscape", "--line-height=150", "--coverpage=1", "../archive/zipdoc_test.pdf"))
+	assert.Equal(t, generation+1, srv.Generation())
+
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	doc, err := apiCtx.Filetree().NodeByPath("/zipdoc_test", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	content, err := apiCtx.DocumentContent(doc.Id())
+	assert.NoError(t, err)
+	assert.Equal(t, "landscape", content.Orientation)
+	assert.Equal(t, 150, content.LineHeight)
+	if assert.NotNil(t, content.CoverPageNumber) {
+		assert.Equal(t, 0, *content.CoverPageNumber)
+	}
+
+	// and of the replacement of the PDF
+	generation = srv.Generation()
+	assert.NoError(t, shell.Process("put", "--content-only", "--portrait", "../archive/zipdoc_test.pdf"))
+	assert.Equal(t, generation+1, srv.Generation())
+	apiCtx, err = srv.NewApiCtx()
+	assert.NoError(t, err)
+	content, err = apiCtx.DocumentContent(doc.Id())
+	assert.NoError(t, err)
+	assert.Equal(t, "portrait", content.Orientation)
+	assert.Equal(t, 150, content.LineHeight)
+}
@@ -0,0 +1,149 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	flag "github.com/ogier/pflag"
+)
+
+func mergeCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "merge",
+		Help:      "merge documents into a new one, usage: merge <path>... -o <new path>",
+		Completer: createFileCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("merge", flag.ContinueOnError)
+			output := flagSet.StringP("output", "o", "", "path of the new document")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) < 2 || *output == "" {
+				c.Err(errors.New("usage: merge <path>... -o <new path>"))
+				return
+			}
+
+			var ids []string
+			for _, p := range args {
+				node, err := ctx.api.Filetree().NodeByPath(p, ctx.node)
+				if err != nil {
+					c.Err(err)
+					return
+				}
+				if !node.IsFile() {
+					c.Err(fmt.Errorf("%s is a directory", p))
+					return
+				}
+				ids = append(ids, node.Id())
+			}
+
+			if _, err := ctx.api.Filetree().NodeByPath(*output, ctx.node); err == nil {
+				c.Err(errors.New("destination entry already exists"))
+				return
+			}
+			parentNode, err := ctx.api.Filetree().NodeByPath(path.Dir(*output), ctx.node)
+			if err != nil || parentNode.IsFile() {
+				c.Err(errors.New("directory doesn't exist"))
+				return
+			}
+
+			_, err = ctx.api.MergeDocuments(ids, parentNode.Id(), path.Base(*output))
+			if err != nil {
+				c.Err(fmt.Errorf("failed to merge, %w", err))
+				return
+			}
+
+			err = ctx.api.SyncComplete()
+			if err != nil {
+				c.Err(fmt.Errorf("cannot notify, %w", err))
+			}
+		},
+	}
+}
+
+func splitCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "split",
+		Help:      "split a document into new ones, usage: split <path> --at <page>...",
+		Completer: createFileCompleter(ctx),
+		LongHelp: `Usage: split <path> --at=<page>...
+
+The parts start at the first page and at each page given with --at, which can
+be repeated or take a list, e.g. --at=5,10. They are named "<name> (part n)"
+and created next to the document, which is kept.`,
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("split", flag.ContinueOnError)
+			var at stringSlice
+			flagSet.Var(&at, "at", "first page of a part, can be repeated")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) != 1 || len(at) == 0 {
+				c.Err(errors.New("usage: split <path> --at <page>..."))
+				return
+			}
+
+			node, err := ctx.api.Filetree().NodeByPath(args[0], ctx.node)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			if !node.IsFile() {
+				c.Err(fmt.Errorf("%s is a directory", args[0]))
+				return
+			}
+
+			pages, err := splitPages(at)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			names := make([]string, len(pages)+1)
+			for i := range names {
+				names[i] = fmt.Sprintf("%s (part %d)", node.Name(), i+1)
+			}
+
+			docs, err := ctx.api.SplitDocument(node.Id(), pages, names)
+			if err != nil {
+				c.Err(fmt.Errorf("failed to split, %w", err))
+				return
+			}
+			c.Printf("created %d documents\n", len(docs))
+
+			err = ctx.api.SyncComplete()
+			if err != nil {
+				c.Err(fmt.Errorf("cannot notify, %w", err))
+			}
+		},
+	}
+}
+
+// splitPages parses the pages of the --at flags, each can be a comma separated list
+func splitPages(at []string) ([]int, error) {
+	var pages []int
+	for _, a := range at {
+		for _, s := range strings.Split(a, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n < 2 {
+				return nil, fmt.Errorf("invalid page %s, parts start after the first page", s)
+			}
+			if len(pages) > 0 && n <= pages[len(pages)-1] {
+				return nil, errors.New("the pages must be in increasing order")
+			}
+			pages = append(pages, n)
+		}
+	}
+	return pages, nil
+}
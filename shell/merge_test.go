@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeAndSplit(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadNotebook(t, srv, "monday", 2)
+	uploadNotebook(t, srv, "tuesday", 3)
+	uploadTestDocs(t, srv, "paper", "book")
+	shell, out := newTestShellFor(t, srv)
+
+	generation := srv.Generation()
+	assert.NoError(t, shell.Process("merge", "monday", "tuesday", "-o", "week"))
+	assert.Equal(t, generation+1, srv.Generation())
+	assert.Equal(t, []string{"1: drawing p1", "2: drawing p2", "3: drawing p1", "4: drawing p2", "5: drawing p3"},
+		notebookFiles(t, srv, "/week"))
+	assert.Equal(t, []string{"1: drawing p1", "2: drawing p2"}, notebookFiles(t, srv, "/monday"))
+
+	// the shell sees the new documents without a refresh
+	out.Reset()
+	assert.NoError(t, shell.Process("ls"))
+	assert.Equal(t, "[f]\tbook\n[f]\tmonday\n[f]\tpaper\n[d]\ttrash\n[f]\ttuesday\n[f]\tweek\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("split", "week", "--at=2,4"))
+	assert.Equal(t, "created 3 documents\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("ls", "week*"))
+	assert.Equal(t, "[f]\tweek\n[f]\tweek (part 1)\n[f]\tweek (part 2)\n[f]\tweek (part 3)\n", out.String())
+	assert.Equal(t, []string{"1: drawing p1"}, notebookFiles(t, srv, "/week (part 1)"))
+	assert.Equal(t, []string{"1: drawing p2", "2: drawing p1"}, notebookFiles(t, srv, "/week (part 2)"))
+	assert.Equal(t, []string{"1: drawing p2", "2: drawing p3"}, notebookFiles(t, srv, "/week (part 3)"))
+
+	// a single PDF keeps its payload
+	assert.NoError(t, shell.Process("merge", "paper", "monday", "-o", "annotated paper"))
+	assert.Equal(t, []string{"1: drawing p1", "2: drawing p2"}, notebookFiles(t, srv, "/annotated paper"))
+
+	generation = srv.Generation()
+	assert.Error(t, shell.Process("merge", "paper", "book", "-o", "both"))
+	assert.Error(t, shell.Process("merge", "monday", "tuesday", "-o", "week"))
+	assert.Error(t, shell.Process("split", "week", "--at=6"))
+	assert.Error(t, shell.Process("split", "week", "--at=3", "--at=2"))
+	assert.Equal(t, generation, srv.Generation())
+}
+
+func TestMergeAndSplitCPages(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadCPagesNotebook(t, srv, "monday", 2)
+	uploadNotebook(t, srv, "tuesday", 2)
+	shell, _ := newTestShellFor(t, srv)
+
+	assert.NoError(t, shell.Process("merge", "monday", "tuesday", "-o", "week"))
+	assert.Equal(t, []string{"1: drawing p1", "2: drawing p2", "3: drawing p1", "4: drawing p2"},
+		notebookFiles(t, srv, "/week"))
+
+	assert.NoError(t, shell.Process("split", "week", "--at=2"))
+	assert.Equal(t, []string{"1: drawing p1"}, notebookFiles(t, srv, "/week (part 1)"))
+	assert.Equal(t, []string{"1: drawing p2", "2: drawing p1", "3: drawing p2"}, notebookFiles(t, srv, "/week (part 2)"))
+
+	// the merged documents keep the format of the first one
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	node, err := apiCtx.Filetree().NodeByPath("week (part 2)", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	content, err := apiCtx.DocumentContent(node.Id())
+	assert.NoError(t, err)
+	assert.Empty(t, content.Pages)
+	assert.Equal(t, 3, content.PageCount)
+	assert.NotNil(t, content.CPages)
+}
@@ -0,0 +1,201 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/model"
+)
+
+const setmetaUsage = `usage: setmeta <path> key=value...
+keys:
+  pinned          true or false
+  visibleName     the name of the document
+  lastOpenedPage  page number, starting at 0
+  lastModified    now, unix milliseconds or RFC3339`
+
+func starCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "star",
+		Help:      "star files and directories",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if err := setPinned(ctx, c.Args, true); err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+func unstarCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "unstar",
+		Help:      "unstar files and directories",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if err := setPinned(ctx, c.Args, false); err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+func setmetaCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "setmeta",
+		Help:      "change the metadata of files and directories",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if len(c.Args) < 2 {
+				c.Err(errors.New(setmetaUsage))
+				return
+			}
+
+			var updates []func(*archive.MetadataFile)
+			rename := false
+			for _, arg := range c.Args[1:] {
+				rename = rename || strings.HasPrefix(arg, "visibleName=")
+				update, err := parseMetadataUpdate(arg)
+				if err != nil {
+					c.Err(err)
+					return
+				}
+				updates = append(updates, update)
+			}
+
+			nodes, err := entryNodes(ctx, c.Args[:1])
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			if rename && len(nodes) > 1 {
+				c.Err(errors.New("cannot rename multiple entries"))
+				return
+			}
+
+			err = updateMetadata(ctx, nodes, func(metadata *archive.MetadataFile) {
+				for _, update := range updates {
+					update(metadata)
+				}
+			})
+			if err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+// entryNodes returns the files and directories matching paths
+func entryNodes(ctx *ShellCtxt, paths []string) ([]*model.Node, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("missing path")
+	}
+
+	var nodes []*model.Node
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		matches, err := ctx.api.Filetree().NodesByPath(path, ctx.node, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no entries found", path)
+		}
+		for _, n := range matches {
+			if n.IsRoot() || seen[n.Id()] {
+				continue
+			}
+			seen[n.Id()] = true
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func setPinned(ctx *ShellCtxt, paths []string, pinned bool) error {
+	nodes, err := entryNodes(ctx, paths)
+	if err != nil {
+		return err
+	}
+	return updateMetadata(ctx, nodes, func(metadata *archive.MetadataFile) {
+		metadata.Pinned = pinned
+	})
+}
+
+// updateMetadata applies update to the metadata of nodes with a single commit
+func updateMetadata(ctx *ShellCtxt, nodes []*model.Node, update func(metadata *archive.MetadataFile)) error {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.Id()
+	}
+
+	_, err := ctx.api.UpdateDocumentsMetadata(ids, func(_ string, metadata *archive.MetadataFile) error {
+		update(metadata)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.api.SyncComplete()
+	if err != nil {
+		return fmt.Errorf("cannot notify, %w", err)
+	}
+	return nil
+}
+
+// parseMetadataUpdate parses a key=value argument of setmeta
+func parseMetadataUpdate(arg string) (func(*archive.MetadataFile), error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return nil, fmt.Errorf("%s: expected key=value", arg)
+	}
+
+	switch key {
+	case "pinned":
+		pinned, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("pinned: %s is not a boolean", value)
+		}
+		return func(m *archive.MetadataFile) { m.Pinned = pinned }, nil
+	case "visibleName":
+		if value == "" {
+			return nil, errors.New("visibleName: the name can't be empty")
+		}
+		return func(m *archive.MetadataFile) { m.DocName = value }, nil
+	case "lastOpenedPage":
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 0 {
+			return nil, fmt.Errorf("lastOpenedPage: %s is not a page number", value)
+		}
+		return func(m *archive.MetadataFile) { m.LastOpenedPage = page }, nil
+	case "lastModified":
+		millis, err := parseTimestamp(value)
+		if err != nil {
+			return nil, fmt.Errorf("lastModified: %w", err)
+		}
+		return func(m *archive.MetadataFile) { m.LastModified = millis }, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown key\n%s", key, setmetaUsage)
+	}
+}
+
+// parseTimestamp converts now, unix milliseconds or a RFC3339 time into unix milliseconds
+func parseTimestamp(value string) (string, error) {
+	if value == "now" {
+		return strconv.FormatInt(time.Now().UnixMilli(), 10), nil
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", fmt.Errorf("%s is neither unix milliseconds nor RFC3339", value)
+	}
+	return strconv.FormatInt(t.UnixMilli(), 10), nil
+}
@@ -0,0 +1,46 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetaCommands(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadTestDocs(t, srv, "paper1", "paper2", "notes")
+	shell, out := newTestShellFor(t, srv)
+
+	generation := srv.Generation()
+	assert.NoError(t, shell.Process("star", "paper*"))
+	// a single commit for all the matches
+	assert.Equal(t, generation+1, srv.Generation())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("find", "--starred", "/"))
+	assert.ElementsMatch(t, []string{"[f] /paper1", "[f] /paper2"}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+
+	assert.NoError(t, shell.Process("unstar", "paper2"))
+	assert.NoError(t, shell.Process("setmeta", "notes", "visibleName=journal", "lastOpenedPage=3", "lastModified=2024-01-02T03:04:05Z"))
+
+	// a new session sees the changes
+	other, out := newTestShellFor(t, srv)
+	assert.NoError(t, other.Process("find", "--starred", "/"))
+	assert.Equal(t, "[f] /paper1\n", out.String())
+
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	node, err := apiCtx.Filetree().NodeByPath("/journal", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 3, node.Document.CurrentPage)
+	assert.Equal(t, "2024-01-02T03:04:05Z", node.Document.ModifiedClient)
+
+	assert.Error(t, other.Process("setmeta", "journal", "pinned=maybe"))
+	assert.Error(t, other.Process("setmeta", "journal", "parent=trash"))
+	assert.Error(t, other.Process("setmeta", "paper*", "visibleName=same"))
+	assert.Error(t, other.Process("star", "missing"))
+}
@@ -57,7 +57,7 @@ func mgetCmd(ctx *ShellCtxt) *ishell.Cmd {
 			fileMap[target] = struct{}{}
 
 			visitor := filetree.FileTreeVistor{
-				func(currentNode *model.Node, currentPath []string) bool {
+				Visit: func(currentNode *model.Node, currentPath []string) bool {
 					idxDir := 0
 					if srcName == "." && len(currentPath) > 0 {
 						idxDir = 1
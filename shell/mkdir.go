@@ -48,14 +48,12 @@ func mkdirCmd(ctx *ShellCtxt) *ishell.Cmd {
 				parentId = ""
 			}
 
-			document, err := ctx.api.CreateDir(parentId, newDir, true)
+			_, err = ctx.api.CreateDir(parentId, newDir, true)
 
 			if err != nil {
 				c.Err(errors.New(fmt.Sprint("failed to create directory", err)))
 				return
 			}
-
-			ctx.api.Filetree().AddDocument(document)
 		},
 	}
 }
@@ -156,14 +156,13 @@ func putFilesAndDirs(pCtx *ShellCtxt, pC *ishell.Context, localDir string, depth
 				// Directory does not exist. Create directory.
 				treeFormat(pC, depth, index, lSize, tFS)
 				pC.Printf("creating directory [%s]...", name)
-				doc, err := pCtx.api.CreateDir(pCtx.node.Id(), name, notify)
+				_, err := pCtx.api.CreateDir(pCtx.node.Id(), name, notify)
 
 				if err != nil {
 					pC.Err(errors.New(fmt.Sprint("failed to create directory", err)))
 					continue
 				} else {
 					pC.Println(" complete")
-					pCtx.api.Filetree().AddDocument(doc) // Add dir to file tree.
 				}
 			} else {
 				// Directory already exists.
@@ -174,6 +173,8 @@ func putFilesAndDirs(pCtx *ShellCtxt, pC *ishell.Context, localDir string, depth
 			// Error checking not required? Unless, someone deletes
 			// or renames the directory meanwhile.
 
+			// the directory is in the file tree published by the last upload
+			pCtx.syncNode()
 			node, _ := pCtx.api.Filetree().NodeByPath(name, pCtx.node)
 			pathToNode, _ := pCtx.api.Filetree().NodeToPath(node)
 
@@ -213,14 +214,13 @@ func putFilesAndDirs(pCtx *ShellCtxt, pC *ishell.Context, localDir string, depth
 				pC.Printf("uploading: [%s]...", name)
 
 				fullName := path.Join(localDir, name)
-				doc, err := pCtx.api.UploadDocument(pCtx.node.Id(), fullName, false, nil)
+				_, err := pCtx.api.UploadDocument(pCtx.node.Id(), fullName, false, nil)
 
 				if err != nil {
 					pC.Err(fmt.Errorf("failed to upload file '%s', %v", name, err))
 				} else {
 					// Document uploaded successfully.
 					pC.Println(" complete")
-					pCtx.api.Filetree().AddDocument(doc)
 				}
 			}
 		}
@@ -49,14 +49,12 @@ func mvCmd(ctx *ShellCtxt) *ishell.Cmd {
 						return
 					}
 
-					n, err := ctx.api.MoveEntry(node, dstNode, node.Name())
+					_, err := ctx.api.MoveEntry(node, dstNode, node.Name())
 
 					if err != nil {
 						c.Err(fmt.Errorf("failed to move entry %w", err))
 						return
 					}
-
-					ctx.api.Filetree().MoveNode(node, n)
 				}
 				err = ctx.api.SyncComplete()
 				if err != nil {
@@ -82,7 +80,7 @@ func mvCmd(ctx *ShellCtxt) *ishell.Cmd {
 				return
 			}
 
-			n, err := ctx.api.MoveEntry(srcNode, parentNode, newEntry)
+			_, err = ctx.api.MoveEntry(srcNode, parentNode, newEntry)
 
 			if err != nil {
 				c.Err(fmt.Errorf("failed to move entry, %w", err))
@@ -92,8 +90,6 @@ func mvCmd(ctx *ShellCtxt) *ishell.Cmd {
 			if err != nil {
 				c.Err(fmt.Errorf("cannot notify, %w", err))
 			}
-
-			ctx.api.Filetree().MoveNode(srcNode, n)
 		},
 	}
 }
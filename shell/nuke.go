@@ -28,7 +28,6 @@ func nukeCmd(ctx *ShellCtxt) *ishell.Cmd {
 				c.Err(fmt.Errorf("failed to delete entry: %v", err))
 				return
 			}
-			ctx.api.Filetree().Clear()
 		},
 	}
 }
@@ -0,0 +1,227 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/model"
+	flag "github.com/ogier/pflag"
+)
+
+const pagesUsage = `usage:
+  pages ls <path>                          list the pages of a document
+  pages mv <path> <page> <position>        move a page to a position
+  pages rm <path> <pages>...               remove pages, e.g. 3 5-8 10-
+  pages insert-blank [--template=name] [--count=n] <path> <position>
+                                           insert blank pages at a position
+  pages dup [--count=n] <path> <page>      duplicate a page after itself
+  pages set-template <path> <template> [pages]...
+                                           change the template of pages, all by default
+pages and positions start at 1`
+
+func pagesCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "pages",
+		Help:      "list, move, remove, insert and duplicate the pages of documents",
+		Completer: createFileCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("pages", flag.ContinueOnError)
+			template := flagSet.String("template", "Blank", "template of the inserted pages")
+			count := flagSet.IntP("count", "n", 1, "number of pages to insert or duplicate")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) < 2 || *count < 1 {
+				c.Err(errors.New(pagesUsage))
+				return
+			}
+
+			cmd, path, args := args[0], args[1], args[2:]
+			node, err := ctx.api.Filetree().NodeByPath(path, ctx.node)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			if !node.IsFile() {
+				c.Err(fmt.Errorf("%s is a directory", path))
+				return
+			}
+
+			switch {
+			case cmd == "ls" && len(args) == 0:
+				err = listPages(ctx, c, node)
+			case cmd == "mv" && len(args) == 2:
+				err = changePages(ctx, node, func(pages []archive.PageInfo) ([]archive.PageInfo, error) {
+					return movePage(pages, args[0], args[1])
+				})
+			case cmd == "rm" && len(args) > 0:
+				err = changePages(ctx, node, func(pages []archive.PageInfo) ([]archive.PageInfo, error) {
+					return removePages(pages, args)
+				})
+			case cmd == "insert-blank" && len(args) == 1:
+				err = changePages(ctx, node, func(pages []archive.PageInfo) ([]archive.PageInfo, error) {
+					position, err := parsePage(args[0], len(pages)+1)
+					if err != nil {
+						return nil, err
+					}
+					var blank []archive.PageInfo
+					for i := 0; i < *count; i++ {
+						blank = append(blank, archive.NewBlankPage(*template))
+					}
+					return insertPages(pages, position-1, blank), nil
+				})
+			case cmd == "dup" && len(args) == 1:
+				err = changePages(ctx, node, func(pages []archive.PageInfo) ([]archive.PageInfo, error) {
+					page, err := parsePage(args[0], len(pages))
+					if err != nil {
+						return nil, err
+					}
+					var copies []archive.PageInfo
+					for i := 0; i < *count; i++ {
+						copies = append(copies, pages[page-1].Duplicate())
+					}
+					return insertPages(pages, page, copies), nil
+				})
+			case cmd == "set-template" && len(args) > 0:
+				err = changePages(ctx, node, func(pages []archive.PageInfo) ([]archive.PageInfo, error) {
+					return setTemplate(pages, args[0], args[1:])
+				})
+			default:
+				err = errors.New(pagesUsage)
+			}
+
+			if err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+func listPages(ctx *ShellCtxt, c *ishell.Context, node *model.Node) error {
+	pages, err := ctx.api.DocumentPages(node.Id())
+	if err != nil {
+		return err
+	}
+
+	for i, p := range pages {
+		docPage := "-"
+		if p.DocPage >= 0 {
+			docPage = fmt.Sprintf("doc %d", p.DocPage+1)
+		}
+		annotated := ""
+		if p.Annotated {
+			annotated = "\tannotated"
+		}
+		c.Printf("%d\t%s\t%s\t%s%s\n", i+1, p.ID, docPage, p.Pagedata, annotated)
+	}
+	return nil
+}
+
+// changePages applies update to the pages of the document node
+func changePages(ctx *ShellCtxt, node *model.Node, update func(pages []archive.PageInfo) ([]archive.PageInfo, error)) error {
+	_, err := ctx.api.UpdatePages(node.Id(), update)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.api.SyncComplete()
+	if err != nil {
+		return fmt.Errorf("cannot notify, %w", err)
+	}
+	return nil
+}
+
+// parsePage parses a page number between 1 and max
+func parsePage(s string, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("invalid page %s, the document has %d pages", s, max)
+	}
+	return n, nil
+}
+
+// parsePageRange parses a page n, a range n-m or n- till the last page
+func parsePageRange(s string, max int) (from, to int, err error) {
+	first, last, isRange := strings.Cut(s, "-")
+	from, err = parsePage(first, max)
+	if err != nil || !isRange {
+		return from, from, err
+	}
+	if last == "" {
+		return from, max, nil
+	}
+	to, err = parsePage(last, max)
+	if err == nil && to < from {
+		err = fmt.Errorf("invalid page range %s", s)
+	}
+	return from, to, err
+}
+
+func movePage(pages []archive.PageInfo, page, position string) ([]archive.PageInfo, error) {
+	from, err := parsePage(page, len(pages))
+	if err != nil {
+		return nil, err
+	}
+	to, err := parsePage(position, len(pages))
+	if err != nil {
+		return nil, err
+	}
+
+	moved := pages[from-1]
+	rest := append(append([]archive.PageInfo{}, pages[:from-1]...), pages[from:]...)
+	return insertPages(rest, to-1, []archive.PageInfo{moved}), nil
+}
+
+func removePages(pages []archive.PageInfo, ranges []string) ([]archive.PageInfo, error) {
+	removed := make(map[int]bool)
+	for _, r := range ranges {
+		from, to, err := parsePageRange(r, len(pages))
+		if err != nil {
+			return nil, err
+		}
+		for n := from; n <= to; n++ {
+			removed[n-1] = true
+		}
+	}
+
+	var kept []archive.PageInfo
+	for i, p := range pages {
+		if !removed[i] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+// setTemplate sets the template of the pages in ranges, of all pages if there is none
+func setTemplate(pages []archive.PageInfo, template string, ranges []string) ([]archive.PageInfo, error) {
+	if len(ranges) == 0 {
+		ranges = []string{"1-"}
+	}
+	result := append([]archive.PageInfo{}, pages...)
+	for _, r := range ranges {
+		from, to, err := parsePageRange(r, len(pages))
+		if err != nil {
+			return nil, err
+		}
+		for n := from; n <= to; n++ {
+			result[n-1].Pagedata = template
+		}
+	}
+	return result, nil
+}
+
+// insertPages inserts pages into all at index i
+func insertPages(all []archive.PageInfo, i int, pages []archive.PageInfo) []archive.PageInfo {
+	result := append([]archive.PageInfo{}, all[:i]...)
+	result = append(result, pages...)
+	return append(result, all[i:]...)
+}
@@ -0,0 +1,187 @@
+package shell
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/juruen/rmapi/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadNotebook uploads a notebook whose pages p1, p2... have drawings
+func uploadNotebook(t *testing.T, srv *apitest.Server, name string, pages int) {
+	var ids, drawings []string
+	for i := 1; i <= pages; i++ {
+		id := fmt.Sprintf("p%d", i)
+		ids = append(ids, id)
+		drawings = append(drawings, "drawing "+id)
+	}
+	uploadNotebookPages(t, srv, name, ids, drawings)
+}
+
+// uploadNotebookPages uploads a notebook with the pages ids and their .rm files,
+// the pages with an empty drawing have no .rm file
+func uploadNotebookPages(t *testing.T, srv *apitest.Server, name string, ids, drawings []string) {
+	content, _ := json.Marshal(map[string]interface{}{"fileType": "notebook", "pages": ids, "pageCount": len(ids)})
+	uploadNotebookContent(t, srv, name, ids, drawings, content)
+}
+
+// uploadCPagesNotebook uploads a notebook like uploadNotebook in the .content format of
+// the newer firmwares, which lists the pages in cPages
+func uploadCPagesNotebook(t *testing.T, srv *apitest.Server, name string, pages int) {
+	var ids, drawings, cpages []string
+	for i := 1; i <= pages; i++ {
+		id := fmt.Sprintf("p%d", i)
+		ids = append(ids, id)
+		drawings = append(drawings, "drawing "+id)
+		cpages = append(cpages, fmt.Sprintf(`{"id":"%s","idx":{"timestamp":"1:2","value":"b%c"},`+
+			`"template":{"timestamp":"1:1","value":"Blank"},"scrollTime":{"timestamp":"1:3","value":"1718093441000"}}`, id, 'a'+i-1))
+	}
+	content := `{"cPages":{"lastOpened":{"timestamp":"1:1","value":"p1"},"original":{"timestamp":"0:0","value":-1},` +
+		`"pages":[` + strings.Join(cpages, ",") + `],"uuids":[{"first":"4b1d9a3c-7e2f-4d6a-b8c5-3f0e1a2d9c7b","second":1}]},` +
+		`"fileType":"notebook","formatVersion":2,"pageCount":` + fmt.Sprint(pages) + `,"tags":[]}`
+	uploadNotebookContent(t, srv, name, ids, drawings, []byte(content))
+}
+
+// uploadNotebookContent uploads a notebook with the .content and the pages ids
+func uploadNotebookContent(t *testing.T, srv *apitest.Server, name string, ids, drawings []string, content []byte) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	notebookID := uuid.New().String()
+	add := func(name, data string) {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(data))
+	}
+
+	for i, id := range ids {
+		if drawings[i] != "" {
+			add(notebookID+"/"+id+".rm", drawings[i])
+		}
+		add(notebookID+"/"+id+"-metadata.json", `{"layers":[{"name":"Layer 1"}]}`)
+	}
+	add(notebookID+".content", string(content))
+	add(notebookID+".pagedata", strings.Repeat("Blank\n", len(ids)))
+	assert.NoError(t, zw.Close())
+
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	_, err = apiCtx.UploadDocumentFrom("", name, util.ZIP, bytes.NewReader(buf.Bytes()), false)
+	assert.NoError(t, err)
+}
+
+// notebookFiles returns the page files of the notebook with their content
+func notebookFiles(t *testing.T, srv *apitest.Server, name string) []string {
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	node, err := apiCtx.Filetree().NodeByPath(name, nil)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, apiCtx.FetchDocumentTo(node.Id(), &buf))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	pageIDs := make(map[string]string)
+	pages, err := apiCtx.DocumentPages(node.Id())
+	assert.NoError(t, err)
+	for i, p := range pages {
+		pageIDs[p.ID] = fmt.Sprint(i + 1)
+	}
+
+	var files []string
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".rm") {
+			continue
+		}
+		r, err := f.Open()
+		assert.NoError(t, err)
+		var data bytes.Buffer
+		data.ReadFrom(r)
+		r.Close()
+		id := strings.TrimSuffix(f.Name[strings.LastIndex(f.Name, "/")+1:], ".rm")
+		files = append(files, pageIDs[id]+": "+data.String())
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestPagesCommands(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadNotebook(t, srv, "meeting", 4)
+	shell, out := newTestShellFor(t, srv)
+
+	out.Reset()
+	assert.NoError(t, shell.Process("pages", "ls", "meeting"))
+	assert.Equal(t, "1\tp1\t-\tBlank\tannotated\n2\tp2\t-\tBlank\tannotated\n"+
+		"3\tp3\t-\tBlank\tannotated\n4\tp4\t-\tBlank\tannotated\n", out.String())
+
+	assert.NoError(t, shell.Process("pages", "mv", "meeting", "4", "1"))
+	assert.NoError(t, shell.Process("pages", "rm", "meeting", "2", "4-"))
+	assert.Equal(t, []string{"1: drawing p4", "2: drawing p2"}, notebookFiles(t, srv, "/meeting"))
+
+	assert.NoError(t, shell.Process("pages", "dup", "meeting", "1"))
+	assert.NoError(t, shell.Process("pages", "insert-blank", "--template=Lined", "meeting", "1"))
+	assert.Equal(t, []string{"2: drawing p4", "3: drawing p4", "4: drawing p2"}, notebookFiles(t, srv, "/meeting"))
+
+	other, out := newTestShellFor(t, srv)
+	assert.NoError(t, other.Process("pages", "ls", "meeting"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasSuffix(lines[0], "\t-\tLined"), lines[0])
+
+	// nothing changes
+	generation := srv.Generation()
+	assert.NoError(t, other.Process("pages", "mv", "meeting", "2", "2"))
+	assert.Equal(t, generation, srv.Generation())
+
+	assert.Error(t, other.Process("pages", "rm", "meeting", "1-4"))
+	assert.Error(t, other.Process("pages", "mv", "meeting", "5", "1"))
+	assert.Error(t, other.Process("pages", "rm", "meeting", "3-2"))
+}
+
+func TestPagesCommandsCPages(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadCPagesNotebook(t, srv, "meeting", 4)
+	shell, out := newTestShellFor(t, srv)
+
+	assert.NoError(t, shell.Process("pages", "mv", "meeting", "4", "1"))
+	assert.NoError(t, shell.Process("pages", "rm", "meeting", "2", "4-"))
+	assert.Equal(t, []string{"1: drawing p4", "2: drawing p2"}, notebookFiles(t, srv, "/meeting"))
+
+	assert.NoError(t, shell.Process("pages", "dup", "meeting", "1"))
+	assert.NoError(t, shell.Process("pages", "insert-blank", "--template=Lined", "meeting", "1"))
+	assert.NoError(t, shell.Process("pages", "set-template", "meeting", "Dots S", "4"))
+	assert.Equal(t, []string{"2: drawing p4", "3: drawing p4", "4: drawing p2"}, notebookFiles(t, srv, "/meeting"))
+
+	other, out := newTestShellFor(t, srv)
+	out.Reset()
+	assert.NoError(t, other.Process("pages", "ls", "meeting"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.True(t, strings.HasSuffix(lines[0], "\t-\tLined"), lines[0])
+		assert.Equal(t, "4\tp2\t-\tDots S\tannotated", lines[3])
+	}
+
+	// the pages stay in cPages
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	node, err := apiCtx.Filetree().NodeByPath("meeting", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	content, err := apiCtx.DocumentContent(node.Id())
+	assert.NoError(t, err)
+	assert.Empty(t, content.Pages)
+	assert.Equal(t, 4, content.PageCount)
+	assert.Len(t, content.CPages.Pages, 6)
+}
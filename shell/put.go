@@ -19,7 +19,10 @@ func putCmd(ctx *ShellCtxt) *ishell.Cmd {
 Options:
   --force              Overwrite existing file (recreates document)
   --content-only       Replace PDF content only (preserves document metadata)
-  --coverpage=<0|1>    Set coverpage (0 to disable, 1 to set first page as cover)`,
+  --coverpage=<0|1>    Set coverpage (0 to disable, 1 to set first page as cover)
+` + layoutUsage + `
+
+The layout settings are part of the upload.`,
 		Func: func(c *ishell.Context) {
 			if len(c.Args) == 0 {
 				c.Err(errors.New("missing source file"))
@@ -33,12 +36,19 @@ Options:
 			force := flags.Bool("force", false, "overwrite existing file")
 			contentOnly := flags.Bool("content-only", false, "replace PDF content only")
 			coverpage := flags.String("coverpage", "", "set coverpage (0 or 1)")
+			layoutFlags := addLayoutFlags(flags)
 
 			if err := flags.Parse(c.Args); err != nil {
 				c.Err(err)
 				return
 			}
 
+			layout, err := layoutFlags.layout()
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
 			args := flags.Args()
 			if len(args) == 0 {
 				c.Err(errors.New("missing source file"))
@@ -52,14 +62,13 @@ Options:
 			}
 
 			// Parse coverpage flag
-			var coverpageFlag *int
 			if *coverpage != "" {
 				switch *coverpage {
 				case "0":
-					// Don't set coverpage (coverpageFlag remains nil)
+					// Don't set coverpage
 				case "1":
 					val := 0 // First page is 0 in the document metadata
-					coverpageFlag = &val
+					layout.CoverPageNumber = &val
 				default:
 					c.Err(errors.New("--coverpage value must be 0 or 1"))
 					return
@@ -79,7 +88,6 @@ Options:
 
 				docName, _ := util.DocPathToName(srcName)
 				node := ctx.node
-				var err error
 
 				// Parse destination directory if provided
 				if len(args) == 2 {
@@ -95,13 +103,12 @@ Options:
 					// Document doesn't exist, create new one
 					c.Printf("uploading: [%s]...", srcName)
 					dstDir := node.Id()
-					document, err := ctx.api.UploadDocument(dstDir, srcName, true, coverpageFlag)
+					_, err := ctx.api.UploadDocument(dstDir, srcName, true, layout)
 					if err != nil {
 						c.Err(fmt.Errorf("failed to upload file [%s]: %v", srcName, err))
 						return
 					}
 					c.Println("OK")
-					ctx.api.Filetree().AddDocument(document)
 					return
 				}
 
@@ -111,7 +118,7 @@ Options:
 				}
 
 				c.Printf("replacing PDF content of [%s] with [%s]...", docName, srcName)
-				if err := ctx.api.ReplaceDocumentFile(existingNode.Document.ID, srcName, true); err != nil {
+				if err := ctx.api.ReplaceDocumentFile(existingNode.Document.ID, srcName, true, layout); err != nil {
 					c.Err(fmt.Errorf("failed to replace content: %v", err))
 					return
 				}
@@ -122,7 +129,6 @@ Options:
 			// Handle regular upload or --force mode
 			docName, _ := util.DocPathToName(srcName)
 			node := ctx.node
-			var err error
 
 			// Parse destination directory if provided
 			if len(args) == 2 {
@@ -153,25 +159,23 @@ Options:
 					c.Err(fmt.Errorf("failed to delete existing file: %v", err))
 					return
 				}
-				ctx.api.Filetree().DeleteNode(existingNode)
 
 				// Upload new document
 				dstDir := node.Id()
-				document, err := ctx.api.UploadDocument(dstDir, srcName, true, coverpageFlag)
+				_, err := ctx.api.UploadDocument(dstDir, srcName, true, layout)
 				if err != nil {
 					c.Err(fmt.Errorf("failed to upload replacement file [%s]: %v", srcName, err))
 					return
 				}
 
 				c.Println("OK")
-				ctx.api.Filetree().AddDocument(document)
 				return
 			}
 
 			// File doesn't exist, upload new document
 			c.Printf("uploading: [%s]...", srcName)
 			dstDir := node.Id()
-			document, err := ctx.api.UploadDocument(dstDir, srcName, true, coverpageFlag)
+			_, err = ctx.api.UploadDocument(dstDir, srcName, true, layout)
 
 			if err != nil {
 				c.Err(fmt.Errorf("failed to upload file [%s] %v", srcName, err))
@@ -179,8 +183,6 @@ Options:
 			}
 
 			c.Println("OK")
-
-			ctx.api.Filetree().AddDocument(document)
 		},
 	}
 }
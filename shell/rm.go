@@ -5,17 +5,21 @@ import (
 	"fmt"
 
 	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
 	flag "github.com/ogier/pflag"
 )
 
 func rmCmd(ctx *ShellCtxt) *ishell.Cmd {
 	return &ishell.Cmd{
 		Name:      "rm",
-		Help:      "delete entry",
+		Help:      "move entry to the trash, entries in the trash are deleted",
 		Completer: createEntryCompleter(ctx),
 		Func: func(c *ishell.Context) {
 			flagSet := flag.NewFlagSet("rm", flag.ContinueOnError)
 			recursive := flagSet.BoolP("recursive", "r", false, "remove non empty folders")
+			permanent := flagSet.Bool("permanent", false, "delete instead of moving to the trash")
 			if err := flagSet.Parse(c.Args); err != nil {
 				if err != flag.ErrHelp {
 					c.Err(err)
@@ -28,30 +32,78 @@ func rmCmd(ctx *ShellCtxt) *ishell.Cmd {
 				return
 			}
 
-			for _, target := range argRest {
-				nodes, err := ctx.api.Filetree().NodesByPath(target, ctx.node, false)
+			// an entry matched by several arguments is removed once
+			nodes, err := entryNodes(ctx, argRest)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			if len(nodes) == 0 {
+				c.Err(errors.New("cannot delete the root directory"))
+				return
+			}
 
-				if err != nil {
-					c.Err(err)
+			trash := ctx.api.Filetree().NodeById(filetree.TrashID)
+			var toTrash, toDelete []*model.Node
+			for _, node := range nodes {
+				if node == trash {
+					c.Err(fmt.Errorf("cannot delete %s", node.Name()))
 					return
 				}
-				for _, node := range nodes {
-					c.Println("deleting: ", node.Name())
-					err = ctx.api.DeleteEntry(node, *recursive, true)
+				if node.IsDirectory() && len(node.Children) > 0 && !*recursive {
+					c.Err(fmt.Errorf("failed to delete entry, %s: directory is not empty", node.Name()))
+					return
+				}
+				if *permanent || isSubdir(trash, node) {
+					toDelete = append(toDelete, node)
+				} else {
+					toTrash = append(toTrash, node)
+				}
+			}
 
-					if err != nil {
-						c.Err(fmt.Errorf("failed to delete entry, %v", err))
-						return
-					}
+			if len(toTrash) > 0 {
+				for _, node := range toTrash {
+					c.Println("trashing: ", node.Name())
+				}
+				err := moveToTrash(ctx, toTrash)
+				if err != nil {
+					c.Err(fmt.Errorf("failed to trash entry, %v", err))
+					return
+				}
+			}
 
-					ctx.api.Filetree().DeleteNode(node)
+			if len(toDelete) > 0 {
+				for _, node := range toDelete {
+					c.Println("deleting: ", node.Name())
+				}
+				err := ctx.api.DeleteEntries(toDelete, *recursive, true)
+				if err != nil {
+					c.Err(fmt.Errorf("failed to delete entry, %v", err))
+					return
 				}
 			}
 
-			err := ctx.api.SyncComplete()
+			err = ctx.api.SyncComplete()
 			if err != nil {
 				c.Err(err)
 			}
 		},
 	}
 }
+
+// moveToTrash moves nodes into the trash and records their parent for restore
+func moveToTrash(ctx *ShellCtxt, nodes []*model.Node) error {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.Id()
+	}
+
+	now := archive.UnixTimestamp()
+	_, err := ctx.api.UpdateDocumentsMetadata(ids, func(_ string, metadata *archive.MetadataFile) error {
+		metadata.TrashedFrom = metadata.Parent
+		metadata.Parent = filetree.TrashID
+		metadata.LastModified = now
+		return nil
+	})
+	return err
+}
@@ -10,6 +10,7 @@ import (
 )
 
 func prefixToNodeDir(ctx *ShellCtxt, s []string) (*model.Node, string) {
+	ctx.syncNode()
 	node := ctx.node
 	isPrefix := len(s) > 0 && s[len(s)-1] != ""
 
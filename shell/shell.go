@@ -22,6 +22,34 @@ func (ctx *ShellCtxt) prompt() string {
 	return fmt.Sprintf("[%s]>", ctx.path)
 }
 
+// syncNode finds the current directory in the current file tree, a new tree
+// is published by the api when the documents change
+func (ctx *ShellCtxt) syncNode() {
+	ft := ctx.api.Filetree()
+	if n := ft.NodeById(ctx.node.Id()); n != nil {
+		ctx.node = n
+		return
+	}
+
+	ctx.node = ft.Root()
+	ctx.path = ctx.node.Name()
+}
+
+// syncCommands makes the commands of shell work on the current file tree
+func syncCommands(shell *ishell.Shell, ctx *ShellCtxt) {
+	for _, cmd := range shell.Cmds() {
+		run := cmd.Func
+		if run == nil {
+			continue
+		}
+		cmd.Func = func(c *ishell.Context) {
+			ctx.syncNode()
+			c.SetPrompt(ctx.prompt())
+			run(c)
+		}
+	}
+}
+
 func setCustomCompleter(shell *ishell.Shell) {
 	cmdCompleter := make(cmdToCompleter)
 	for _, cmd := range shell.Cmds() {
@@ -43,6 +71,20 @@ func useHiddenFiles() bool {
 }
 
 func RunShell(apiCtx api.ApiCtx, userInfo *api.UserInfo, args []string, jsonOutput bool) error {
+	shell := newShell(apiCtx, userInfo, jsonOutput)
+
+	if len(args) > 0 {
+		return shell.Process(args...)
+	} else {
+		shell.Printf("ReMarkable Cloud API Shell, User: %s, SyncVersion: %s\n", userInfo.User, userInfo.SyncVersion)
+		shell.Run()
+
+		return nil
+	}
+}
+
+// newShell creates a shell with all the commands working on apiCtx
+func newShell(apiCtx api.ApiCtx, userInfo *api.UserInfo, jsonOutput bool) *ishell.Shell {
 	shell := ishell.New()
 	ctx := &ShellCtxt{
 		node:           apiCtx.Filetree().Root(),
@@ -62,25 +104,33 @@ func RunShell(apiCtx api.ApiCtx, userInfo *api.UserInfo, args []string, jsonOutp
 	shell.AddCmd(mgetCmd(ctx))
 	shell.AddCmd(mkdirCmd(ctx))
 	shell.AddCmd(rmCmd(ctx))
+	shell.AddCmd(restoreCmd(ctx))
+	shell.AddCmd(emptyTrashCmd(ctx))
 	shell.AddCmd(mvCmd(ctx))
+	shell.AddCmd(cpCmd(ctx))
 	shell.AddCmd(putCmd(ctx))
 	shell.AddCmd(mputCmd(ctx))
 	shell.AddCmd(versionCmd(ctx))
 	shell.AddCmd(statCmd(ctx))
 	shell.AddCmd(getACmd(ctx))
 	shell.AddCmd(findCmd(ctx))
+	shell.AddCmd(tagCmd(ctx))
+	shell.AddCmd(pagesCmd(ctx))
+	shell.AddCmd(mergeCmd(ctx))
+	shell.AddCmd(splitCmd(ctx))
+	shell.AddCmd(templateCmd(ctx))
+	shell.AddCmd(catCmd(ctx))
+	shell.AddCmd(highlightsCmd(ctx))
+	shell.AddCmd(starCmd(ctx))
+	shell.AddCmd(unstarCmd(ctx))
+	shell.AddCmd(setmetaCmd(ctx))
+	shell.AddCmd(setContentCmd(ctx))
 	shell.AddCmd(nukeCmd(ctx))
 	shell.AddCmd(accountCmd(ctx))
 	shell.AddCmd(refreshCmd(ctx))
 
+	syncCommands(shell, ctx)
 	setCustomCompleter(shell)
 
-	if len(args) > 0 {
-		return shell.Process(args...)
-	} else {
-		shell.Printf("ReMarkable Cloud API Shell, User: %s, SyncVersion: %s\n", userInfo.User, userInfo.SyncVersion)
-		shell.Run()
-
-		return nil
-	}
+	return shell
 }
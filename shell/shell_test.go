@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/api"
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/juruen/rmapi/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestShell creates a shell working on an in-memory cloud, the output is written into out
+func newTestShell(t *testing.T) (shell *ishell.Shell, out *bytes.Buffer, srv *apitest.Server) {
+	srv = apitest.NewServer()
+	shell, out = newTestShellFor(t, srv)
+	return
+}
+
+// newTestShellFor creates a shell working on the current state of srv
+func newTestShellFor(t *testing.T, srv *apitest.Server) (*ishell.Shell, *bytes.Buffer) {
+	apiCtx, err := srv.NewApiCtx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	shell := newShell(apiCtx, &api.UserInfo{User: "test", SyncVersion: api.Version15}, false)
+	shell.SetOut(out)
+	return shell, out
+}
+
+// uploadTestDocs uploads pdf documents with the given names into the root of srv
+func uploadTestDocs(t *testing.T, srv *apitest.Server, names ...string) {
+	apiCtx, err := srv.NewApiCtx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		_, err := apiCtx.UploadDocumentFrom("", name, util.PDF, strings.NewReader("%PDF-1.4"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestShellCommands(t *testing.T) {
+	shell, out, _ := newTestShell(t)
+
+	assert.NoError(t, shell.Process("mkdir", "books"))
+	assert.NoError(t, shell.Process("mkdir", "books/novels"))
+	assert.NoError(t, shell.Process("mkdir", "papers"))
+
+	out.Reset()
+	assert.NoError(t, shell.Process("ls"))
+	assert.Equal(t, "[d]\tbooks\n[d]\tpapers\n[d]\ttrash\n", out.String())
+
+	assert.NoError(t, shell.Process("mv", "papers", "books"))
+	assert.NoError(t, shell.Process("cd", "books"))
+
+	out.Reset()
+	assert.NoError(t, shell.Process("ls"))
+	assert.Equal(t, "[d]\tnovels\n[d]\tpapers\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("pwd"))
+	assert.Equal(t, "/books\n", out.String())
+
+	assert.NoError(t, shell.Process("rm", "papers"))
+	out.Reset()
+	assert.NoError(t, shell.Process("ls"))
+	assert.Equal(t, "[d]\tnovels\n", out.String())
+
+	assert.Error(t, shell.Process("cd", "missing"))
+}
+
+func TestShellUnauthorized(t *testing.T) {
+	shell, _, srv := newTestShell(t)
+
+	srv.SetUnauthorized(true)
+	assert.Error(t, shell.Process("mkdir", "books"))
+}
+
+func TestShellMput(t *testing.T) {
+	shell, out, _ := newTestShell(t)
+	assert.NoError(t, shell.Process("mkdir", "backup"))
+
+	src := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "books", "novels"), 0755))
+	for _, name := range []string{"notes.pdf", "books/paper.pdf", "books/novels/story.pdf"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(src, name), []byte("%PDF-1.4"), 0644))
+	}
+	assert.NoError(t, shell.Process("mput", "--src", src, "backup"))
+
+	// the new directories are found right after they are created
+	out.Reset()
+	assert.NoError(t, shell.Process("ls", "backup/books"))
+	assert.Equal(t, "[d]\tnovels\n[f]\tpaper\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("ls", "backup/books/novels"))
+	assert.Equal(t, "[f]\tstory\n", out.String())
+}
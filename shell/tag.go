@@ -0,0 +1,235 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
+	flag "github.com/ogier/pflag"
+)
+
+const tagUsage = `usage:
+  tag list [path]                    list the tags of a document or of all documents
+  tag add [--page=n] <path> <tag>    add a tag to documents or to their page n
+  tag remove [--page=n] <path> <tag> remove a tag from documents or from their page n
+  tag rename <path> <old> <new>      rename a tag of documents
+  tag rename <old> <new>             rename a tag in all documents`
+
+func tagCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "tag",
+		Help:      "list and change the tags of documents and pages",
+		Completer: createFileCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("tag", flag.ContinueOnError)
+			page := flagSet.IntP("page", "p", 0, "page number, starting at 1")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) < 1 {
+				c.Err(errors.New(tagUsage))
+				return
+			}
+
+			var err error
+			switch cmd, args := args[0], args[1:]; {
+			case cmd == "list" && len(args) == 0:
+				err = listAllTags(ctx, c)
+			case cmd == "list" && len(args) == 1:
+				err = listTags(ctx, c, args[0])
+			case cmd == "add" && len(args) == 2:
+				err = changeTags(ctx, args[0], *page, func(content *archive.Content, pageID string) {
+					content.AddTag(args[1], pageID)
+				})
+			case cmd == "remove" && len(args) == 2:
+				err = changeTags(ctx, args[0], *page, func(content *archive.Content, pageID string) {
+					content.RemoveTag(args[1], pageID)
+				})
+			case cmd == "rename" && len(args) == 3:
+				err = changeTags(ctx, args[0], 0, func(content *archive.Content, _ string) {
+					content.RenameTag(args[1], args[2])
+				})
+			case cmd == "rename" && len(args) == 2:
+				err = renameTag(ctx, c, args[0], args[1])
+			default:
+				err = errors.New(tagUsage)
+			}
+
+			if err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+// documentNodes returns the documents matching path
+func documentNodes(ctx *ShellCtxt, path string) ([]*model.Node, error) {
+	nodes, err := ctx.api.Filetree().NodesByPath(path, ctx.node, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*model.Node
+	for _, n := range nodes {
+		if n.IsFile() {
+			docs = append(docs, n)
+		}
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%s: no documents found", path)
+	}
+	return docs, nil
+}
+
+// allDocumentNodes returns every document of the library
+func allDocumentNodes(ctx *ShellCtxt) []*model.Node {
+	var docs []*model.Node
+	filetree.WalkTree(ctx.api.Filetree().Root(), filetree.FileTreeVistor{
+		Visit: func(node *model.Node, path []string) bool {
+			if node.IsFile() {
+				docs = append(docs, node)
+			}
+			return filetree.ContinueVisiting
+		},
+	})
+	return docs
+}
+
+// changeTags applies update to the documents of path, a page > 0 selects the page to update
+func changeTags(ctx *ShellCtxt, path string, page int, update func(content *archive.Content, pageID string)) error {
+	nodes, err := documentNodes(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.Id()
+	}
+
+	_, err = ctx.api.UpdateContent(ids, func(docId string, content *archive.Content) error {
+		pageID := ""
+		if page > 0 {
+			pages := content.PageIDs()
+			if page > len(pages) {
+				return fmt.Errorf("page %d doesn't exist, the document has %d pages", page, len(pages))
+			}
+			pageID = pages[page-1]
+		}
+		update(content, pageID)
+		return nil
+	})
+	return err
+}
+
+func listTags(ctx *ShellCtxt, c *ishell.Context, path string) error {
+	node, err := ctx.api.Filetree().NodeByPath(path, ctx.node)
+	if err != nil {
+		return err
+	}
+	if !node.IsFile() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	content, err := ctx.api.DocumentContent(node.Id())
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range content.DocumentTags {
+		c.Println(tag.Name)
+	}
+
+	pageNumbers := make(map[string]int)
+	for i, id := range content.PageIDs() {
+		pageNumbers[id] = i + 1
+	}
+	for _, tag := range content.PageTags {
+		if n, ok := pageNumbers[tag.PageID]; ok {
+			c.Printf("%s (page %d)\n", tag.Name, n)
+		} else {
+			c.Printf("%s (page %s)\n", tag.Name, tag.PageID)
+		}
+	}
+	return nil
+}
+
+func listAllTags(ctx *ShellCtxt, c *ishell.Context) error {
+	counts := make(map[string]int)
+	for _, n := range allDocumentNodes(ctx) {
+		content, err := ctx.api.DocumentContent(n.Id())
+		if err != nil {
+			return err
+		}
+
+		names := make(map[string]bool)
+		for _, tag := range content.DocumentTags {
+			names[tag.Name] = true
+		}
+		for _, tag := range content.PageTags {
+			names[tag.Name] = true
+		}
+		for name := range names {
+			counts[name]++
+		}
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.Printf("%s\t%d\n", name, counts[name])
+	}
+	return nil
+}
+
+// renameTag renames a tag in every document with a single commit
+func renameTag(ctx *ShellCtxt, c *ishell.Context, old, new string) error {
+	var ids []string
+	for _, n := range allDocumentNodes(ctx) {
+		content, err := ctx.api.DocumentContent(n.Id())
+		if err != nil {
+			return err
+		}
+		if hasTag(content, old) {
+			ids = append(ids, n.Id())
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no documents with the tag %s", old)
+	}
+
+	docs, err := ctx.api.UpdateContent(ids, func(docId string, content *archive.Content) error {
+		content.RenameTag(old, new)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.Printf("renamed the tag in %d documents\n", len(docs))
+	return nil
+}
+
+func hasTag(content *archive.Content, name string) bool {
+	for _, tag := range content.DocumentTags {
+		if tag.Name == name {
+			return true
+		}
+	}
+	for _, tag := range content.PageTags {
+		if tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
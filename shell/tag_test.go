@@ -0,0 +1,41 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagCommands(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadTestDocs(t, srv, "paper1", "paper2")
+	shell, out := newTestShellFor(t, srv)
+
+	assert.NoError(t, shell.Process("tag", "add", "paper*", "ml"))
+	assert.NoError(t, shell.Process("tag", "add", "paper1", "to read"))
+
+	out.Reset()
+	assert.NoError(t, shell.Process("tag", "list", "paper1"))
+	assert.Equal(t, "ml\nto read\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("find", "--tag=to read", "/"))
+	assert.Equal(t, "[f] /paper1\n", out.String())
+
+	assert.NoError(t, shell.Process("tag", "remove", "paper1", "to read"))
+	generation := srv.Generation()
+	out.Reset()
+	assert.NoError(t, shell.Process("tag", "rename", "ml", "machine learning"))
+	assert.Equal(t, "renamed the tag in 2 documents\n", out.String())
+	// a single commit for the library
+	assert.Equal(t, generation+1, srv.Generation())
+
+	// a new session sees the changes
+	other, out := newTestShellFor(t, srv)
+	assert.NoError(t, other.Process("tag", "list"))
+	assert.Equal(t, "machine learning\t2\n", out.String())
+
+	assert.Error(t, other.Process("tag", "add", "--page=2", "paper1", "p2"))
+	assert.Error(t, other.Process("tag", "add", "missing", "ml"))
+}
@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
+	flag "github.com/ogier/pflag"
+)
+
+const templateUsage = `usage:
+  template put [options] <file.svg|file.png>  upload a custom template
+      --name=<name>        name of the template, the file name by default
+      --category=<name>    category of the template, can be repeated, Custom by default
+      --landscape          the template is in landscape
+      --icon-code=<code>   icon of the template on the tablet
+  template ls                                 list the custom templates
+  template rm <name>...                       remove custom templates`
+
+func templateCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "template",
+		Help:      "upload, list and remove custom templates",
+		Completer: createFsEntryCompleter(),
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("template", flag.ContinueOnError)
+			name := flagSet.String("name", "", "name of the template")
+			var categories stringSlice
+			flagSet.Var(&categories, "category", "category of the template, can be repeated")
+			landscape := flagSet.Bool("landscape", false, "the template is in landscape")
+			iconCode := flagSet.String("icon-code", "", "icon of the template")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+			args := flagSet.Args()
+			if len(args) < 1 {
+				c.Err(errors.New(templateUsage))
+				return
+			}
+
+			var err error
+			switch cmd, args := args[0], args[1:]; {
+			case cmd == "put" && len(args) == 1:
+				template := &archive.Template{
+					Name:       *name,
+					Categories: categories,
+					Landscape:  *landscape,
+					IconCode:   *iconCode,
+				}
+				err = putTemplate(ctx, c, args[0], template)
+			case cmd == "ls" && len(args) == 0:
+				err = listTemplates(ctx, c)
+			case cmd == "rm" && len(args) > 0:
+				err = removeTemplates(ctx, args)
+			default:
+				err = errors.New(templateUsage)
+			}
+
+			if err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+func putTemplate(ctx *ShellCtxt, c *ishell.Context, srcName string, template *archive.Template) error {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(srcName), "."))
+	if template.Name == "" {
+		template.Name = strings.TrimSuffix(filepath.Base(srcName), filepath.Ext(srcName))
+	}
+	if node := templateNode(ctx, template.Name); node != nil {
+		return fmt.Errorf("template %s already exists", template.Name)
+	}
+
+	f, err := os.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	c.Printf("uploading template: [%s]...", template.Name)
+	_, err = ctx.api.UploadTemplate(template, ext, f, true)
+	if err != nil {
+		return fmt.Errorf("failed to upload template [%s], %w", template.Name, err)
+	}
+	c.Println(" complete")
+	return nil
+}
+
+func listTemplates(ctx *ShellCtxt, c *ishell.Context) error {
+	for _, node := range templateNodes(ctx) {
+		template, err := ctx.api.DocumentTemplate(node.Id())
+		if err != nil {
+			return fmt.Errorf("%s: %w", node.Name(), err)
+		}
+		orientation := "portrait"
+		if template.Landscape {
+			orientation = "landscape"
+		}
+		c.Printf("%s\t%s\t%s\n", template.Name, strings.Join(template.Categories, ","), orientation)
+	}
+	return nil
+}
+
+func removeTemplates(ctx *ShellCtxt, names []string) error {
+	var nodes []*model.Node
+	for _, name := range names {
+		node := templateNode(ctx, name)
+		if node == nil {
+			return fmt.Errorf("template %s doesn't exist", name)
+		}
+		nodes = append(nodes, node)
+	}
+
+	if err := ctx.api.DeleteEntries(nodes, false, false); err != nil {
+		return fmt.Errorf("failed to remove templates, %w", err)
+	}
+
+	err := ctx.api.SyncComplete()
+	if err != nil {
+		return fmt.Errorf("cannot notify, %w", err)
+	}
+	return nil
+}
+
+// templateNodes returns the custom templates sorted by name
+func templateNodes(ctx *ShellCtxt) []*model.Node {
+	var templates []*model.Node
+	filetree.WalkTree(ctx.api.Filetree().Root(), filetree.FileTreeVistor{
+		Visit: func(n *model.Node, path []string) bool {
+			if n.Document != nil && n.Document.Type == model.TemplateType {
+				templates = append(templates, n)
+			}
+			return filetree.ContinueVisiting
+		},
+	})
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name() < templates[j].Name() })
+	return templates
+}
+
+// templateNode returns the custom template name or nil
+func templateNode(ctx *ShellCtxt, name string) *model.Node {
+	for _, n := range templateNodes(ctx) {
+		if n.Name() == name {
+			return n
+		}
+	}
+	return nil
+}
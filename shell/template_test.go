@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateCommands(t *testing.T) {
+	srv := apitest.NewServer()
+	uploadNotebook(t, srv, "minutes", 3)
+	shell, out := newTestShellFor(t, srv)
+
+	dir := t.TempDir()
+	svg := filepath.Join(dir, "meeting.svg")
+	assert.NoError(t, os.WriteFile(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0600))
+	png := filepath.Join(dir, "grid.png")
+	assert.NoError(t, os.WriteFile(png, []byte("\x89PNG"), 0600))
+
+	assert.NoError(t, shell.Process("template", "put", "--category=Work", "--category=Meetings", svg))
+	assert.NoError(t, shell.Process("template", "put", "--name=Big grid", "--landscape", png))
+	assert.Error(t, shell.Process("template", "put", svg))
+	assert.Error(t, shell.Process("template", "put", filepath.Join(dir, "missing.pdf")))
+
+	// another client sees the templates, ls hides them
+	other, out := newTestShellFor(t, srv)
+	assert.NoError(t, other.Process("template", "ls"))
+	assert.Equal(t, "Big grid\tCustom\tlandscape\nmeeting\tWork,Meetings\tportrait\n", out.String())
+	out.Reset()
+	assert.NoError(t, other.Process("ls"))
+	assert.NotContains(t, out.String(), "meeting\n")
+
+	assert.NoError(t, other.Process("pages", "set-template", "minutes", "meeting", "2-"))
+	out.Reset()
+	assert.NoError(t, other.Process("pages", "ls", "minutes"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Contains(t, lines[0], "\tBlank\t")
+	assert.Contains(t, lines[1], "\tmeeting\t")
+	assert.Contains(t, lines[2], "\tmeeting\t")
+
+	assert.NoError(t, other.Process("template", "rm", "meeting"))
+	assert.Error(t, other.Process("template", "rm", "meeting"))
+	out.Reset()
+	assert.NoError(t, other.Process("template", "ls"))
+	assert.Equal(t, "Big grid\tCustom\tlandscape\n", out.String())
+}
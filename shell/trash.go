@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell"
+	"github.com/juruen/rmapi/archive"
+	"github.com/juruen/rmapi/filetree"
+	"github.com/juruen/rmapi/model"
+	flag "github.com/ogier/pflag"
+)
+
+func restoreCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name:      "restore",
+		Help:      "move entries from the trash back to where they were deleted",
+		Completer: createEntryCompleter(ctx),
+		Func: func(c *ishell.Context) {
+			if len(c.Args) < 1 {
+				c.Err(errors.New("missing param"))
+				return
+			}
+
+			trash := ctx.api.Filetree().NodeById(filetree.TrashID)
+			nodes, err := entryNodes(ctx, c.Args)
+			if err != nil {
+				c.Err(err)
+				return
+			}
+
+			ids := make([]string, len(nodes))
+			for i, node := range nodes {
+				if node.Parent != trash {
+					c.Err(fmt.Errorf("%s is not in the trash", node.Name()))
+					return
+				}
+				ids[i] = node.Id()
+			}
+
+			// the original parent might have been deleted, the entry is restored into the root then
+			targets := restoreTargets(ctx)
+			docs, err := ctx.api.UpdateDocumentsMetadata(ids, func(_ string, metadata *archive.MetadataFile) error {
+				metadata.Parent = ""
+				if targets[metadata.TrashedFrom] {
+					metadata.Parent = metadata.TrashedFrom
+				}
+				metadata.TrashedFrom = ""
+				return nil
+			})
+			if err != nil {
+				c.Err(fmt.Errorf("failed to restore entry, %v", err))
+				return
+			}
+
+			for _, doc := range docs {
+				node := ctx.api.Filetree().NodeById(doc.ID)
+				if p, err := ctx.api.Filetree().NodeToPath(node); err == nil {
+					c.Println("restored: ", p)
+				}
+			}
+
+			err = ctx.api.SyncComplete()
+			if err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+// restoreTargets returns the directories entries can be restored into,
+// the ones that aren't in the trash
+func restoreTargets(ctx *ShellCtxt) map[string]bool {
+	trash := ctx.api.Filetree().NodeById(filetree.TrashID)
+	targets := make(map[string]bool)
+	filetree.WalkTree(ctx.api.Filetree().Root(), filetree.FileTreeVistor{
+		Visit: func(node *model.Node, path []string) bool {
+			if node.IsDirectory() && !isSubdir(trash, node) {
+				targets[node.Id()] = true
+			}
+			return filetree.ContinueVisiting
+		},
+	})
+	return targets
+}
+
+func emptyTrashCmd(ctx *ShellCtxt) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emptytrash",
+		Help: "delete the entries in the trash",
+		Func: func(c *ishell.Context) {
+			flagSet := flag.NewFlagSet("emptytrash", flag.ContinueOnError)
+			olderThan := flagSet.String("older-than", "", "only delete entries trashed before this age, e.g. 30d or 12h")
+			if err := flagSet.Parse(c.Args); err != nil {
+				if err != flag.ErrHelp {
+					c.Err(err)
+				}
+				return
+			}
+
+			var cutoff time.Time
+			if *olderThan != "" {
+				age, err := parseAge(*olderThan)
+				if err != nil {
+					c.Err(err)
+					return
+				}
+				cutoff = time.Now().Add(-age)
+			}
+
+			var nodes []*model.Node
+			for _, node := range ctx.api.Filetree().NodeById(filetree.TrashID).Nodes() {
+				if !cutoff.IsZero() {
+					// the time an entry is moved to the trash is its last modification
+					trashed, err := time.Parse(time.RFC3339Nano, node.Document.ModifiedClient)
+					if err != nil || trashed.After(cutoff) {
+						continue
+					}
+				}
+				nodes = append(nodes, node)
+			}
+			if len(nodes) == 0 {
+				c.Println("nothing to delete")
+				return
+			}
+
+			err := ctx.api.DeleteEntries(nodes, true, true)
+			if err != nil {
+				c.Err(fmt.Errorf("failed to delete entry, %v", err))
+				return
+			}
+			c.Printf("deleted %d entries\n", len(nodes))
+
+			err = ctx.api.SyncComplete()
+			if err != nil {
+				c.Err(err)
+			}
+		},
+	}
+}
+
+// parseAge parses a duration, which can also be given in days, e.g. 30d
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(s)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %s", s)
+	}
+	return age, nil
+}
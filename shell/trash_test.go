@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/juruen/rmapi/api/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashCommands(t *testing.T) {
+	srv := apitest.NewServer()
+	shell, out := newTestShellFor(t, srv)
+
+	assert.NoError(t, shell.Process("mkdir", "books"))
+	assert.NoError(t, shell.Process("mkdir", "books/novels"))
+	assert.NoError(t, shell.Process("mkdir", "papers"))
+
+	assert.Error(t, shell.Process("rm", "books"))
+	assert.NoError(t, shell.Process("rm", "-r", "books"))
+	assert.NoError(t, shell.Process("rm", "papers"))
+
+	out.Reset()
+	assert.NoError(t, shell.Process("ls", "trash"))
+	assert.Equal(t, "[d]\tbooks\n[d]\tpapers\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("restore", "trash/books"))
+	assert.Equal(t, "restored:  /books\n", out.String())
+	assert.Error(t, shell.Process("restore", "books"))
+
+	// a new session sees the changes, the trashed directory kept its entries
+	other, out := newTestShellFor(t, srv)
+	assert.NoError(t, other.Process("ls", "books"))
+	assert.Equal(t, "[d]\tnovels\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, other.Process("emptytrash", "--older-than=1d"))
+	assert.Equal(t, "nothing to delete\n", out.String())
+
+	assert.NoError(t, other.Process("rm", "books/novels"))
+	generation := srv.Generation()
+	out.Reset()
+	assert.NoError(t, other.Process("emptytrash"))
+	assert.Equal(t, "deleted 2 entries\n", out.String())
+	assert.Equal(t, generation+1, srv.Generation())
+
+	out.Reset()
+	assert.NoError(t, other.Process("ls", "trash"))
+	assert.Equal(t, "", out.String())
+
+	// permanent deletes remove the entries of directories too
+	assert.NoError(t, other.Process("mkdir", "books/novels"))
+	assert.NoError(t, other.Process("rm", "-r", "--permanent", "books"))
+	apiCtx, err := srv.NewApiCtx()
+	assert.NoError(t, err)
+	assert.Len(t, apiCtx.Filetree().Root().Children, 1)
+}
+
+func TestTrashOverlappingArguments(t *testing.T) {
+	srv := apitest.NewServer()
+	shell, out := newTestShellFor(t, srv)
+	assert.NoError(t, shell.Process("mkdir", "books"))
+	assert.NoError(t, shell.Process("mkdir", "books/ab"))
+	assert.NoError(t, shell.Process("mkdir", "books/ac"))
+	assert.NoError(t, shell.Process("cd", "books"))
+
+	// ab matches both arguments, it is trashed once and restored into books
+	generation := srv.Generation()
+	assert.NoError(t, shell.Process("rm", "a*", "ab"))
+	assert.Equal(t, generation+1, srv.Generation())
+
+	out.Reset()
+	assert.NoError(t, shell.Process("restore", "/trash/ab"))
+	assert.Equal(t, "restored:  /books/ab\n", out.String())
+
+	assert.Error(t, shell.Process("rm", "/"))
+	assert.Error(t, shell.Process("rm", "missing"))
+}
@@ -44,12 +44,14 @@ const (
 type HttpClientCtx struct {
 	Client *http.Client
 	Tokens model.AuthTokens
+	// UserAgent overrides RmapiUserAGent when set
+	UserAgent string
 }
 
 func CreateHttpClientCtx(tokens model.AuthTokens) HttpClientCtx {
 	var httpClient = &http.Client{Timeout: 5 * 60 * time.Second}
 
-	return HttpClientCtx{httpClient, tokens}
+	return HttpClientCtx{Client: httpClient, Tokens: tokens}
 }
 
 func (ctx HttpClientCtx) addAuthorization(req *http.Request, authType AuthType) {
@@ -230,7 +232,11 @@ func (ctx HttpClientCtx) Request(authType AuthType, verb, url string, body io.Re
 	}
 
 	ctx.addAuthorization(request, authType)
-	request.Header["user-agent"] = []string{RmapiUserAGent}
+	userAgent := RmapiUserAGent
+	if ctx.UserAgent != "" {
+		userAgent = ctx.UserAgent
+	}
+	request.Header["user-agent"] = []string{userAgent}
 
 	if headers != nil {
 		for k, v := range headers {
@@ -275,6 +281,8 @@ func (ctx HttpClientCtx) Request(authType AuthType, verb, url string, body io.Re
 	switch response.StatusCode {
 	case http.StatusUnauthorized:
 		return response, ErrUnauthorized
+	case http.StatusNotFound:
+		return response, ErrNotFound
 	case http.StatusConflict:
 		return response, ErrConflict
 	case http.StatusPreconditionFailed: